@@ -15,7 +15,51 @@ limitations under the License.
 package v1
 
 import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// InjectRuntimeClassAnnotation set to "false" on a pod or a namespace keeps the runtime class
+	// injection of a KataConfig away from the pod or the pods of the namespace
+	InjectRuntimeClassAnnotation = "kataconfiguration.openshift.io/inject-runtime-class"
+
+	// RetryAnnotation requests a retry of failed nodes. On a KataConfig it holds a comma
+	// separated list of node names, on a Node any value retries that node.
+	RetryAnnotation = "kataconfiguration.openshift.io/retry"
+
+	// OwnerLabel is put on every object the operator creates for a KataConfig, it holds
+	// the name of the KataConfig
+	OwnerLabel = "kataconfiguration.openshift.io/owner"
+
+	// SandboxingLabel set to SandboxingRequired on a namespace only admits pods using the
+	// runtime class of a KataConfig into the namespace
+	SandboxingLabel    = "sandboxing"
+	SandboxingRequired = "required"
+
+	// KataInstalledLabel is set to "true" on the nodes kata is installed on
+	KataInstalledLabel = "kataconfiguration.openshift.io/kata-installed"
+
+	// KataVersionLabel holds the version of kata installed on the node
+	KataVersionLabel = "kataconfiguration.openshift.io/kata-version"
+
+	// NestedVirtualizationLabel is "true" if the node is a VM itself and the kata VMs run nested
+	NestedVirtualizationLabel = "kataconfiguration.openshift.io/nested-virtualization"
+
+	// HypervisorLabelPrefix followed by the name of a hypervisor, e.g. qemu, is set to "true" for
+	// every hypervisor kata can use on the node
+	HypervisorLabelPrefix = "hypervisor.kataconfiguration.openshift.io/"
+
+	// KataDefaultMemoryMiB and KataDefaultVCPUs are the sandbox VM settings kata uses if its
+	// configuration leaves them out
+	KataDefaultMemoryMiB = 2048
+	KataDefaultVCPUs     = 1
+
+	// DefaultRuntimeClassName is the runtime class of the default kata handler
+	DefaultRuntimeClassName = "kata"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -31,6 +75,150 @@ type KataConfigSpec struct {
 
 	// +optional
 	Config KataInstallConfig `json:"config"`
+
+	// RolloutStrategy controls how kata is rolled out to the selected nodes.
+	// If not specified, kata is installed on all selected nodes at once.
+	// +optional
+	RolloutStrategy *KataRolloutStrategy `json:"rolloutStrategy,omitempty"`
+
+	// RollbackPolicy decides what happens to a node the kata installation failed on.
+	// Automatic rolls the node back right away, Manual leaves the node as it is until
+	// it is listed in RollbackNodes. Defaults to Automatic.
+	// +optional
+	// +kubebuilder:validation:Enum=Automatic;Manual
+	RollbackPolicy KataRollbackPolicy `json:"rollbackPolicy,omitempty"`
+
+	// RollbackNodes is the list of failed nodes to roll back if the rollback policy is Manual
+	// +optional
+	RollbackNodes []string `json:"rollbackNodes,omitempty"`
+
+	// RetryPolicy controls the automatic retry of nodes the kata installation failed on.
+	// If not specified, failed nodes are only retried on request.
+	// +optional
+	RetryPolicy *KataRetryPolicy `json:"retryPolicy,omitempty"`
+
+	// Overhead controls the pod overhead of the runtime class. If not specified, the overhead is
+	// computed from the sandbox VM settings of the kata configuration on the nodes.
+	// +optional
+	Overhead *KataOverheadConfig `json:"overhead,omitempty"`
+
+	// NodeTaint is put on the nodes once kata is installed on them, e.g. kata=true:NoSchedule, so
+	// that only sandboxed workloads land there. The runtime class tolerates it, the pods using it
+	// get the toleration.
+	// +optional
+	NodeTaint *corev1.Taint `json:"nodeTaint,omitempty"`
+
+	// Tolerations let the daemons and the pods using the runtime class run on nodes with these
+	// taints, e.g. the taints of dedicated nodes
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// RuntimeClassInjection sets the runtime class of the KataConfig on new pods that don't set a
+	// runtime class themselves. Pods and namespaces opt out with the annotation
+	// kataconfiguration.openshift.io/inject-runtime-class: "false".
+	// +optional
+	RuntimeClassInjection *KataRuntimeClassInjection `json:"runtimeClassInjection,omitempty"`
+
+	// UninstallPolicy decides what happens to the pods using the runtime class when the KataConfig
+	// is deleted. Block waits until they are deleted, Evict evicts them through the eviction API,
+	// which respects their PodDisruptionBudgets. Defaults to Block.
+	// +optional
+	// +kubebuilder:validation:Enum=Block;Evict
+	UninstallPolicy KataUninstallPolicy `json:"uninstallPolicy,omitempty"`
+
+	// EvictionDeadlineSeconds is how long the pods are evicted for if the uninstall policy is
+	// Evict. Pods still running at the deadline, e.g. because of their PodDisruptionBudget, are
+	// left to be deleted by hand. Defaults to 600 seconds.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	EvictionDeadlineSeconds int `json:"evictionDeadlineSeconds,omitempty"`
+}
+
+// KataUninstallPolicy decides what happens to the pods using kata when kata is uninstalled
+type KataUninstallPolicy string
+
+const (
+	// UninstallPolicyBlock waits until the pods using kata are deleted
+	UninstallPolicyBlock KataUninstallPolicy = "Block"
+
+	// UninstallPolicyEvict evicts the pods using kata
+	UninstallPolicyEvict KataUninstallPolicy = "Evict"
+)
+
+// KataRuntimeClassInjection selects the pods the runtime class is injected into. If both selectors
+// are set, a pod has to match both.
+type KataRuntimeClassInjection struct {
+	// NamespaceSelector selects the namespaces whose pods get the runtime class
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// PodSelector selects the pods that get the runtime class by their labels
+	// +optional
+	PodSelector *metav1.LabelSelector `json:"podSelector,omitempty"`
+}
+
+// KataOverheadConfig sets the pod overhead of the runtime class or the VM settings it is computed from
+type KataOverheadConfig struct {
+	// PodFixed is set as the overhead of the runtime class as is, nothing is computed then
+	// +optional
+	PodFixed corev1.ResourceList `json:"podFixed,omitempty"`
+
+	// DefaultMemoryMiB replaces the memory of the sandbox VM read from the kata configuration of the nodes
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	DefaultMemoryMiB int32 `json:"defaultMemoryMiB,omitempty"`
+
+	// DefaultVCPUs replaces the number of vCPUs of the sandbox VM read from the kata configuration of the nodes
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	DefaultVCPUs int32 `json:"defaultVCPUs,omitempty"`
+}
+
+// KataRetryPolicy defines how often and when failed nodes are retried automatically
+type KataRetryPolicy struct {
+	// MaxAttempts is the number of automatic retries per node
+	// +kubebuilder:validation:Minimum=0
+	MaxAttempts int `json:"maxAttempts"`
+
+	// BackoffSeconds is the delay before the first automatic retry, it doubles with
+	// every further attempt up to one hour. Defaults to 60 seconds.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	BackoffSeconds int `json:"backoffSeconds,omitempty"`
+}
+
+// KataRollbackPolicy decides if failed nodes are rolled back automatically
+type KataRollbackPolicy string
+
+const (
+	// RollbackPolicyAutomatic rolls back a node as soon as the kata installation failed on it
+	RollbackPolicyAutomatic KataRollbackPolicy = "Automatic"
+
+	// RollbackPolicyManual only rolls back the nodes listed in RollbackNodes
+	RollbackPolicyManual KataRollbackPolicy = "Manual"
+)
+
+// KataRolloutStrategy defines how kata is rolled out to the selected nodes
+type KataRolloutStrategy struct {
+	// CanaryNodes is the number of nodes kata is installed on first. The other
+	// nodes are only released once the canary nodes finished the installation.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	CanaryNodes int `json:"canaryNodes,omitempty"`
+
+	// BatchSize is the number or percentage of the selected nodes released at once
+	// after the canary nodes. If not specified, all remaining nodes are released at once.
+	// +optional
+	BatchSize *intstr.IntOrString `json:"batchSize,omitempty"`
+
+	// FailureThreshold is the number of failed nodes that pauses the rollout, defaults to 1
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	FailureThreshold int `json:"failureThreshold,omitempty"`
+
+	// Paused stops releasing further nodes to the rollout
+	// +optional
+	Paused bool `json:"paused,omitempty"`
 }
 
 // KataConfigStatus defines the observed state of KataConfig
@@ -44,10 +232,48 @@ type KataConfigStatus struct {
 	// TotalNodesCounts is the total number of worker nodes targeted by this CR
 	TotalNodesCount int `json:"totalNodesCount"`
 
+	// PoolName is the name of the kata MachineConfigPool of this KataConfig, kata-oc for the
+	// first KataConfig and kata-oc-<name> for any further one. The names of the MachineConfig,
+	// the daemonsets and the runtime class are derived from it.
+	// +optional
+	PoolName string `json:"poolName,omitempty"`
+
+	// MachineConfigPoolStatus reflects the machine config pool the kata nodes are configured by
+	// +optional
+	MachineConfigPoolStatus KataMachineConfigPoolStatus `json:"machineConfigPoolStatus,omitempty"`
+
+	// Topology reflects the cluster topology the kata installation is planned for
+	// +optional
+	Topology KataTopologyStatus `json:"topology,omitempty"`
+
+	// OverheadStatus records the pod overhead of the runtime class and how it was derived
+	// +optional
+	OverheadStatus KataOverheadStatus `json:"overheadStatus,omitempty"`
+
+	// RolloutStatus reflects the progress of the rollout if a rollout strategy is used
+	// +optional
+	RolloutStatus KataRolloutStatus `json:"rolloutStatus,omitempty"`
+
+	// PreflightStatus reflects the results of the host checks run on the nodes before kata installation
+	// +optional
+	PreflightStatus KataPreflightStatus `json:"preflightStatus,omitempty"`
+
 	// InstallationStatus reflects the status of the ongoing kata installation
 	// +optional
 	InstallationStatus KataInstallationStatus `json:"installationStatus,omitempty"`
 
+	// RollbackStatus reflects the rollback of the nodes the kata installation failed on
+	// +optional
+	RollbackStatus KataRollbackStatus `json:"rollbackStatus,omitempty"`
+
+	// RetryStatus holds the history of the retries of failed nodes
+	// +optional
+	RetryStatus KataRetryStatus `json:"retryStatus,omitempty"`
+
+	// ValidationStatus reflects the result of starting a kata pod on each node after installation
+	// +optional
+	ValidationStatus KataValidationStatus `json:"validationStatus,omitempty"`
+
 	// UnInstallationStatus reflects the status of the ongoing kata uninstallation
 	// +optional
 	UnInstallationStatus KataUnInstallationStatus `json:"unInstallationStatus,omitempty"`
@@ -55,6 +281,28 @@ type KataConfigStatus struct {
 	// Upgradestatus reflects the status of the ongoing kata upgrade
 	// +optional
 	Upgradestatus KataUpgradeStatus `json:"upgradeStatus,omitempty"`
+
+	// NodeStatus reflects the progress of the installation daemon on each node
+	// +optional
+	NodeStatus []KataNodeStatus `json:"nodeStatus,omitempty"`
+
+	// Conditions represent the latest available observations of the KataConfig
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// RuntimeClassNames returns the runtime classes in RuntimeClass. On Kubernetes it lists the runtime
+// classes of all kata-deploy handlers separated by commas.
+func (s *KataConfigStatus) RuntimeClassNames() []string {
+	var names []string
+	for _, name := range strings.Split(s.RuntimeClass, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
 }
 
 // +genclient
@@ -91,6 +339,79 @@ func init() {
 type KataInstallConfig struct {
 	// SourceImage is the name of the kata-deploy image
 	SourceImage string `json:"sourceImage"`
+
+	// PullSecret references a secret of type kubernetes.io/dockerconfigjson in the namespace of the
+	// operator, it holds the credentials to pull the payload image with
+	// +optional
+	PullSecret *corev1.LocalObjectReference `json:"pullSecret,omitempty"`
+
+	// UseClusterPullSecret pulls the payload image with the global pull secret of the cluster too.
+	// The credentials in PullSecret take precedence for the registries both have credentials for.
+	// +optional
+	UseClusterPullSecret bool `json:"useClusterPullSecret,omitempty"`
+}
+
+// KataRolloutStatus reflects the progress of the rollout
+type KataRolloutStatus struct {
+	// ReleasedNodesList reflects the list of nodes released to the rollout so far
+	// +optional
+	ReleasedNodesList []string `json:"releasedNodesList,omitempty"`
+
+	// CurrentBatch is the number of the batch being rolled out, the canary nodes are the first batch
+	// +optional
+	CurrentBatch int `json:"currentBatch,omitempty"`
+
+	// Paused is true if no further nodes are released, either because of the rollout strategy
+	// or because the failure threshold was reached
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// Reason explains why the rollout is paused
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// KataPreflightStatus reflects the results of the host checks run on the nodes before kata installation
+type KataPreflightStatus struct {
+	// PassedNodesList reflects the list of nodes that passed all preflight checks
+	// +optional
+	PassedNodesList []string `json:"passedNodesList,omitempty"`
+
+	// Failed reflects the status of nodes that failed at least one preflight check.
+	// Kata installation is skipped on these nodes.
+	// +optional
+	Failed KataFailedNodeStatus `json:"failed,omitempty"`
+
+	// NodeResults holds the result of every preflight check per node
+	// +optional
+	NodeResults []NodePreflightResult `json:"nodeResults,omitempty"`
+}
+
+// NodePreflightResult holds the preflight check results of a single node
+type NodePreflightResult struct {
+	// Name of the node
+	Name string `json:"name"`
+
+	// Checks holds the result of the individual preflight checks
+	// +optional
+	Checks []PreflightCheckResult `json:"checks,omitempty"`
+
+	// NestedVirtualization is true if the node is a VM itself and the kata VMs run nested
+	// +optional
+	NestedVirtualization *bool `json:"nestedVirtualization,omitempty"`
+}
+
+// PreflightCheckResult holds the outcome of a single preflight check
+type PreflightCheckResult struct {
+	// Name of the check, e.g. kvm or cpu-virtualization
+	Name string `json:"name"`
+
+	// Passed is true if the node meets the requirement of the check
+	Passed bool `json:"passed"`
+
+	// Message describes what was found on the node
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // KataInstallationStatus reflects the status of the ongoing kata installation
@@ -133,6 +454,261 @@ type KataFailedNodeStatus struct {
 	FailedNodesList []FailedNodeStatus `json:"failedNodesList,omitempty"`
 }
 
+// KataRollbackStatus reflects the rollback of the nodes the kata installation failed on
+type KataRollbackStatus struct {
+	// CompletedNodesList reflects the list of nodes that were restored to their state
+	// before the kata installation and removed from the kata pool
+	// +optional
+	CompletedNodesList []string `json:"completedNodesList,omitempty"`
+
+	// RequestedNodesList reflects the list of rollback nodes the installation daemon
+	// got restarted on to roll them back
+	// +optional
+	RequestedNodesList []string `json:"requestedNodesList,omitempty"`
+
+	// Failed reflects the status of nodes the rollback failed on
+	// +optional
+	Failed KataFailedNodeStatus `json:"failed,omitempty"`
+}
+
+// KataClusterTopology is the kind of cluster kata is installed on
+type KataClusterTopology string
+
+const (
+	// ClusterTopologyStandard is a cluster with dedicated worker nodes
+	ClusterTopologyStandard KataClusterTopology = "Standard"
+
+	// ClusterTopologyCompact is a cluster whose control plane nodes are the only nodes
+	ClusterTopologyCompact KataClusterTopology = "Compact"
+
+	// ClusterTopologySingleNode is a single node OpenShift cluster
+	ClusterTopologySingleNode KataClusterTopology = "SingleNode"
+)
+
+// KataTopologyStatus holds the detected cluster topology
+type KataTopologyStatus struct {
+	// ControlPlaneTopology as reported by the cluster infrastructure config,
+	// empty if the cluster doesn't report it
+	// +optional
+	ControlPlaneTopology string `json:"controlPlaneTopology,omitempty"`
+
+	// InfrastructureTopology as reported by the cluster infrastructure config,
+	// empty if the cluster doesn't report it
+	// +optional
+	InfrastructureTopology string `json:"infrastructureTopology,omitempty"`
+
+	// Type is the kind of cluster derived from the infrastructure config and the control plane nodes
+	// +optional
+	// +kubebuilder:validation:Enum=Standard;Compact;SingleNode
+	Type KataClusterTopology `json:"type,omitempty"`
+
+	// MachinePool is the machine config pool the selected nodes belong to without a kata pool,
+	// master on compact and single node clusters and worker otherwise
+	// +optional
+	MachinePool string `json:"machinePool,omitempty"`
+}
+
+// KataNodePhase is the step the installation daemon is at on a node
+type KataNodePhase string
+
+const (
+	// NodePhaseInstalling is set while the kata binaries are installed on the node
+	NodePhaseInstalling KataNodePhase = "Installing"
+
+	// NodePhaseBinariesInstalled is set once the binaries are installed and the node waits for the CRI-O configuration
+	NodePhaseBinariesInstalled KataNodePhase = "BinariesInstalled"
+
+	// NodePhaseInstalled is set once the node is ready to run kata
+	NodePhaseInstalled KataNodePhase = "Installed"
+
+	// NodePhaseFailed is set if the installation or the uninstallation failed on the node
+	NodePhaseFailed KataNodePhase = "Failed"
+
+	// NodePhaseUninstalling is set while the kata binaries are removed from the node
+	NodePhaseUninstalling KataNodePhase = "Uninstalling"
+
+	// NodePhaseBinariesUninstalled is set once the binaries are removed from the node
+	NodePhaseBinariesUninstalled KataNodePhase = "BinariesUninstalled"
+)
+
+// KataNodeStatus holds the progress of the installation daemon on a node
+type KataNodeStatus struct {
+	// Name of the node
+	Name string `json:"name"`
+
+	// Phase is the step the installation daemon is at
+	// +optional
+	Phase KataNodePhase `json:"phase,omitempty"`
+
+	// PayloadVersion is the version of the kata payload installed on the node
+	// +optional
+	PayloadVersion string `json:"payloadVersion,omitempty"`
+
+	// KataVersion is the version of kata installed on the node, the payload of an OpenShift
+	// version may ship any kata version
+	// +optional
+	KataVersion string `json:"kataVersion,omitempty"`
+
+	// StartTime is the time the installation daemon started the current operation
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is the time the current operation completed or failed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Error is the error of the failed operation
+	// +optional
+	Error string `json:"error,omitempty"`
+
+	// Diagnostics points to the diagnostics the installation daemon collected when the operation failed
+	// +optional
+	Diagnostics *KataNodeDiagnostics `json:"diagnostics,omitempty"`
+}
+
+// KataNodeDiagnostics locates the diagnostics tarball of a failed node. It holds the rpm-ostree status,
+// the journal of the CRI-O and kata units, the output of the kata check, the CRI-O drop-in files and
+// the KVM messages of the kernel log.
+type KataNodeDiagnostics struct {
+	// CollectionTime is the time the diagnostics were collected
+	CollectionTime metav1.Time `json:"collectionTime"`
+
+	// HostPath is the path of the tarball on the node
+	// +optional
+	HostPath string `json:"hostPath,omitempty"`
+
+	// ConfigMap is the name of the ConfigMap in the operator namespace holding the tarball, it is
+	// empty if the tarball exceeds the size limit of a ConfigMap
+	// +optional
+	ConfigMap string `json:"configMap,omitempty"`
+}
+
+// KataOverheadSource tells where the pod overhead of the runtime class comes from
+type KataOverheadSource string
+
+const (
+	// OverheadSourceSpec is the overhead set in the KataConfig spec
+	OverheadSourceSpec KataOverheadSource = "Spec"
+
+	// OverheadSourceComputed is computed from the sandbox VM settings
+	OverheadSourceComputed KataOverheadSource = "Computed"
+
+	// OverheadSourceDefault is the overhead of the upstream kata-deploy runtime class, used
+	// as long as no node reported its sandbox VM settings
+	OverheadSourceDefault KataOverheadSource = "Default"
+)
+
+// KataOverheadStatus records the pod overhead of the runtime class
+type KataOverheadStatus struct {
+	// PodFixed is the overhead set in the runtime class
+	// +optional
+	PodFixed corev1.ResourceList `json:"podFixed,omitempty"`
+
+	// Source tells where the overhead comes from
+	// +optional
+	// +kubebuilder:validation:Enum=Spec;Computed;Default
+	Source KataOverheadSource `json:"source,omitempty"`
+
+	// Derivation describes how the overhead was calculated
+	// +optional
+	Derivation string `json:"derivation,omitempty"`
+
+	// NodesList holds the sandbox VM settings the installation daemon read on each node
+	// +optional
+	NodesList []NodeSandboxConfig `json:"nodesList,omitempty"`
+}
+
+// NodeSandboxConfig holds the sandbox VM settings of the kata configuration on a node
+type NodeSandboxConfig struct {
+	// Name of the node
+	Name string `json:"name"`
+
+	// Hypervisor is the hypervisor section the settings were read from
+	// +optional
+	Hypervisor string `json:"hypervisor,omitempty"`
+
+	// DefaultMemoryMiB is the memory of the sandbox VM before the memory of the containers is added
+	DefaultMemoryMiB int32 `json:"defaultMemoryMiB"`
+
+	// DefaultVCPUs is the number of vCPUs of the sandbox VM before the CPUs of the containers are added
+	DefaultVCPUs int32 `json:"defaultVCPUs"`
+}
+
+// KataMachineConfigPoolStatus mirrors the status of the machine config pool the kata nodes are in
+type KataMachineConfigPoolStatus struct {
+	// Name of the machine config pool, this is the kata pool or the parent pool
+	// if the kata MachineConfig is applied to the parent pool directly
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// MachineCount is the number of machines in the pool
+	// +optional
+	MachineCount int32 `json:"machineCount,omitempty"`
+
+	// ReadyMachineCount is the number of machines of the pool that are ready
+	// +optional
+	ReadyMachineCount int32 `json:"readyMachineCount,omitempty"`
+
+	// UpdatedMachineCount is the number of machines of the pool running the current configuration
+	// +optional
+	UpdatedMachineCount int32 `json:"updatedMachineCount,omitempty"`
+
+	// DegradedMachineCount is the number of machines the configuration failed to apply on
+	// +optional
+	DegradedMachineCount int32 `json:"degradedMachineCount,omitempty"`
+
+	// DegradedNodesList lists the degraded nodes of the pool with the reason
+	// reported by the machine config daemon
+	// +optional
+	DegradedNodesList []FailedNodeStatus `json:"degradedNodesList,omitempty"`
+}
+
+// KataRetryStatus holds the history of the retries of failed nodes
+type KataRetryStatus struct {
+	// Nodes holds the retry history per node
+	// +optional
+	Nodes []NodeRetryStatus `json:"nodes,omitempty"`
+}
+
+// NodeRetryStatus holds the retry history of a single node
+type NodeRetryStatus struct {
+	// Name of the node
+	Name string `json:"name"`
+
+	// Attempts holds the retries of the node, oldest first
+	// +optional
+	Attempts []RetryAttempt `json:"attempts,omitempty"`
+
+	// NextRetryTime is when the next automatic retry is due
+	// +optional
+	NextRetryTime *metav1.Time `json:"nextRetryTime,omitempty"`
+}
+
+// RetryAttempt records a single retry of a failed node
+type RetryAttempt struct {
+	// Time the node was retried
+	Time metav1.Time `json:"time"`
+
+	// Trigger is Automatic for retries of the retry policy and Annotation
+	// for retries requested through the retry annotation
+	Trigger string `json:"trigger"`
+
+	// Error is the failure that got retried
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// KataValidationStatus reflects the result of starting a kata pod on each node after installation
+type KataValidationStatus struct {
+	// ReadyNodesList reflects the list of nodes that successfully started a kata pod
+	// +optional
+	ReadyNodesList []string `json:"readyNodesList,omitempty"`
+
+	// Failed reflects the status of nodes that were not able to start a kata pod
+	// +optional
+	Failed KataFailedNodeStatus `json:"failed,omitempty"`
+}
+
 // KataUnInstallationStatus reflects the status of the ongoing kata uninstallation
 type KataUnInstallationStatus struct {
 	// InProgress reflects the status of nodes that are in the process of kata uninstallation
@@ -150,6 +726,40 @@ type KataUnInstallationInProgressStatus struct {
 	InProgressNodesCount int `json:"inProgressNodesCount,omitempty"`
 	// +optional
 	BinariesUnInstalledNodesList []string `json:"binariesUninstallNodesList,omitempty"`
+
+	// BlockingPodsCount is the number of pods using a kata runtime class, kata is only
+	// uninstalled once they are gone
+	// +optional
+	BlockingPodsCount int `json:"blockingPodsCount,omitempty"`
+
+	// BlockingPods lists the pods using a kata runtime class, at most the first 50
+	// +optional
+	BlockingPods []KataBlockingPod `json:"blockingPods,omitempty"`
+
+	// EvictionStartTime is when the eviction of the blocking pods started if the uninstall
+	// policy is Evict
+	// +optional
+	EvictionStartTime *metav1.Time `json:"evictionStartTime,omitempty"`
+}
+
+// KataBlockingPod is a pod using a kata runtime class which keeps kata from being uninstalled
+type KataBlockingPod struct {
+	// Namespace of the pod
+	Namespace string `json:"namespace"`
+
+	// Name of the pod
+	Name string `json:"name"`
+
+	// RuntimeClass is the kata runtime class the pod uses
+	RuntimeClass string `json:"runtimeClass"`
+
+	// Owner is the kind and name of the controller of the pod, e.g. ReplicaSet/web-5d8f7c9b4
+	// +optional
+	Owner string `json:"owner,omitempty"`
+
+	// EvictionError is why the last eviction of the pod failed, e.g. its PodDisruptionBudget
+	// +optional
+	EvictionError string `json:"evictionError,omitempty"`
 }
 
 // KataUpgradeStatus reflects the status of the ongoing kata upgrade
@@ -21,8 +21,10 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -40,6 +42,21 @@ func (in *FailedNodeStatus) DeepCopy() *FailedNodeStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataBlockingPod) DeepCopyInto(out *KataBlockingPod) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataBlockingPod.
+func (in *KataBlockingPod) DeepCopy() *KataBlockingPod {
+	if in == nil {
+		return nil
+	}
+	out := new(KataBlockingPod)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KataConfig) DeepCopyInto(out *KataConfig) {
 	*out = *in
@@ -127,7 +144,44 @@ func (in *KataConfigSpec) DeepCopyInto(out *KataConfigSpec) {
 		*out = new(metav1.LabelSelector)
 		(*in).DeepCopyInto(*out)
 	}
-	out.Config = in.Config
+	in.Config.DeepCopyInto(&out.Config)
+	if in.RolloutStrategy != nil {
+		in, out := &in.RolloutStrategy, &out.RolloutStrategy
+		*out = new(KataRolloutStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RollbackNodes != nil {
+		in, out := &in.RollbackNodes, &out.RollbackNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RetryPolicy != nil {
+		in, out := &in.RetryPolicy, &out.RetryPolicy
+		*out = new(KataRetryPolicy)
+		**out = **in
+	}
+	if in.Overhead != nil {
+		in, out := &in.Overhead, &out.Overhead
+		*out = new(KataOverheadConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NodeTaint != nil {
+		in, out := &in.NodeTaint, &out.NodeTaint
+		*out = new(corev1.Taint)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RuntimeClassInjection != nil {
+		in, out := &in.RuntimeClassInjection, &out.RuntimeClassInjection
+		*out = new(KataRuntimeClassInjection)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataConfigSpec.
@@ -143,9 +197,31 @@ func (in *KataConfigSpec) DeepCopy() *KataConfigSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KataConfigStatus) DeepCopyInto(out *KataConfigStatus) {
 	*out = *in
+	in.MachineConfigPoolStatus.DeepCopyInto(&out.MachineConfigPoolStatus)
+	out.Topology = in.Topology
+	in.OverheadStatus.DeepCopyInto(&out.OverheadStatus)
+	in.RolloutStatus.DeepCopyInto(&out.RolloutStatus)
+	in.PreflightStatus.DeepCopyInto(&out.PreflightStatus)
 	in.InstallationStatus.DeepCopyInto(&out.InstallationStatus)
+	in.RollbackStatus.DeepCopyInto(&out.RollbackStatus)
+	in.RetryStatus.DeepCopyInto(&out.RetryStatus)
+	in.ValidationStatus.DeepCopyInto(&out.ValidationStatus)
 	in.UnInstallationStatus.DeepCopyInto(&out.UnInstallationStatus)
 	out.Upgradestatus = in.Upgradestatus
+	if in.NodeStatus != nil {
+		in, out := &in.NodeStatus, &out.NodeStatus
+		*out = make([]KataNodeStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataConfigStatus.
@@ -181,6 +257,11 @@ func (in *KataFailedNodeStatus) DeepCopy() *KataFailedNodeStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KataInstallConfig) DeepCopyInto(out *KataInstallConfig) {
 	*out = *in
+	if in.PullSecret != nil {
+		in, out := &in.PullSecret, &out.PullSecret
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataInstallConfig.
@@ -231,6 +312,290 @@ func (in *KataInstallationStatus) DeepCopy() *KataInstallationStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataMachineConfigPoolStatus) DeepCopyInto(out *KataMachineConfigPoolStatus) {
+	*out = *in
+	if in.DegradedNodesList != nil {
+		in, out := &in.DegradedNodesList, &out.DegradedNodesList
+		*out = make([]FailedNodeStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataMachineConfigPoolStatus.
+func (in *KataMachineConfigPoolStatus) DeepCopy() *KataMachineConfigPoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataMachineConfigPoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataNodeDiagnostics) DeepCopyInto(out *KataNodeDiagnostics) {
+	*out = *in
+	in.CollectionTime.DeepCopyInto(&out.CollectionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataNodeDiagnostics.
+func (in *KataNodeDiagnostics) DeepCopy() *KataNodeDiagnostics {
+	if in == nil {
+		return nil
+	}
+	out := new(KataNodeDiagnostics)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataNodeStatus) DeepCopyInto(out *KataNodeStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Diagnostics != nil {
+		in, out := &in.Diagnostics, &out.Diagnostics
+		*out = new(KataNodeDiagnostics)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataNodeStatus.
+func (in *KataNodeStatus) DeepCopy() *KataNodeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataNodeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataOverheadConfig) DeepCopyInto(out *KataOverheadConfig) {
+	*out = *in
+	if in.PodFixed != nil {
+		in, out := &in.PodFixed, &out.PodFixed
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataOverheadConfig.
+func (in *KataOverheadConfig) DeepCopy() *KataOverheadConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KataOverheadConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataOverheadStatus) DeepCopyInto(out *KataOverheadStatus) {
+	*out = *in
+	if in.PodFixed != nil {
+		in, out := &in.PodFixed, &out.PodFixed
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.NodesList != nil {
+		in, out := &in.NodesList, &out.NodesList
+		*out = make([]NodeSandboxConfig, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataOverheadStatus.
+func (in *KataOverheadStatus) DeepCopy() *KataOverheadStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataOverheadStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataPreflightStatus) DeepCopyInto(out *KataPreflightStatus) {
+	*out = *in
+	if in.PassedNodesList != nil {
+		in, out := &in.PassedNodesList, &out.PassedNodesList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Failed.DeepCopyInto(&out.Failed)
+	if in.NodeResults != nil {
+		in, out := &in.NodeResults, &out.NodeResults
+		*out = make([]NodePreflightResult, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataPreflightStatus.
+func (in *KataPreflightStatus) DeepCopy() *KataPreflightStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataPreflightStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataRetryPolicy) DeepCopyInto(out *KataRetryPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataRetryPolicy.
+func (in *KataRetryPolicy) DeepCopy() *KataRetryPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(KataRetryPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataRetryStatus) DeepCopyInto(out *KataRetryStatus) {
+	*out = *in
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]NodeRetryStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataRetryStatus.
+func (in *KataRetryStatus) DeepCopy() *KataRetryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataRetryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataRollbackStatus) DeepCopyInto(out *KataRollbackStatus) {
+	*out = *in
+	if in.CompletedNodesList != nil {
+		in, out := &in.CompletedNodesList, &out.CompletedNodesList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RequestedNodesList != nil {
+		in, out := &in.RequestedNodesList, &out.RequestedNodesList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Failed.DeepCopyInto(&out.Failed)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataRollbackStatus.
+func (in *KataRollbackStatus) DeepCopy() *KataRollbackStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataRollbackStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataRolloutStatus) DeepCopyInto(out *KataRolloutStatus) {
+	*out = *in
+	if in.ReleasedNodesList != nil {
+		in, out := &in.ReleasedNodesList, &out.ReleasedNodesList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataRolloutStatus.
+func (in *KataRolloutStatus) DeepCopy() *KataRolloutStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataRolloutStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataRolloutStrategy) DeepCopyInto(out *KataRolloutStrategy) {
+	*out = *in
+	if in.BatchSize != nil {
+		in, out := &in.BatchSize, &out.BatchSize
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataRolloutStrategy.
+func (in *KataRolloutStrategy) DeepCopy() *KataRolloutStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(KataRolloutStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataRuntimeClassInjection) DeepCopyInto(out *KataRuntimeClassInjection) {
+	*out = *in
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodSelector != nil {
+		in, out := &in.PodSelector, &out.PodSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataRuntimeClassInjection.
+func (in *KataRuntimeClassInjection) DeepCopy() *KataRuntimeClassInjection {
+	if in == nil {
+		return nil
+	}
+	out := new(KataRuntimeClassInjection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataTopologyStatus) DeepCopyInto(out *KataTopologyStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataTopologyStatus.
+func (in *KataTopologyStatus) DeepCopy() *KataTopologyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataTopologyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KataUnInstallationInProgressStatus) DeepCopyInto(out *KataUnInstallationInProgressStatus) {
 	*out = *in
@@ -239,6 +604,15 @@ func (in *KataUnInstallationInProgressStatus) DeepCopyInto(out *KataUnInstallati
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.BlockingPods != nil {
+		in, out := &in.BlockingPods, &out.BlockingPods
+		*out = make([]KataBlockingPod, len(*in))
+		copy(*out, *in)
+	}
+	if in.EvictionStartTime != nil {
+		in, out := &in.EvictionStartTime, &out.EvictionStartTime
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataUnInstallationInProgressStatus.
@@ -283,3 +657,121 @@ func (in *KataUpgradeStatus) DeepCopy() *KataUpgradeStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KataValidationStatus) DeepCopyInto(out *KataValidationStatus) {
+	*out = *in
+	if in.ReadyNodesList != nil {
+		in, out := &in.ReadyNodesList, &out.ReadyNodesList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Failed.DeepCopyInto(&out.Failed)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KataValidationStatus.
+func (in *KataValidationStatus) DeepCopy() *KataValidationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KataValidationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodePreflightResult) DeepCopyInto(out *NodePreflightResult) {
+	*out = *in
+	if in.Checks != nil {
+		in, out := &in.Checks, &out.Checks
+		*out = make([]PreflightCheckResult, len(*in))
+		copy(*out, *in)
+	}
+	if in.NestedVirtualization != nil {
+		in, out := &in.NestedVirtualization, &out.NestedVirtualization
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodePreflightResult.
+func (in *NodePreflightResult) DeepCopy() *NodePreflightResult {
+	if in == nil {
+		return nil
+	}
+	out := new(NodePreflightResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeRetryStatus) DeepCopyInto(out *NodeRetryStatus) {
+	*out = *in
+	if in.Attempts != nil {
+		in, out := &in.Attempts, &out.Attempts
+		*out = make([]RetryAttempt, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NextRetryTime != nil {
+		in, out := &in.NextRetryTime, &out.NextRetryTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeRetryStatus.
+func (in *NodeRetryStatus) DeepCopy() *NodeRetryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeRetryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeSandboxConfig) DeepCopyInto(out *NodeSandboxConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeSandboxConfig.
+func (in *NodeSandboxConfig) DeepCopy() *NodeSandboxConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeSandboxConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreflightCheckResult) DeepCopyInto(out *PreflightCheckResult) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreflightCheckResult.
+func (in *PreflightCheckResult) DeepCopy() *PreflightCheckResult {
+	if in == nil {
+		return nil
+	}
+	out := new(PreflightCheckResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetryAttempt) DeepCopyInto(out *RetryAttempt) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetryAttempt.
+func (in *RetryAttempt) DeepCopy() *RetryAttempt {
+	if in == nil {
+		return nil
+	}
+	out := new(RetryAttempt)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,187 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-kata inspects and drives the kata installation of the sandboxed containers operator.
+// Installed in the PATH it is run as a kubectl or oc plugin, e.g. `oc kata status`.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	mcfgapi "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	nodeapi "k8s.io/kubernetes/pkg/apis/node/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const defaultOperatorNamespace = "sandboxed-containers-operator-system"
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(nodeapi.AddToScheme(scheme))
+	utilruntime.Must(mcfgapi.Install(scheme))
+	utilruntime.Must(kataconfigurationv1.AddToScheme(scheme))
+}
+
+// command is a subcommand of the plugin, it gets the arguments following its name
+type command struct {
+	usage       string
+	description string
+	run         func(o *options, args []string) error
+}
+
+var commands = map[string]command{
+	"status": {
+		usage:       "status",
+		description: "Show the kata installation phase of every node",
+		run:         runStatus,
+	},
+	"pods": {
+		usage:       "pods",
+		description: "List the pods using a kata runtime class",
+		run:         runPods,
+	},
+	"retry": {
+		usage:       "retry <node> [<node>...]",
+		description: "Retry the kata installation on failed nodes",
+		run:         runRetry,
+	},
+	"pause": {
+		usage:       "pause",
+		description: "Pause the kata rollout",
+		run:         runPause,
+	},
+	"resume": {
+		usage:       "resume",
+		description: "Resume the kata rollout",
+		run:         runResume,
+	},
+	"must-gather": {
+		usage:       "must-gather [--dest-dir <dir>]",
+		description: "Collect the daemon logs and node diagnostics, the machine config pools and the rendered MachineConfigs",
+		run:         runMustGather,
+	},
+}
+
+// options are the global flags shared by all commands
+type options struct {
+	kataConfigName string
+	namespace      string
+
+	config *rest.Config
+	client client.Client
+}
+
+func main() {
+	o := &options{}
+	flag.StringVar(&o.kataConfigName, "kataconfig", "", "Name of the KataConfig, required if there are several")
+	flag.StringVar(&o.namespace, "operator-namespace", defaultOperatorNamespace, "Namespace the operator and its daemons run in")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(1)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", flag.Arg(0))
+		usage()
+		os.Exit(1)
+	}
+
+	var err error
+	o.config, err = ctrl.GetConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to load the kubeconfig: %v\n", err)
+		os.Exit(1)
+	}
+	o.client, err = client.New(o.config, client.Options{Scheme: scheme})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to create the client: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := cmd.run(o, flag.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: kubectl-kata [flags] <command> [args]\n\nCommands:\n")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-32s %s\n", commands[name].usage, commands[name].description)
+	}
+	fmt.Fprintf(os.Stderr, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// kataConfigs returns the KataConfig selected with --kataconfig, all KataConfigs if none is selected
+func (o *options) kataConfigs() ([]kataconfigurationv1.KataConfig, error) {
+	if o.kataConfigName != "" {
+		kc := kataconfigurationv1.KataConfig{}
+		if err := o.client.Get(context.TODO(), client.ObjectKey{Name: o.kataConfigName}, &kc); err != nil {
+			return nil, err
+		}
+		return []kataconfigurationv1.KataConfig{kc}, nil
+	}
+
+	kataConfigList := &kataconfigurationv1.KataConfigList{}
+	if err := o.client.List(context.TODO(), kataConfigList); err != nil {
+		return nil, err
+	}
+	if len(kataConfigList.Items) == 0 {
+		return nil, fmt.Errorf("no KataConfig found")
+	}
+	sort.Slice(kataConfigList.Items, func(i, j int) bool {
+		return kataConfigList.Items[i].Name < kataConfigList.Items[j].Name
+	})
+	return kataConfigList.Items, nil
+}
+
+// kataConfig returns the single KataConfig a command acts on
+func (o *options) kataConfig() (*kataconfigurationv1.KataConfig, error) {
+	kataConfigs, err := o.kataConfigs()
+	if err != nil {
+		return nil, err
+	}
+	if len(kataConfigs) > 1 {
+		var names []string
+		for _, kc := range kataConfigs {
+			names = append(names, kc.Name)
+		}
+		return nil, fmt.Errorf("there are several KataConfigs, select one with --kataconfig: %s", strings.Join(names, ", "))
+	}
+	return &kataConfigs[0], nil
+}
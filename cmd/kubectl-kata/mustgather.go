@@ -0,0 +1,231 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	nodeapi "k8s.io/api/node/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+)
+
+// runMustGather writes the KataConfigs, the runtime classes, the machine config pools, the kata and rendered
+// MachineConfigs and the logs of the pods in the operator namespace to a directory
+func runMustGather(o *options, args []string) error {
+	fs := flag.NewFlagSet("must-gather", flag.ContinueOnError)
+	destDir := fs.String("dest-dir", "must-gather.local."+time.Now().Format("20060102150405"), "Directory to write to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	g := &gatherer{options: o, dir: *destDir}
+	for _, gather := range []func() error{
+		g.gatherKataConfigs,
+		g.gatherRuntimeClasses,
+		g.gatherMachineConfigs,
+		g.gatherPodLogs,
+		g.gatherNodeDiagnostics,
+	} {
+		if err := gather(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Wrote the diagnostics to %s\n", g.dir)
+	return nil
+}
+
+type gatherer struct {
+	*options
+	dir string
+}
+
+func (g *gatherer) gatherKataConfigs() error {
+	kataConfigList := &kataconfigurationv1.KataConfigList{}
+	if err := g.client.List(context.TODO(), kataConfigList); err != nil {
+		return err
+	}
+	return g.writeList("kataconfigs", kataConfigList)
+}
+
+func (g *gatherer) gatherRuntimeClasses() error {
+	rcList := &nodeapi.RuntimeClassList{}
+	if err := g.client.List(context.TODO(), rcList); err != nil {
+		return err
+	}
+	return g.writeList("runtimeclasses", rcList)
+}
+
+// gatherMachineConfigs writes all pools, the MachineConfigs created for the KataConfigs and the
+// rendered MachineConfigs the pools currently use or move to. There are none on Kubernetes.
+func (g *gatherer) gatherMachineConfigs() error {
+	mcpList := &mcfgv1.MachineConfigPoolList{}
+	if err := g.client.List(context.TODO(), mcpList); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil
+		}
+		return err
+	}
+	if err := g.writeList("machineconfigpools", mcpList); err != nil {
+		return err
+	}
+
+	rendered := sets.NewString()
+	for _, mcp := range mcpList.Items {
+		rendered.Insert(mcp.Spec.Configuration.Name, mcp.Status.Configuration.Name)
+	}
+	rendered.Delete("")
+
+	mcList := &mcfgv1.MachineConfigList{}
+	if err := g.client.List(context.TODO(), mcList); err != nil {
+		return err
+	}
+	for i := range mcList.Items {
+		mc := &mcList.Items[i]
+		if _, ok := mc.Labels[kataconfigurationv1.OwnerLabel]; !ok && !rendered.Has(mc.Name) {
+			continue
+		}
+		if err := g.write(filepath.Join("machineconfigs", mc.Name), mc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gatherPodLogs writes the logs of the operator, the daemons and the validation pods,
+// including the logs of the previous run of restarted containers
+func (g *gatherer) gatherPodLogs() error {
+	clientset, err := kubernetes.NewForConfig(g.config)
+	if err != nil {
+		return err
+	}
+
+	podList := &corev1.PodList{}
+	if err := g.client.List(context.TODO(), podList, client.InNamespace(g.namespace)); err != nil {
+		return err
+	}
+	if err := g.writeList(filepath.Join("pods", "pods"), podList); err != nil {
+		return err
+	}
+
+	for _, pod := range podList.Items {
+		for _, cs := range pod.Status.ContainerStatuses {
+			previous := []bool{false}
+			if cs.RestartCount > 0 {
+				previous = append(previous, true)
+			}
+			for _, p := range previous {
+				logs, err := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+					Container: cs.Name,
+					Previous:  p,
+				}).DoRaw(context.TODO())
+				if err != nil {
+					logs = []byte(fmt.Sprintf("unable to get the logs: %v\n", err))
+				}
+
+				name := pod.Name + "_" + cs.Name
+				if p {
+					name += "_previous"
+				}
+				if err := g.writeFile(filepath.Join("pods", name+".log"), logs); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// gatherNodeDiagnostics writes the diagnostics tarballs the daemons collected on failed nodes
+func (g *gatherer) gatherNodeDiagnostics() error {
+	cmList := &corev1.ConfigMapList{}
+	if err := g.client.List(context.TODO(), cmList, client.InNamespace(g.namespace),
+		client.HasLabels{kataconfigurationv1.OwnerLabel}); err != nil {
+		return err
+	}
+
+	for _, cm := range cmList.Items {
+		for key, data := range cm.BinaryData {
+			if err := g.writeFile(filepath.Join("diagnostics", cm.Name+"_"+key), data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeList writes the items of a list to a single YAML file
+func (g *gatherer) writeList(name string, list runtime.Object) error {
+	objs, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		if err := setKind(obj); err != nil {
+			return err
+		}
+	}
+	return g.writeYAML(name, map[string]interface{}{"items": objs})
+}
+
+func (g *gatherer) write(name string, obj runtime.Object) error {
+	if err := setKind(obj); err != nil {
+		return err
+	}
+	return g.writeYAML(name, obj)
+}
+
+func (g *gatherer) writeYAML(name string, v interface{}) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return g.writeFile(name+".yaml", data)
+}
+
+func (g *gatherer) writeFile(name string, data []byte) error {
+	path := filepath.Join(g.dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// setKind fills in the type meta the client leaves empty, so that the YAML files can be applied again
+func setKind(obj runtime.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	return nil
+}
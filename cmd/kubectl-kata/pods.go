@@ -0,0 +1,79 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	nodeapi "k8s.io/api/node/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// kataRuntimeHandler is the CRI-O runtime the kata runtime classes use
+const kataRuntimeHandler = "kata"
+
+func runPods(o *options, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("pods takes no arguments")
+	}
+
+	runtimeClasses, err := kataRuntimeClasses(o)
+	if err != nil {
+		return err
+	}
+	if runtimeClasses.Len() == 0 {
+		return fmt.Errorf("no kata runtime class found")
+	}
+
+	podList := &corev1.PodList{}
+	if err := o.client.List(context.TODO(), podList); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tRUNTIMECLASS\tNODE\tPHASE")
+	for _, pod := range podList.Items {
+		if pod.Spec.RuntimeClassName == nil || !runtimeClasses.Has(*pod.Spec.RuntimeClassName) {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", pod.Namespace, pod.Name, *pod.Spec.RuntimeClassName,
+			valueOr(pod.Spec.NodeName, "-"), pod.Status.Phase)
+	}
+	return w.Flush()
+}
+
+// kataRuntimeClasses returns the names of the runtime classes running pods with kata, the ones
+// created by the operator and any other using the kata handler
+func kataRuntimeClasses(o *options) (sets.String, error) {
+	rcList := &nodeapi.RuntimeClassList{}
+	if err := o.client.List(context.TODO(), rcList); err != nil {
+		return nil, err
+	}
+
+	names := sets.NewString()
+	for _, rc := range rcList.Items {
+		if _, ok := rc.Labels[kataconfigurationv1.OwnerLabel]; ok || rc.Handler == kataRuntimeHandler {
+			names.Insert(rc.Name)
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,93 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// runRetry puts the retry annotation on the nodes, the operator retries the nodes and removes the annotation
+func runRetry(o *options, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("retry needs the names of the nodes to retry")
+	}
+
+	for _, nodeName := range args {
+		node := &corev1.Node{}
+		if err := o.client.Get(context.TODO(), client.ObjectKey{Name: nodeName}, node); err != nil {
+			return err
+		}
+
+		patch := client.MergeFrom(node.DeepCopy())
+		annotations := node.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[kataconfigurationv1.RetryAnnotation] = "true"
+		node.SetAnnotations(annotations)
+		if err := o.client.Patch(context.TODO(), node, patch); err != nil {
+			return err
+		}
+		fmt.Printf("Requested a retry of node %s\n", nodeName)
+	}
+	return nil
+}
+
+func runPause(o *options, args []string) error {
+	return setRolloutPaused(o, args, true)
+}
+
+func runResume(o *options, args []string) error {
+	return setRolloutPaused(o, args, false)
+}
+
+// setRolloutPaused sets the paused flag of the rollout strategy
+func setRolloutPaused(o *options, args []string, paused bool) error {
+	if len(args) > 0 {
+		return fmt.Errorf("pause and resume take no arguments")
+	}
+
+	kc, err := o.kataConfig()
+	if err != nil {
+		return err
+	}
+	if kc.Spec.RolloutStrategy == nil {
+		return fmt.Errorf("KataConfig %s has no rollout strategy, kata is installed on all nodes at once", kc.Name)
+	}
+
+	action, state := "Resumed", "running"
+	if paused {
+		action, state = "Paused", "paused"
+	}
+	if kc.Spec.RolloutStrategy.Paused == paused {
+		fmt.Printf("The rollout of KataConfig %s is %s already\n", kc.Name, state)
+		return nil
+	}
+
+	patch := client.MergeFrom(kc.DeepCopy())
+	kc.Spec.RolloutStrategy.Paused = paused
+	if err := o.client.Patch(context.TODO(), kc, patch); err != nil {
+		return err
+	}
+	fmt.Printf("%s the rollout of KataConfig %s\n", action, kc.Name)
+	return nil
+}
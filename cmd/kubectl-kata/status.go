@@ -0,0 +1,155 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+)
+
+// Phases the operator records in its status lists rather than the daemon in the node status
+const (
+	phasePending         = "Pending"
+	phasePreflightFailed = "PreflightFailed"
+	phaseRolledBack      = "RolledBack"
+	phaseValidated       = "Validated"
+	phaseUninstalled     = "Uninstalled"
+)
+
+// nodeRow is a line of the status table
+type nodeRow struct {
+	name           string
+	phase          string
+	payloadVersion string
+	duration       string
+	err            string
+}
+
+func runStatus(o *options, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("status takes no arguments")
+	}
+
+	kataConfigs, err := o.kataConfigs()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for i := range kataConfigs {
+		kc := &kataConfigs[i]
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "KataConfig %s: pool %s, runtime class %s%s\n", kc.Name, valueOr(kc.Status.PoolName, "-"),
+			valueOr(kc.Status.RuntimeClass, "-"), rolloutSummary(kc))
+		fmt.Fprintln(w, "NODE\tPHASE\tPAYLOAD\tDURATION\tERROR")
+		for _, row := range nodeRows(kc, time.Now()) {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", row.name, row.phase, valueOr(row.payloadVersion, "-"),
+				valueOr(row.duration, "-"), row.err)
+		}
+	}
+	return w.Flush()
+}
+
+// nodeRows merges the node status reported by the daemons with the status lists of the operator
+func nodeRows(kc *kataconfigurationv1.KataConfig, now time.Time) []nodeRow {
+	status := &kc.Status
+	rows := map[string]*nodeRow{}
+	row := func(name string) *nodeRow {
+		if rows[name] == nil {
+			rows[name] = &nodeRow{name: name, phase: phasePending}
+		}
+		return rows[name]
+	}
+
+	for _, ns := range status.NodeStatus {
+		r := row(ns.Name)
+		r.phase = string(ns.Phase)
+		r.payloadVersion = ns.PayloadVersion
+		r.err = ns.Error
+		if ns.StartTime != nil {
+			end := now
+			if ns.CompletionTime != nil {
+				end = ns.CompletionTime.Time
+			}
+			r.duration = end.Sub(ns.StartTime.Time).Round(time.Second).String()
+		}
+	}
+
+	// The lists below are written after the phase the daemon reports, they take precedence
+	for _, n := range status.InstallationStatus.Completed.CompletedNodesList {
+		row(n).phase = string(kataconfigurationv1.NodePhaseInstalled)
+	}
+	for _, n := range status.ValidationStatus.ReadyNodesList {
+		row(n).phase = phaseValidated
+	}
+	setFailed := func(failed kataconfigurationv1.KataFailedNodeStatus, phase string) {
+		for _, fn := range failed.FailedNodesList {
+			if fn.Name == "" {
+				continue
+			}
+			r := row(fn.Name)
+			r.phase = phase
+			r.err = fn.Error
+		}
+	}
+	setFailed(status.PreflightStatus.Failed, phasePreflightFailed)
+	setFailed(status.InstallationStatus.Failed, string(kataconfigurationv1.NodePhaseFailed))
+	setFailed(status.ValidationStatus.Failed, string(kataconfigurationv1.NodePhaseFailed))
+	for _, n := range status.RollbackStatus.CompletedNodesList {
+		row(n).phase = phaseRolledBack
+	}
+	setFailed(status.RollbackStatus.Failed, string(kataconfigurationv1.NodePhaseFailed))
+	for _, n := range status.UnInstallationStatus.Completed.CompletedNodesList {
+		row(n).phase = phaseUninstalled
+	}
+	setFailed(status.UnInstallationStatus.Failed, string(kataconfigurationv1.NodePhaseFailed))
+
+	var sorted []nodeRow
+	for _, r := range rows {
+		r.err = strings.ReplaceAll(r.err, "\n", " ")
+		sorted = append(sorted, *r)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+	return sorted
+}
+
+// rolloutSummary describes the state of the rollout if a rollout strategy is used
+func rolloutSummary(kc *kataconfigurationv1.KataConfig) string {
+	if kc.Spec.RolloutStrategy == nil {
+		return ""
+	}
+	rollout := kc.Status.RolloutStatus
+	if rollout.Paused {
+		return fmt.Sprintf(", rollout paused (%s)", rollout.Reason)
+	}
+	return fmt.Sprintf(", rollout released %d nodes", len(rollout.ReleasedNodesList))
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
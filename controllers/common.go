@@ -1,8 +1,15 @@
 package controllers
 
 import (
+	"context"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // DaemonOperation represents the operation kata daemon is going to perform
@@ -30,6 +37,15 @@ func contains(list []string, s string) bool {
 	return false
 }
 
+func failedNodesContain(failed kataconfigurationv1.KataFailedNodeStatus, nodeName string) bool {
+	for _, fn := range failed.FailedNodesList {
+		if fn.Name == nodeName {
+			return true
+		}
+	}
+	return false
+}
+
 func getClientSet() (*kubernetes.Clientset, error) {
 	config, err := clientcmd.BuildConfigFromFlags("", "")
 	if err != nil {
@@ -43,3 +59,82 @@ func getClientSet() (*kubernetes.Clientset, error) {
 
 	return clientset, nil
 }
+
+// setNodeLabels adds the given labels to the node unless it already has them
+func setNodeLabels(c client.Client, nodeName string, labels map[string]string) error {
+	node := &corev1.Node{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: nodeName}, node); err != nil {
+		return err
+	}
+
+	patch := client.MergeFrom(node.DeepCopy())
+	nodeLabels := node.GetLabels()
+	if nodeLabels == nil {
+		nodeLabels = map[string]string{}
+	}
+	changed := false
+	for k, v := range labels {
+		if current, ok := nodeLabels[k]; !ok || current != v {
+			nodeLabels[k] = v
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	node.SetLabels(nodeLabels)
+
+	return c.Patch(context.TODO(), node, patch)
+}
+
+// removeNodeLabels removes the labels with the given keys from the node
+func removeNodeLabels(c client.Client, nodeName string, keys []string) error {
+	node := &corev1.Node{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: nodeName}, node); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	patch := client.MergeFrom(node.DeepCopy())
+	nodeLabels := node.GetLabels()
+	changed := false
+	for _, k := range keys {
+		if _, ok := nodeLabels[k]; ok {
+			delete(nodeLabels, k)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	node.SetLabels(nodeLabels)
+
+	return c.Patch(context.TODO(), node, patch)
+}
+
+// deleteDaemonPod deletes the pod the given daemonset runs on the node, the
+// daemonset controller then starts a new one that runs the daemon again
+func deleteDaemonPod(c client.Client, namespace string, dsName string, nodeName string) error {
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.InNamespace(namespace),
+		client.MatchingLabels{"name": dsName},
+	}
+	if err := c.List(context.TODO(), podList, listOpts...); err != nil {
+		return err
+	}
+
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Spec.NodeName != nodeName {
+			continue
+		}
+		if err := c.Delete(context.TODO(), pod); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
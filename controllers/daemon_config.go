@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// DefaultDaemonImage is the image of the daemon that installs kata on the nodes, used when
+	// neither the --daemon-image flag nor the RELATED_IMAGE_KATA_DAEMON environment variable is set
+	DefaultDaemonImage = "quay.io/isolatedcontainers/sandboxed-containers-operator-daemon@sha256:84df0ddc078c3dee27074d419f85dae715f8667c95e37ebf01fb7e45b083c721"
+
+	// DefaultOperatorNamespace is the namespace the operator and its daemonsets run in on OpenShift,
+	// unless the operator is told otherwise
+	DefaultOperatorNamespace = "sandboxed-containers-operator-system"
+
+	// DefaultDaemonServiceAccount is the service account the daemons run as, the operator creates it
+	// along with the roles of the daemon
+	DefaultDaemonServiceAccount = "sandboxed-containers-operator-daemon"
+
+	// DefaultDaemonLogLevel and DefaultDaemonLogFormat are the defaults of the daemon itself
+	DefaultDaemonLogLevel  = "info"
+	DefaultDaemonLogFormat = "console"
+
+	// payloadAuthDir is where the pull secret of the payload image is mounted in the daemon
+	payloadAuthDir = "/etc/kata-payload"
+	// payloadAuthFileName is the name of the pull secret file, in the format of a docker config.json
+	payloadAuthFileName = "auth.json"
+	// legacyPayloadSecret held the username and password for the payload image before pull secrets were
+	// supported. It is still read, so that clusters relying on it keep their credentials on upgrade.
+	legacyPayloadSecret = "payload-secret"
+)
+
+// DaemonConfig holds the settings of the pods the operator runs on the nodes, the installation
+// daemons and the validation pods. It is set up from the flags and the environment of the operator,
+// so that the daemon image can be mirrored and follows the version of the operator.
+type DaemonConfig struct {
+	// Image of the daemon
+	Image string
+	// ImagePullPolicy of the daemon image
+	ImagePullPolicy corev1.PullPolicy
+	// Namespace the daemonsets and the validation pods are created in
+	Namespace string
+	// ServiceAccount the daemon runs as, it is created by the operator
+	ServiceAccount string
+	// ImagePullSecrets are the secrets of the namespace used to pull the daemon image
+	ImagePullSecrets []string
+	// Tolerations of the daemon pods, on top of those needed to run on the kata nodes
+	Tolerations []corev1.Toleration
+	// PriorityClassName of the daemon pods
+	PriorityClassName string
+	// Resources requested by the daemon container
+	Resources corev1.ResourceRequirements
+	// LogLevel of the daemon, one of debug, info or error
+	LogLevel string
+	// LogFormat of the daemon, console or json
+	LogFormat string
+}
+
+// withDefaults returns the configuration with the unset fields set to their default
+func (c DaemonConfig) withDefaults() DaemonConfig {
+	if c.Image == "" {
+		c.Image = DefaultDaemonImage
+	}
+	if c.ImagePullPolicy == "" {
+		c.ImagePullPolicy = corev1.PullIfNotPresent
+	}
+	if c.Namespace == "" {
+		c.Namespace = DefaultOperatorNamespace
+	}
+	if c.ServiceAccount == "" {
+		c.ServiceAccount = DefaultDaemonServiceAccount
+	}
+	if c.LogLevel == "" {
+		c.LogLevel = DefaultDaemonLogLevel
+	}
+	if c.LogFormat == "" {
+		c.LogFormat = DefaultDaemonLogFormat
+	}
+	return c
+}
+
+// ValidateLogging checks the log level and format against those the daemon accepts, the daemon
+// would otherwise fail to start on every node
+func (c DaemonConfig) ValidateLogging() error {
+	switch c.LogLevel {
+	case "", "debug", "info", "error":
+	default:
+		return fmt.Errorf("invalid log level %q, expected debug, info or error", c.LogLevel)
+	}
+	switch c.LogFormat {
+	case "", "console", "json":
+	default:
+		return fmt.Errorf("invalid log format %q, expected console or json", c.LogFormat)
+	}
+	return nil
+}
+
+// imagePullSecrets returns the references to the pull secrets of the daemon image
+func (c DaemonConfig) imagePullSecrets() []corev1.LocalObjectReference {
+	var refs []corev1.LocalObjectReference
+	for _, name := range c.ImagePullSecrets {
+		refs = append(refs, corev1.LocalObjectReference{Name: name})
+	}
+	return refs
+}
+
+// ParseTolerations parses the tolerations given as a JSON list, in the format of a pod spec
+func ParseTolerations(value string) ([]corev1.Toleration, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var tolerations []corev1.Toleration
+	if err := json.Unmarshal([]byte(value), &tolerations); err != nil {
+		return nil, fmt.Errorf("invalid tolerations %q: %v", value, err)
+	}
+	return tolerations, nil
+}
+
+// ParseResourceList parses resources given as a comma separated list of name=quantity, e.g. cpu=10m,memory=50Mi
+func ParseResourceList(value string) (corev1.ResourceList, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	resources := corev1.ResourceList{}
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid resource %q, expected name=quantity", item)
+		}
+		quantity, err := resource.ParseQuantity(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity of resource %s: %v", parts[0], err)
+		}
+		resources[corev1.ResourceName(parts[0])] = quantity
+	}
+	return resources, nil
+}
+
+// ParseList parses a comma separated list, the empty items are dropped
+func ParseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
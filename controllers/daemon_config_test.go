@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseResourceList(t *testing.T) {
+	for _, test := range []struct {
+		value     string
+		resources corev1.ResourceList
+		valid     bool
+	}{
+		{"", nil, true},
+		{" ", nil, true},
+		{"cpu=10m", corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10m")}, true},
+		{"cpu=10m, memory=50Mi", corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("10m"),
+			corev1.ResourceMemory: resource.MustParse("50Mi"),
+		}, true},
+		{"cpu", nil, false},
+		{"=10m", nil, false},
+		{"memory=lots", nil, false},
+		{"cpu=10m,", nil, false},
+	} {
+		resources, err := ParseResourceList(test.value)
+		if (err == nil) != test.valid {
+			t.Errorf("ParseResourceList(%q): unexpected error %v", test.value, err)
+			continue
+		}
+		if len(resources) != len(test.resources) {
+			t.Errorf("ParseResourceList(%q) = %v, want %v", test.value, resources, test.resources)
+			continue
+		}
+		for name, quantity := range test.resources {
+			if parsed, ok := resources[name]; !ok || parsed.Cmp(quantity) != 0 {
+				t.Errorf("ParseResourceList(%q) = %v, want %v", test.value, resources, test.resources)
+			}
+		}
+	}
+}
+
+func TestParseTolerations(t *testing.T) {
+	for _, test := range []struct {
+		value       string
+		tolerations []corev1.Toleration
+		valid       bool
+	}{
+		{"", nil, true},
+		{`[{"key": "kata", "operator": "Exists", "effect": "NoSchedule"}]`, []corev1.Toleration{
+			{Key: "kata", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+		}, true},
+		{`[{"operator": "Exists"}, {"key": "dedicated", "value": "kata"}]`, []corev1.Toleration{
+			{Operator: corev1.TolerationOpExists},
+			{Key: "dedicated", Value: "kata"},
+		}, true},
+		{`{"key": "kata"}`, nil, false},
+		{`kata=true:NoSchedule`, nil, false},
+	} {
+		tolerations, err := ParseTolerations(test.value)
+		if (err == nil) != test.valid || !reflect.DeepEqual(tolerations, test.tolerations) {
+			t.Errorf("ParseTolerations(%q) = %v, %v, want %v", test.value, tolerations, err, test.tolerations)
+		}
+	}
+}
+
+func TestParseList(t *testing.T) {
+	for _, test := range []struct {
+		value string
+		items []string
+	}{
+		{"", nil},
+		{" , ", nil},
+		{"pull-secret", []string{"pull-secret"}},
+		{"pull-secret, mirror-secret,", []string{"pull-secret", "mirror-secret"}},
+	} {
+		if items := ParseList(test.value); !reflect.DeepEqual(items, test.items) {
+			t.Errorf("ParseList(%q) = %q, want %q", test.value, items, test.items)
+		}
+	}
+}
+
+func TestValidateLogging(t *testing.T) {
+	for _, test := range []struct {
+		level, format string
+		valid         bool
+	}{
+		{"", "", true},
+		{"debug", "json", true},
+		{"error", "console", true},
+		{"warn", "console", false},
+		{"info", "text", false},
+	} {
+		err := DaemonConfig{LogLevel: test.level, LogFormat: test.format}.ValidateLogging()
+		if (err == nil) != test.valid {
+			t.Errorf("ValidateLogging(%q, %q): unexpected error %v", test.level, test.format, err)
+		}
+	}
+}
+
+func TestDaemonLogging(t *testing.T) {
+	r := &KataConfigOpenShiftReconciler{
+		Daemon:     DaemonConfig{LogLevel: "debug", LogFormat: "json"},
+		kataConfig: &kataconfigurationv1.KataConfig{ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig"}},
+	}
+	command := func() string {
+		ds := r.processDaemonsetForCR(InstallOperation)
+		return ds.Spec.Template.Spec.Containers[0].Command[2]
+	}
+
+	if c := command(); c != "/daemon --resource example-kataconfig --operation install --log-level debug --log-format json" {
+		t.Errorf("got daemon command %q", c)
+	}
+	// Without configuration the daemon logs as it does by default
+	r.Daemon = DaemonConfig{}
+	if c := command(); c != "/daemon --resource example-kataconfig --operation install --log-level info --log-format console" {
+		t.Errorf("got daemon command %q", c)
+	}
+}
+
+func TestKubernetesDaemonConfig(t *testing.T) {
+	r := &KataConfigKubernetesReconciler{
+		Daemon: DaemonConfig{
+			Image:             "registry.example.com/daemon:latest",
+			Namespace:         "kata-operator",
+			ServiceAccount:    "kata-daemon",
+			ImagePullPolicy:   corev1.PullAlways,
+			ImagePullSecrets:  []string{"pull-secret"},
+			Tolerations:       []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}},
+			PriorityClassName: "system-node-critical",
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10m")},
+			},
+		},
+		kataConfig: &kataconfigurationv1.KataConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig"},
+			Spec: kataconfigurationv1.KataConfigSpec{
+				NodeTaint: &corev1.Taint{Key: "kata", Effect: corev1.TaintEffectNoSchedule},
+			},
+			Status: kataconfigurationv1.KataConfigStatus{KataImage: "quay.io/kata-containers/kata-deploy:2.1.0"},
+		},
+	}
+
+	ds := r.processDaemonset(InstallOperation)
+	if ds.Namespace != "kata-operator" || ds.Spec.Template.Spec.ServiceAccountName != "kata-daemon" {
+		t.Errorf("daemonset in namespace %s runs as %s", ds.Namespace, ds.Spec.Template.Spec.ServiceAccountName)
+	}
+	podSpec := ds.Spec.Template.Spec
+	container := podSpec.Containers[0]
+	if container.Image != r.kataConfig.Status.KataImage {
+		t.Errorf("daemonset runs %s instead of the kata-deploy image", container.Image)
+	}
+	if container.ImagePullPolicy != corev1.PullAlways || !reflect.DeepEqual(container.Resources, r.Daemon.Resources) {
+		t.Errorf("daemon container pulled %s with resources %+v", container.ImagePullPolicy, container.Resources)
+	}
+	if !reflect.DeepEqual(podSpec.ImagePullSecrets, []corev1.LocalObjectReference{{Name: "pull-secret"}}) ||
+		podSpec.PriorityClassName != "system-node-critical" {
+		t.Errorf("daemon pods pulled with %v have priority class %q", podSpec.ImagePullSecrets, podSpec.PriorityClassName)
+	}
+	// The configured tolerations come along with those needed on the kata nodes
+	tolerations := append(append([]corev1.Toleration{}, r.Daemon.Tolerations...), kataTolerations(r.kataConfig)...)
+	if len(tolerations) != 2 || !reflect.DeepEqual(podSpec.Tolerations, tolerations) {
+		t.Errorf("got tolerations %+v, want %+v", podSpec.Tolerations, tolerations)
+	}
+
+	// Without configuration the daemons run in the operator namespace as the daemon service account
+	r.Daemon = DaemonConfig{}
+	ds = r.processDaemonset(InstallOperation)
+	if ds.Namespace != DefaultOperatorNamespace || ds.Spec.Template.Spec.ServiceAccountName != DefaultDaemonServiceAccount {
+		t.Errorf("daemonset in namespace %s runs as %s", ds.Namespace, ds.Spec.Template.Spec.ServiceAccountName)
+	}
+	if policy := ds.Spec.Template.Spec.Containers[0].ImagePullPolicy; policy != corev1.PullIfNotPresent {
+		t.Errorf("got pull policy %s by default", policy)
+	}
+}
+
+func TestAddPayloadPullSecret(t *testing.T) {
+	env := func(podSpec *corev1.PodSpec) map[string]corev1.EnvVar {
+		vars := map[string]corev1.EnvVar{}
+		for _, v := range podSpec.Containers[0].Env {
+			vars[v.Name] = v
+		}
+		return vars
+	}
+	r := &KataConfigOpenShiftReconciler{kataConfig: &kataconfigurationv1.KataConfig{}}
+
+	// Clusters relying on the legacy secret keep their credentials
+	podSpec := &corev1.PodSpec{Containers: []corev1.Container{{}}}
+	r.addPayloadPullSecret(podSpec)
+	vars := env(podSpec)
+	for _, name := range []string{"PAYLOAD_REGISTRY_USERNAME", "PAYLOAD_REGISTRY_PASSWORD"} {
+		if v, ok := vars[name]; !ok || v.ValueFrom.SecretKeyRef.Name != legacyPayloadSecret || !*v.ValueFrom.SecretKeyRef.Optional {
+			t.Errorf("%s not taken from the optional legacy secret: %+v", name, v)
+		}
+	}
+	if _, ok := vars["PAYLOAD_AUTH_FILE"]; ok || len(podSpec.Volumes) != 0 {
+		t.Error("pull secret mounted without being configured")
+	}
+
+	r.kataConfig.Spec.Config.PullSecret = &corev1.LocalObjectReference{Name: "payload-pull-secret"}
+	podSpec = &corev1.PodSpec{Containers: []corev1.Container{{}}}
+	r.addPayloadPullSecret(podSpec)
+	vars = env(podSpec)
+	if _, ok := vars["PAYLOAD_REGISTRY_USERNAME"]; ok {
+		t.Error("legacy secret passed along with the pull secret")
+	}
+	if len(podSpec.Volumes) != 1 || podSpec.Volumes[0].Secret.SecretName != "payload-pull-secret" ||
+		vars["PAYLOAD_AUTH_FILE"].Value != payloadAuthDir+"/"+payloadAuthFileName {
+		t.Errorf("pull secret not mounted: %+v", podSpec)
+	}
+}
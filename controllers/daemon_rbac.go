@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// The operator grants the daemon what it needs, so it has to hold these permissions itself
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;update
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings;clusterroles;clusterrolebindings,verbs=get;list;watch;create;update
+// +kubebuilder:rbac:groups=security.openshift.io,resources=securitycontextconstraints,resourceNames=privileged,verbs=use
+
+// daemonClusterRules are the permissions of the daemon on cluster scoped objects. It reads the
+// KataConfig and reports the state of its node in the KataConfig status.
+var daemonClusterRules = []rbacv1.PolicyRule{
+	{
+		APIGroups: []string{kataconfigurationv1.GroupVersion.Group},
+		Resources: []string{"kataconfigs"},
+		Verbs:     []string{"get"},
+	},
+	{
+		APIGroups: []string{kataconfigurationv1.GroupVersion.Group},
+		Resources: []string{"kataconfigs/status"},
+		Verbs:     []string{"get", "update", "patch"},
+	},
+	{
+		// The payload matching the cluster version is installed
+		APIGroups:     []string{"config.openshift.io"},
+		Resources:     []string{"clusterversions"},
+		ResourceNames: []string{"version"},
+		Verbs:         []string{"get"},
+	},
+}
+
+// daemonNamespaceRules are the permissions of the daemon in its namespace. It keeps the
+// diagnostics of a failed node in a ConfigMap and it runs privileged.
+var daemonNamespaceRules = []rbacv1.PolicyRule{
+	{
+		APIGroups: []string{""},
+		Resources: []string{"configmaps"},
+		Verbs:     []string{"get", "create", "update"},
+	},
+	{
+		APIGroups:     []string{"security.openshift.io"},
+		Resources:     []string{"securitycontextconstraints"},
+		ResourceNames: []string{"privileged"},
+		Verbs:         []string{"use"},
+	},
+}
+
+// kataDeployClusterRules are the permissions of kata-deploy, which installs kata on Kubernetes.
+// It labels its node once kata is installed there.
+var kataDeployClusterRules = []rbacv1.PolicyRule{
+	{
+		APIGroups: []string{""},
+		Resources: []string{"nodes"},
+		Verbs:     []string{"get", "patch"},
+	},
+}
+
+func (r *KataConfigOpenShiftReconciler) reconcileDaemonRBAC() error {
+	return reconcileDaemonRBAC(r.Client, r.Log, r.daemonConfig(), daemonClusterRules)
+}
+
+func (r *KataConfigKubernetesReconciler) reconcileDaemonRBAC() error {
+	return reconcileDaemonRBAC(r.Client, r.Log, r.daemonConfig(), kataDeployClusterRules)
+}
+
+// reconcileDaemonRBAC creates the service account of the daemon along with its roles and puts them
+// back if they were changed. They are shared by the daemons of all KataConfigs, so they have no owner.
+func reconcileDaemonRBAC(c client.Client, log logr.Logger, daemon DaemonConfig, clusterRules []rbacv1.PolicyRule) error {
+	name := daemon.ServiceAccount
+	subjects := []rbacv1.Subject{
+		{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      name,
+			Namespace: daemon.Namespace,
+		},
+	}
+
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: daemon.Namespace}}
+	clusterRole := &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	clusterRoleBinding := &rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	role := &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: daemon.Namespace}}
+	roleBinding := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: daemon.Namespace}}
+
+	for _, o := range []struct {
+		kind   string
+		obj    runtime.Object
+		mutate controllerutil.MutateFn
+	}{
+		{"ServiceAccount", sa, func() error { return nil }},
+		{"ClusterRole", clusterRole, func() error {
+			clusterRole.Rules = clusterRules
+			return nil
+		}},
+		{"ClusterRoleBinding", clusterRoleBinding, func() error {
+			clusterRoleBinding.RoleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: name}
+			clusterRoleBinding.Subjects = subjects
+			return nil
+		}},
+		{"Role", role, func() error {
+			role.Rules = daemonNamespaceRules
+			return nil
+		}},
+		{"RoleBinding", roleBinding, func() error {
+			roleBinding.RoleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: name}
+			roleBinding.Subjects = subjects
+			return nil
+		}},
+	} {
+		result, err := controllerutil.CreateOrUpdate(context.TODO(), c, o.obj, o.mutate)
+		if err != nil {
+			return err
+		}
+		if result != controllerutil.OperationResultNone {
+			log.Info("Reconciled the daemon RBAC", "kind", o.kind, "name", name, "result", result)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	nodeapi "k8s.io/api/node/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// kataConfigDriftCondition is true if objects created for the KataConfig had been
+	// changed or deleted and got restored recently
+	kataConfigDriftCondition = "DriftDetected"
+
+	// driftReportDuration is how long the drift condition keeps reporting the restored objects
+	// unless the KataConfig changes, the reconciles following a restore have nothing left to restore
+	driftReportDuration = 10 * time.Minute
+)
+
+// reconcileDrift compares the objects created for the KataConfig with their desired state,
+// restores the ones that were changed or deleted and reports the outcome in the drift condition
+func (r *KataConfigOpenShiftReconciler) reconcileDrift() error {
+	var restored []string
+	for _, restore := range []func() (string, error){
+		r.restoreInstallDaemonset,
+		r.restoreMachineConfigPool,
+		r.restoreMachineConfig,
+		r.restoreRuntimeClass,
+	} {
+		obj, err := restore()
+		if err != nil {
+			return err
+		}
+		if obj != "" {
+			restored = append(restored, obj)
+		}
+	}
+
+	now := metav1.Now()
+	current := meta.FindStatusCondition(r.kataConfig.Status.Conditions, kataConfigDriftCondition)
+	condition := metav1.Condition{
+		Type:               kataConfigDriftCondition,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: r.kataConfig.Generation,
+		Reason:             "InSync",
+		Message:            "All objects match their desired state",
+	}
+	if len(restored) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "ObjectsRestored"
+		condition.Message = "Restored " + strings.Join(restored, ", ") + " at " + now.UTC().Format(time.RFC3339)
+	} else if current != nil && current.Status == metav1.ConditionTrue &&
+		current.ObservedGeneration == r.kataConfig.Generation &&
+		now.Sub(current.LastTransitionTime.Time) < driftReportDuration {
+		return nil
+	} else if current != nil && current.Status == condition.Status && current.Message == condition.Message &&
+		current.ObservedGeneration == condition.ObservedGeneration {
+		return nil
+	}
+
+	meta.SetStatusCondition(&r.kataConfig.Status.Conditions, condition)
+	if len(restored) > 0 {
+		// The report lasts from the last restore, not from the first one
+		meta.FindStatusCondition(r.kataConfig.Status.Conditions, kataConfigDriftCondition).LastTransitionTime = now
+	}
+	return r.Client.Status().Update(context.TODO(), r.kataConfig)
+}
+
+// restoreInstallDaemonset updates the installation daemonset if it exists, it is deleted on purpose once kata is installed
+func (r *KataConfigOpenShiftReconciler) restoreInstallDaemonset() (string, error) {
+	ds := r.processDaemonsetForCR(InstallOperation)
+	foundDs := &appsv1.DaemonSet{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace}, foundDs)
+	if err != nil && errors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	// Fields left empty in the desired spec are defaulted by the API server, they don't count as drift
+	if equality.Semantic.DeepDerivative(ds.Spec, foundDs.Spec) {
+		return "", nil
+	}
+
+	r.Log.Info("Restoring the installation daemonset", "ds.Name", ds.Name)
+	foundDs.Spec = ds.Spec
+	if err := r.Client.Update(context.TODO(), foundDs); err != nil {
+		return "", err
+	}
+	return "DaemonSet " + ds.Name, nil
+}
+
+// restoreMachineConfigPool restores the kata pool, it is recreated once the runtime class exists
+func (r *KataConfigOpenShiftReconciler) restoreMachineConfigPool() (string, error) {
+	machinePool, err := r.workerOrMaster()
+	if err != nil {
+		return "", err
+	}
+	if r.usesParentPool(machinePool) {
+		return "", nil
+	}
+
+	mcp := r.newMCPforCR()
+	foundMcp := &mcfgv1.MachineConfigPool{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: mcp.Name}, foundMcp)
+	if err != nil && errors.IsNotFound(err) {
+		if r.kataConfig.Status.RuntimeClass == "" {
+			return "", nil
+		}
+		if err := controllerutil.SetControllerReference(r.kataConfig, mcp, r.Scheme); err != nil {
+			return "", err
+		}
+		r.Log.Info("Recreating the Machine Config Pool", "mcp.Name", mcp.Name)
+		if err := r.Client.Create(context.TODO(), mcp); err != nil {
+			return "", err
+		}
+		return "MachineConfigPool " + mcp.Name, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	// The node selector also changes on purpose if the excluded nodes change
+	if equality.Semantic.DeepEqual(foundMcp.Spec.NodeSelector, mcp.Spec.NodeSelector) &&
+		equality.Semantic.DeepEqual(foundMcp.Spec.MachineConfigSelector, mcp.Spec.MachineConfigSelector) {
+		return "", nil
+	}
+
+	r.Log.Info("Restoring the Machine Config Pool", "mcp.Name", mcp.Name)
+	foundMcp.Spec.NodeSelector = mcp.Spec.NodeSelector
+	foundMcp.Spec.MachineConfigSelector = mcp.Spec.MachineConfigSelector
+	if err := r.Client.Update(context.TODO(), foundMcp); err != nil {
+		return "", err
+	}
+	return "MachineConfigPool " + mcp.Name, nil
+}
+
+// restoreMachineConfig restores the CRI-O configuration for kata, it is recreated once the runtime class exists
+func (r *KataConfigOpenShiftReconciler) restoreMachineConfig() (string, error) {
+	machinePool, err := r.workerOrMaster()
+	if err != nil {
+		return "", err
+	}
+	mc, err := r.newMCForCR(machinePool)
+	if err != nil {
+		return "", err
+	}
+
+	foundMc := &mcfgv1.MachineConfig{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: mc.Name}, foundMc)
+	if err != nil && errors.IsNotFound(err) {
+		if r.kataConfig.Status.RuntimeClass == "" {
+			return "", nil
+		}
+		if err := controllerutil.SetControllerReference(r.kataConfig, mc, r.Scheme); err != nil {
+			return "", err
+		}
+		r.Log.Info("Recreating the Machine Config", "mc.Name", mc.Name)
+		if err := r.Client.Create(context.TODO(), mc); err != nil {
+			return "", err
+		}
+		return "MachineConfig " + mc.Name, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	sameConfig, err := sameRawConfig(foundMc.Spec.Config.Raw, mc.Spec.Config.Raw)
+	if err != nil {
+		return "", err
+	}
+	roleLabel := "machineconfiguration.openshift.io/role"
+	if sameConfig && foundMc.Labels[roleLabel] == mc.Labels[roleLabel] {
+		return "", nil
+	}
+
+	r.Log.Info("Restoring the Machine Config", "mc.Name", mc.Name)
+	foundMc.Spec = mc.Spec
+	if foundMc.Labels == nil {
+		foundMc.Labels = map[string]string{}
+	}
+	for k, v := range mc.Labels {
+		foundMc.Labels[k] = v
+	}
+	if err := r.Client.Update(context.TODO(), foundMc); err != nil {
+		return "", err
+	}
+	return "MachineConfig " + mc.Name, nil
+}
+
+// sameRawConfig compares two ignition configs, the API server doesn't keep the formatting of the raw config
+func sameRawConfig(a, b []byte) (bool, error) {
+	var ca, cb interface{}
+	if len(a) > 0 {
+		// A config that got broken is drift as well
+		if err := json.Unmarshal(a, &ca); err != nil {
+			return false, nil
+		}
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &cb); err != nil {
+			return false, err
+		}
+	}
+	return reflect.DeepEqual(ca, cb), nil
+}
+
+// restoreRuntimeClass restores the runtime class once it has been created
+func (r *KataConfigOpenShiftReconciler) restoreRuntimeClass() (string, error) {
+	if r.kataConfig.Status.RuntimeClass == "" {
+		return "", nil
+	}
+
+	rc := r.newRuntimeClassForCR()
+	if err := controllerutil.SetControllerReference(r.kataConfig, rc, r.Scheme); err != nil {
+		return "", err
+	}
+
+	foundRc := &nodeapi.RuntimeClass{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: rc.Name}, foundRc)
+	if err != nil && errors.IsNotFound(err) {
+		r.Log.Info("Recreating the RuntimeClass", "rc.Name", rc.Name)
+		if err := r.Client.Create(context.TODO(), rc); err != nil {
+			return "", err
+		}
+		return "RuntimeClass " + rc.Name, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	if foundRc.Handler == rc.Handler &&
+		equality.Semantic.DeepEqual(foundRc.Overhead, rc.Overhead) &&
+		equality.Semantic.DeepEqual(foundRc.Scheduling, rc.Scheduling) {
+		return "", nil
+	}
+
+	// The handler can't be changed, the runtime class has to be recreated then
+	if foundRc.Handler != rc.Handler {
+		r.Log.Info("Recreating the RuntimeClass with the kata handler", "rc.Name", rc.Name)
+		if err := r.Client.Delete(context.TODO(), foundRc); err != nil && !errors.IsNotFound(err) {
+			return "", err
+		}
+		if err := r.Client.Create(context.TODO(), rc); err != nil {
+			return "", err
+		}
+		return "RuntimeClass " + rc.Name, nil
+	}
+
+	r.Log.Info("Restoring the RuntimeClass", "rc.Name", rc.Name)
+	foundRc.Overhead = rc.Overhead
+	foundRc.Scheduling = rc.Scheduling
+	if err := r.Client.Update(context.TODO(), foundRc); err != nil {
+		return "", err
+	}
+	return "RuntimeClass " + rc.Name, nil
+}
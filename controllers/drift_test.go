@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileDrift(t *testing.T) {
+	scheme := runtime.NewScheme()
+	for _, addToScheme := range []func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme,
+		kataconfigurationv1.AddToScheme,
+		mcfgv1.Install,
+	} {
+		if err := addToScheme(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	kataConfig := &kataconfigurationv1.KataConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig", Generation: 1},
+		Status: kataconfigurationv1.KataConfigStatus{
+			Topology: kataconfigurationv1.KataTopologyStatus{MachinePool: "worker"},
+		},
+	}
+	r := &KataConfigOpenShiftReconciler{Log: ctrl.Log.WithName("test"), Scheme: scheme, kataConfig: kataConfig}
+
+	// Someone changed the image of the installation daemonset
+	ds := r.processDaemonsetForCR(InstallOperation)
+	ds.Spec.Template.Spec.Containers[0].Image = "registry.example.com/daemon:other"
+	r.Client = fake.NewFakeClientWithScheme(scheme, kataConfig, ds)
+
+	reconcile := func(step string, status metav1.ConditionStatus, reason string) *metav1.Condition {
+		t.Helper()
+		if err := r.reconcileDrift(); err != nil {
+			t.Fatal(err)
+		}
+		condition := meta.FindStatusCondition(kataConfig.Status.Conditions, kataConfigDriftCondition)
+		if condition == nil || condition.Status != status || condition.Reason != reason {
+			t.Fatalf("%s: got condition %+v, want %s", step, condition, reason)
+		}
+		return condition
+	}
+
+	restored := reconcile("restore", metav1.ConditionTrue, "ObjectsRestored")
+	message := restored.Message
+	foundDs := &appsv1.DaemonSet{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace}, foundDs); err != nil {
+		t.Fatal(err)
+	}
+	if foundDs.Spec.Template.Spec.Containers[0].Image == "registry.example.com/daemon:other" {
+		t.Error("installation daemonset not restored")
+	}
+
+	// The reconcile triggered by the restore finds nothing to restore, the report is kept
+	if condition := reconcile("next reconcile", metav1.ConditionTrue, "ObjectsRestored"); condition.Message != message {
+		t.Errorf("got message %q, want %q", condition.Message, message)
+	}
+
+	// The report is dropped once it is old enough
+	restored = meta.FindStatusCondition(kataConfig.Status.Conditions, kataConfigDriftCondition)
+	restored.LastTransitionTime = metav1.NewTime(time.Now().Add(-driftReportDuration))
+	reconcile("expired", metav1.ConditionFalse, "InSync")
+
+	// or once the KataConfig changed
+	foundDs.Spec.Template.Spec.Containers[0].Image = "registry.example.com/daemon:other"
+	if err := r.Client.Update(context.TODO(), foundDs); err != nil {
+		t.Fatal(err)
+	}
+	reconcile("restore again", metav1.ConditionTrue, "ObjectsRestored")
+	kataConfig.Generation++
+	reconcile("spec changed", metav1.ConditionFalse, "InSync")
+}
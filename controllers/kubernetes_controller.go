@@ -19,6 +19,7 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -43,11 +44,16 @@ import (
 
 // var _ reconcile.Reconciler = &KataConfigKubernetesReconciler{}
 
+// kubernetesRuntimeClassNames are the runtime classes of the handlers kata-deploy configures
+var kubernetesRuntimeClassNames = []string{"kata-qemu-virtiofs", "kata-qemu", "kata-clh", "kata-fc", "kata"}
+
 // KataConfigKubernetesReconciler reconciles a KataConfig object in Kubernetes cluster
 type KataConfigKubernetesReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+	// Daemon configures the namespace and the service account of kata-deploy and the validation pods
+	Daemon DaemonConfig
 
 	clientset  kubernetes.Interface
 	kataConfig *kataconfigurationv1.KataConfig
@@ -81,10 +87,42 @@ func (r *KataConfigKubernetesReconciler) Reconcile(req ctrl.Request) (ctrl.Resul
 	return r.processKataConfigInstallRequest()
 }
 
+// processKataConfigDeleteRequest takes the taint and the labels off the kata nodes before the KataConfig goes away.
+// kata itself is removed from the nodes by the preStop hook of kata-deploy when its daemonset is deleted.
 func (r *KataConfigKubernetesReconciler) processKataConfigDeleteRequest() (ctrl.Result, error) {
+	if !contains(r.kataConfig.GetFinalizers(), kataConfigFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	installed := r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesList
+	if err := removeNodeTaints(r.Client, installed); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := removeKataNodeLabels(r.Client, installed); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	r.Log.Info("Removed the taints and the labels of the kata nodes. Proceeding with the KataConfig deletion")
+	controllerutil.RemoveFinalizer(r.kataConfig, kataConfigFinalizer)
+	if err := r.Client.Update(context.TODO(), r.kataConfig); err != nil {
+		return ctrl.Result{}, err
+	}
 	return ctrl.Result{}, nil
 }
 
+func (r *KataConfigKubernetesReconciler) addFinalizer() error {
+	r.Log.Info("Adding Finalizer for the KataConfig")
+	controllerutil.AddFinalizer(r.kataConfig, kataConfigFinalizer)
+
+	// Update CR
+	err := r.Client.Update(context.TODO(), r.kataConfig)
+	if err != nil {
+		r.Log.Error(err, "Failed to update KataConfig with finalizer")
+		return err
+	}
+	return nil
+}
+
 func (r *KataConfigKubernetesReconciler) processKataConfigInstallRequest() (ctrl.Result, error) {
 	if r.kataConfig.Status.TotalNodesCount == 0 {
 
@@ -130,6 +168,17 @@ func (r *KataConfigKubernetesReconciler) processKataConfigInstallRequest() (ctrl
 	// Don't create the daemonset if kata is already installed on the cluster nodes
 	if r.kataConfig.Status.TotalNodesCount > 0 &&
 		r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesCount != r.kataConfig.Status.TotalNodesCount {
+		if r.kataConfig.Spec.RolloutStrategy != nil {
+			if err := r.updateRollout(); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		// kata-deploy runs as the daemon service account
+		if err := r.reconcileDaemonRBAC(); err != nil {
+			return ctrl.Result{}, err
+		}
+
 		ds := r.processDaemonset(InstallOperation)
 		// Set KataConfig instance as the owner and controller
 		if err := controllerutil.SetControllerReference(r.kataConfig, ds, r.Scheme); err != nil {
@@ -150,16 +199,130 @@ func (r *KataConfigKubernetesReconciler) processKataConfigInstallRequest() (ctrl
 		return r.monitorKataConfigInstallation()
 	}
 
-	// Add finalizer for this CR
-	// if !contains(r.kataConfig.GetFinalizers(), kataConfigFinalizer) {
-	// 	if err := r.addFinalizer(); err != nil {
-	// 		return ctrl.Result{}, err
-	// 	}
-	// }
+	// The finalizer removes the taints and the labels once the KataConfig is deleted
+	if !contains(r.kataConfig.GetFinalizers(), kataConfigFinalizer) {
+		if err := r.addFinalizer(); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Keep everything but sandboxed workloads off the nodes kata got installed on
+	if err := reconcileNodeTaints(r.Client, r.kataConfig, r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesList); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Let schedulers and tooling find the kata nodes by their labels
+	if err := r.reconcileNodeLabels(); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Make sure a kata pod can actually be started on every node kata got installed on
+	if r.kataConfig.Status.RuntimeClass != "" {
+		validator := &kataNodeValidator{
+			client:           r.Client,
+			scheme:           r.Scheme,
+			log:              r.Log,
+			kataConfig:       r.kataConfig,
+			namespace:        r.daemonConfig().Namespace,
+			image:            r.daemonConfig().Image,
+			pullSecrets:      r.daemonConfig().imagePullSecrets(),
+			runtimeClassName: "kata",
+		}
+
+		done, err := validator.validate(r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesList)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !done {
+			return ctrl.Result{Requeue: true, RequeueAfter: 30 * time.Second}, nil
+		}
+	}
 
 	return ctrl.Result{}, nil
 }
 
+// updateRollout releases the next batch of nodes to the installation daemonset. A node is done
+// once the daemon labeled it with the kata runtime label, it failed if its kata-deploy pod can't run
+// or the validation pod failed on it.
+func (r *KataConfigKubernetesReconciler) updateRollout() error {
+	nodesList := &corev1.NodeList{}
+	if err := r.Client.List(context.TODO(), nodesList, client.MatchingLabels(r.kataNodeSelector())); err != nil {
+		return err
+	}
+
+	var doneNodes []string
+	for _, node := range nodesList.Items {
+		if node.GetLabels()["katacontainers.io/kata-runtime"] == "true" {
+			doneNodes = append(doneNodes, node.Name)
+		}
+	}
+
+	failedNodes, err := r.failedInstallNodes()
+	if err != nil {
+		return err
+	}
+	for _, fn := range r.kataConfig.Status.ValidationStatus.Failed.FailedNodesList {
+		if !contains(failedNodes, fn.Name) {
+			failedNodes = append(failedNodes, fn.Name)
+		}
+	}
+
+	rollout := &kataRollout{
+		client:     r.Client,
+		log:        r.Log,
+		kataConfig: r.kataConfig,
+	}
+	return rollout.update(nodesList.Items, doneNodes, failedNodes)
+}
+
+// kataNodeSelector returns the node selector of the KataConfig, the worker nodes if none is set
+func (r *KataConfigKubernetesReconciler) kataNodeSelector() map[string]string {
+	if r.kataConfig.Spec.KataConfigPoolSelector != nil {
+		return r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels
+	}
+	return map[string]string{"node-role.kubernetes.io/worker": ""}
+}
+
+// failedInstallNodes returns the nodes the kata-deploy pod of the installation daemonset fails on
+func (r *KataConfigKubernetesReconciler) failedInstallNodes() ([]string, error) {
+	ds := r.processDaemonset(InstallOperation)
+	podList := &corev1.PodList{}
+	listOpts := []client.ListOption{
+		client.InNamespace(ds.Namespace),
+		client.MatchingLabels(ds.Spec.Selector.MatchLabels),
+	}
+	if err := r.Client.List(context.TODO(), podList, listOpts...); err != nil {
+		return nil, err
+	}
+
+	var failedNodes []string
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Spec.NodeName != "" && kataDeployFailed(pod) && !contains(failedNodes, pod.Spec.NodeName) {
+			failedNodes = append(failedNodes, pod.Spec.NodeName)
+		}
+	}
+	sort.Strings(failedNodes)
+	return failedNodes, nil
+}
+
+// kataDeployFailed returns true if the kata-deploy pod failed or keeps failing to start
+func kataDeployFailed(pod *corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodFailed {
+		return true
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Waiting == nil {
+			continue
+		}
+		switch cs.State.Waiting.Reason {
+		case "CrashLoopBackOff", "ImagePullBackOff", "ErrImagePull", "CreateContainerError", "CreateContainerConfigError":
+			return true
+		}
+	}
+	return false
+}
+
 func (r *KataConfigKubernetesReconciler) monitorKataConfigInstallation() (ctrl.Result, error) {
 	// If the installation of the binaries is successful on all nodes, proceed with creating the runtime classes
 	if r.kataConfig.Status.TotalNodesCount > 0 && r.kataConfig.Status.InstallationStatus.InProgress.InProgressNodesCount == r.kataConfig.Status.TotalNodesCount {
@@ -221,9 +384,7 @@ func (r *KataConfigKubernetesReconciler) monitorKataConfigInstallation() (ctrl.R
 }
 
 func (r *KataConfigKubernetesReconciler) setRuntimeClass() (ctrl.Result, error) {
-	runtimeClassNames := []string{"kata-qemu-virtiofs", "kata-qemu", "kata-clh", "kata-fc", "kata"}
-
-	for _, runtimeClassName := range runtimeClassNames {
+	for _, runtimeClassName := range kubernetesRuntimeClassNames {
 		rc := func() *nodeapi.RuntimeClass {
 			rc := &nodeapi.RuntimeClass{
 				TypeMeta: metav1.TypeMeta{
@@ -232,6 +393,9 @@ func (r *KataConfigKubernetesReconciler) setRuntimeClass() (ctrl.Result, error)
 				},
 				ObjectMeta: metav1.ObjectMeta{
 					Name: runtimeClassName,
+					Labels: map[string]string{
+						kataConfigOwnerLabel: r.kataConfig.Name,
+					},
 				},
 				Handler: runtimeClassName,
 			}
@@ -241,6 +405,12 @@ func (r *KataConfigKubernetesReconciler) setRuntimeClass() (ctrl.Result, error)
 					NodeSelector: r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels,
 				}
 			}
+			if tolerations := kataTolerations(r.kataConfig); tolerations != nil {
+				if rc.Scheduling == nil {
+					rc.Scheduling = &nodeapi.Scheduling{}
+				}
+				rc.Scheduling.Tolerations = tolerations
+			}
 			return rc
 		}()
 
@@ -261,7 +431,7 @@ func (r *KataConfigKubernetesReconciler) setRuntimeClass() (ctrl.Result, error)
 
 	}
 
-	r.kataConfig.Status.RuntimeClass = strings.Join(runtimeClassNames, ",")
+	r.kataConfig.Status.RuntimeClass = strings.Join(kubernetesRuntimeClassNames, ",")
 	err := r.Client.Status().Update(context.TODO(), r.kataConfig)
 	if err != nil {
 		return ctrl.Result{}, err
@@ -270,6 +440,10 @@ func (r *KataConfigKubernetesReconciler) setRuntimeClass() (ctrl.Result, error)
 	return ctrl.Result{}, nil
 }
 
+func (r *KataConfigKubernetesReconciler) daemonConfig() DaemonConfig {
+	return r.Daemon.withDefaults()
+}
+
 func (r *KataConfigKubernetesReconciler) processDaemonset(operation DaemonOperation) *appsv1.DaemonSet {
 	runPrivileged := true
 	var runAsUser int64 = 0
@@ -288,7 +462,9 @@ func (r *KataConfigKubernetesReconciler) processDaemonset(operation DaemonOperat
 			"node-role.kubernetes.io/worker": "",
 		}
 	}
+	nodeSelector = withRolloutLabel(r.kataConfig, nodeSelector, kataRolloutReleasedLabel)
 
+	daemon := r.daemonConfig()
 	return &appsv1.DaemonSet{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "apps/v1",
@@ -296,7 +472,10 @@ func (r *KataConfigKubernetesReconciler) processDaemonset(operation DaemonOperat
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      dsName,
-			Namespace: "sandboxed-containers-operator",
+			Namespace: daemon.Namespace,
+			Labels: map[string]string{
+				kataConfigOwnerLabel: r.kataConfig.Name,
+			},
 		},
 		Spec: appsv1.DaemonSetSpec{
 			Selector: &metav1.LabelSelector{
@@ -316,13 +495,17 @@ func (r *KataConfigKubernetesReconciler) processDaemonset(operation DaemonOperat
 					Labels: labels,
 				},
 				Spec: corev1.PodSpec{
-					ServiceAccountName: "sandboxed-containers-operator",
+					ServiceAccountName: daemon.ServiceAccount,
+					ImagePullSecrets:   daemon.imagePullSecrets(),
 					NodeSelector:       nodeSelector,
+					Tolerations:        append(append([]corev1.Toleration{}, daemon.Tolerations...), kataTolerations(r.kataConfig)...),
+					PriorityClassName:  daemon.PriorityClassName,
 					Containers: []corev1.Container{
 						{
 							Name:            "kata-install-pod",
 							Image:           r.kataConfig.Status.KataImage,
-							ImagePullPolicy: "Always",
+							ImagePullPolicy: daemon.ImagePullPolicy,
+							Resources:       daemon.Resources,
 							Lifecycle: &corev1.Lifecycle{
 								PreStop: &corev1.Handler{
 									Exec: &corev1.ExecAction{
@@ -435,6 +618,7 @@ func (r *KataConfigKubernetesReconciler) SetupWithManager(mgr ctrl.Manager) erro
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kataconfigurationv1.KataConfig{}).
 		Owns(&appsv1.DaemonSet{}).
+		Owns(&corev1.Pod{}).
 		Watches(&source.Kind{Type: &corev1.Node{}}, &handler.EnqueueRequestsFromMapFunc{
 			ToRequests: handler.ToRequestsFunc(func(kataConfigObj handler.MapObject) []reconcile.Request {
 				kataConfigList := &kataconfigurationv1.KataConfigList{}
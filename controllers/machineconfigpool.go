@@ -0,0 +1,247 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// kataConfigPoolDegradedCondition is true if the machine config pool of the kata nodes
+	// reports the Degraded or NodeDegraded condition
+	kataConfigPoolDegradedCondition = "MachineConfigPoolDegraded"
+)
+
+// reportedMachineConfigPool returns the pool whose status is reported in the KataConfig, the kata
+// pool once it exists and the parent pool otherwise. It returns nil if there is no pool at all.
+func (r *KataConfigOpenShiftReconciler) reportedMachineConfigPool() (*mcfgv1.MachineConfigPool, error) {
+	mcp := &mcfgv1.MachineConfigPool{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: r.poolName()}, mcp)
+	if err == nil {
+		return mcp, nil
+	} else if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	machinePool, err := r.workerOrMaster()
+	if err != nil && errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: machinePool}, mcp)
+	if err != nil && errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return mcp, nil
+}
+
+// reportMachineConfigPoolStatus copies the machine counts, the degraded conditions and
+// the degraded nodes of the machine config pool into the KataConfig status
+func (r *KataConfigOpenShiftReconciler) reportMachineConfigPoolStatus() error {
+	mcp, err := r.reportedMachineConfigPool()
+	if err != nil {
+		return err
+	}
+
+	status := &r.kataConfig.Status
+	poolStatus := kataconfigurationv1.KataMachineConfigPoolStatus{}
+	conditions := append([]metav1.Condition{}, status.Conditions...)
+	if mcp == nil {
+		meta.RemoveStatusCondition(&conditions, kataConfigPoolDegradedCondition)
+	} else {
+		poolStatus.Name = mcp.Name
+		poolStatus.MachineCount = mcp.Status.MachineCount
+		poolStatus.ReadyMachineCount = mcp.Status.ReadyMachineCount
+		poolStatus.UpdatedMachineCount = mcp.Status.UpdatedMachineCount
+		poolStatus.DegradedMachineCount = mcp.Status.DegradedMachineCount
+		poolStatus.DegradedNodesList, err = r.degradedNodes(mcp)
+		if err != nil {
+			return err
+		}
+
+		condition := metav1.Condition{
+			Type:               kataConfigPoolDegradedCondition,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: r.kataConfig.Generation,
+			Reason:             "PoolNotDegraded",
+			Message:            fmt.Sprintf("Machine config pool %s is not degraded", mcp.Name),
+		}
+		for _, t := range []mcfgv1.MachineConfigPoolConditionType{mcfgv1.MachineConfigPoolNodeDegraded, mcfgv1.MachineConfigPoolDegraded} {
+			if c := mcfgv1.GetMachineConfigPoolCondition(mcp.Status, t); c != nil && c.Status == corev1.ConditionTrue {
+				condition.Status = metav1.ConditionTrue
+				condition.Reason = string(t)
+				condition.Message = fmt.Sprintf("Machine config pool %s is degraded: %s", mcp.Name, c.Message)
+				break
+			}
+		}
+		meta.SetStatusCondition(&conditions, condition)
+	}
+
+	if equality.Semantic.DeepEqual(status.MachineConfigPoolStatus, poolStatus) &&
+		sameConditions(status.Conditions, conditions) {
+		return nil
+	}
+
+	if poolStatus.DegradedMachineCount > 0 {
+		r.Log.Info("Machine config pool is degraded", "mcp.Name", poolStatus.Name, "degraded nodes", poolStatus.DegradedNodesList)
+	}
+	status.MachineConfigPoolStatus = poolStatus
+	status.Conditions = conditions
+	return r.Client.Status().Update(context.TODO(), r.kataConfig)
+}
+
+// degradedNodes returns the nodes of the pool the machine config daemon failed to configure
+func (r *KataConfigOpenShiftReconciler) degradedNodes(mcp *mcfgv1.MachineConfigPool) ([]kataconfigurationv1.FailedNodeStatus, error) {
+	if mcp.Status.DegradedMachineCount == 0 || mcp.Spec.NodeSelector == nil {
+		return nil, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(mcp.Spec.NodeSelector)
+	if err != nil {
+		return nil, err
+	}
+	nodesList := &corev1.NodeList{}
+	if err := r.Client.List(context.TODO(), nodesList, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+
+	var degraded []kataconfigurationv1.FailedNodeStatus
+	for _, node := range nodesList.Items {
+		state := node.GetAnnotations()[daemonconsts.MachineConfigDaemonStateAnnotationKey]
+		if state != daemonconsts.MachineConfigDaemonStateDegraded && state != daemonconsts.MachineConfigDaemonStateUnreconcilable {
+			continue
+		}
+		degraded = append(degraded, kataconfigurationv1.FailedNodeStatus{
+			Name:  node.Name,
+			Error: node.GetAnnotations()[daemonconsts.MachineConfigDaemonReasonAnnotationKey],
+		})
+	}
+	return degraded, nil
+}
+
+// sameConditions compares conditions ignoring the transition times, which are set by SetStatusCondition
+func sameConditions(a, b []metav1.Condition) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, ca := range a {
+		cb := meta.FindStatusCondition(b, ca.Type)
+		if cb == nil || cb.Status != ca.Status || cb.Reason != ca.Reason || cb.Message != ca.Message ||
+			cb.ObservedGeneration != ca.ObservedGeneration {
+			return false
+		}
+	}
+	return true
+}
+
+// machineConfigPoolReady returns true once all machines of the pool run the configuration
+// the pool currently renders
+func machineConfigPoolReady(mcp *mcfgv1.MachineConfigPool) bool {
+	return mcp.Status.ObservedGeneration == mcp.Generation &&
+		mcp.Status.Configuration.Name == mcp.Spec.Configuration.Name &&
+		mcp.Status.UpdatedMachineCount == mcp.Status.MachineCount &&
+		mcp.Status.ReadyMachineCount == mcp.Status.MachineCount
+}
+
+// machineConfigRendered returns true if the MachineConfig is part of the configuration the pool renders
+func machineConfigRendered(mcp *mcfgv1.MachineConfigPool, mcName string) bool {
+	for _, source := range mcp.Spec.Configuration.Source {
+		if source.Name == mcName {
+			return true
+		}
+	}
+	return false
+}
+
+// nodesBackInParentPool returns true once the nodes left the kata pool and run the configuration of the
+// parent pool. Nodes the kata pool still selects stay in it until the pool is deleted, they don't count.
+func (r *KataConfigOpenShiftReconciler) nodesBackInParentPool(parentMcp *mcfgv1.MachineConfigPool, nodeNames []string) (bool, error) {
+	kataSelector := labels.Nothing()
+	kataMcp := &mcfgv1.MachineConfigPool{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: r.poolName()}, kataMcp)
+	if err == nil && kataMcp.Spec.NodeSelector != nil {
+		if kataSelector, err = metav1.LabelSelectorAsSelector(kataMcp.Spec.NodeSelector); err != nil {
+			return false, err
+		}
+	} else if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+
+	for _, nodeName := range nodeNames {
+		node := &corev1.Node{}
+		if err := r.Client.Get(context.TODO(), client.ObjectKey{Name: nodeName}, node); err != nil {
+			return false, err
+		}
+		if kataSelector.Matches(labels.Set(node.GetLabels())) {
+			continue
+		}
+		if node.GetAnnotations()[daemonconsts.CurrentMachineConfigAnnotationKey] != parentMcp.Spec.Configuration.Name {
+			r.Log.Info("Waiting for the node to run the configuration of the parent pool", "node", nodeName,
+				"mcp.Name", parentMcp.Name)
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// kataConfigsForPool maps an event of a machine config pool to the KataConfig the pool was created for,
+// events of the parent pools go to all KataConfigs as their nodes may come from or go back to them
+func (r *KataConfigOpenShiftReconciler) kataConfigsForPool(o handler.MapObject) []reconcile.Request {
+	if owner, ok := o.Meta.GetLabels()[kataConfigOwnerLabel]; ok {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: owner}}}
+	}
+	if o.Meta.GetName() != "worker" && o.Meta.GetName() != "master" {
+		return nil
+	}
+	return r.allKataConfigRequests()
+}
+
+// allKataConfigRequests returns a reconcile request for every KataConfig
+func (r *KataConfigOpenShiftReconciler) allKataConfigRequests() []reconcile.Request {
+	kataConfigList := &kataconfigurationv1.KataConfigList{}
+	if err := r.Client.List(context.TODO(), kataConfigList); err != nil {
+		r.Log.Error(err, "Failed to list KataConfig custom resources")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, kc := range kataConfigList.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: kc.Name},
+		})
+	}
+	return requests
+}
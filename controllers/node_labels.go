@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// kataNodeState is what is known about kata on a node it is installed on
+type kataNodeState struct {
+	version     string
+	hypervisors []string
+	// nested is nil as long as it is unknown whether the node is a VM itself
+	nested *bool
+}
+
+// invalidLabelValueChars are the characters not allowed in a label value
+var invalidLabelValueChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// labels returns the kata labels of the node, the ones which aren't known are left out
+func (s kataNodeState) labels() map[string]string {
+	labels := map[string]string{kataconfigurationv1.KataInstalledLabel: "true"}
+	if version := labelValue(s.version); version != "" {
+		labels[kataconfigurationv1.KataVersionLabel] = version
+	}
+	for _, hypervisor := range s.hypervisors {
+		labels[kataconfigurationv1.HypervisorLabelPrefix+hypervisor] = "true"
+	}
+	if s.nested != nil {
+		labels[kataconfigurationv1.NestedVirtualizationLabel] = strconv.FormatBool(*s.nested)
+	}
+	return labels
+}
+
+// reconcileNodeLabels puts the kata labels on the nodes kata is installed on and removes them from
+// the other nodes, labels which don't apply anymore, e.g. of a hypervisor, are removed as well
+func reconcileNodeLabels(c client.Client, installed map[string]kataNodeState, others []string) error {
+	nodeNames := make([]string, 0, len(installed))
+	for nodeName := range installed {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+
+	for _, nodeName := range nodeNames {
+		if err := setKataNodeLabels(c, nodeName, installed[nodeName].labels()); err != nil {
+			return err
+		}
+	}
+	return removeKataNodeLabels(c, others)
+}
+
+// removeKataNodeLabels removes the kata labels from the nodes
+func removeKataNodeLabels(c client.Client, nodeNames []string) error {
+	for _, nodeName := range nodeNames {
+		if err := setKataNodeLabels(c, nodeName, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setKataNodeLabels makes the labels the only kata labels of the node, nil removes them all
+func setKataNodeLabels(c client.Client, nodeName string, labels map[string]string) error {
+	node := &corev1.Node{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: nodeName}, node); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	patch := client.MergeFrom(node.DeepCopy())
+	nodeLabels := node.GetLabels()
+	if nodeLabels == nil {
+		nodeLabels = map[string]string{}
+	}
+	changed := false
+	for k := range nodeLabels {
+		if _, ok := labels[k]; !ok && isKataNodeLabel(k) {
+			delete(nodeLabels, k)
+			changed = true
+		}
+	}
+	for k, v := range labels {
+		if current, ok := nodeLabels[k]; !ok || current != v {
+			nodeLabels[k] = v
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	node.SetLabels(nodeLabels)
+
+	return c.Patch(context.TODO(), node, patch)
+}
+
+// isKataNodeLabel returns true for the labels the operator maintains on the kata nodes
+func isKataNodeLabel(key string) bool {
+	switch key {
+	case kataconfigurationv1.KataInstalledLabel, kataconfigurationv1.KataVersionLabel,
+		kataconfigurationv1.NestedVirtualizationLabel:
+		return true
+	}
+	return strings.HasPrefix(key, kataconfigurationv1.HypervisorLabelPrefix)
+}
+
+// labelValue turns the value into a valid label value, "" if nothing is left of it
+func labelValue(value string) string {
+	value = invalidLabelValueChars.ReplaceAllString(value, "_")
+	if len(value) > 63 {
+		value = value[:63]
+	}
+	return strings.Trim(value, "._-")
+}
+
+// reconcileNodeLabels labels the nodes kata is installed on from what the daemons reported: the
+// installed kata version, the hypervisor of the kata configuration and the preflight results
+func (r *KataConfigOpenShiftReconciler) reconcileNodeLabels() error {
+	status := &r.kataConfig.Status
+	installed := map[string]kataNodeState{}
+	for _, nodeName := range status.InstallationStatus.Completed.CompletedNodesList {
+		installed[nodeName] = kataNodeState{}
+	}
+
+	var others []string
+	for _, ns := range status.NodeStatus {
+		state, ok := installed[ns.Name]
+		if !ok {
+			others = append(others, ns.Name)
+			continue
+		}
+		state.version = ns.KataVersion
+		installed[ns.Name] = state
+	}
+	for _, sandbox := range status.OverheadStatus.NodesList {
+		if state, ok := installed[sandbox.Name]; ok && sandbox.Hypervisor != "" {
+			state.hypervisors = []string{sandbox.Hypervisor}
+			installed[sandbox.Name] = state
+		}
+	}
+	for _, result := range status.PreflightStatus.NodeResults {
+		if state, ok := installed[result.Name]; ok {
+			state.nested = result.NestedVirtualization
+			installed[result.Name] = state
+		}
+	}
+
+	return reconcileNodeLabels(r.Client, installed, others)
+}
+
+// reconcileNodeLabels labels the nodes kata-deploy installed kata on. kata-deploy doesn't report the
+// kata version or the hypervisors of a node, so only the installed label is set. The labels are
+// removed from the selected nodes kata isn't installed on.
+func (r *KataConfigKubernetesReconciler) reconcileNodeLabels() error {
+	installed := map[string]kataNodeState{}
+	for _, nodeName := range r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesList {
+		installed[nodeName] = kataNodeState{}
+	}
+
+	nodesList := &corev1.NodeList{}
+	if err := r.Client.List(context.TODO(), nodesList, client.MatchingLabels(r.kataNodeSelector())); err != nil {
+		return err
+	}
+	var others []string
+	for _, node := range nodesList.Items {
+		if _, ok := installed[node.Name]; !ok {
+			others = append(others, node.Name)
+		}
+	}
+	return reconcileNodeLabels(r.Client, installed, others)
+}
+
+// kataNodeLabelsChanged passes the node updates which changed a kata label, the labels are put
+// back if they got changed by hand
+func kataNodeLabelsChanged() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldLabels, newLabels := e.MetaOld.GetLabels(), e.MetaNew.GetLabels()
+			for _, labels := range []map[string]string{oldLabels, newLabels} {
+				for k := range labels {
+					if isKataNodeLabel(k) && oldLabels[k] != newLabels[k] {
+						return true
+					}
+				}
+			}
+			return false
+		},
+	}
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestLabelValue(t *testing.T) {
+	for _, test := range []struct {
+		value string
+		label string
+	}{
+		{"", ""},
+		{"2.1.0", "2.1.0"},
+		{"2.1.0-rc0+git.abc", "2.1.0-rc0_git.abc"},
+		{" 2.1.0\n", "2.1.0"},
+		{"-.-", ""},
+		{strings.Repeat("a", 62) + ".b", strings.Repeat("a", 62)},
+	} {
+		if label := labelValue(test.value); label != test.label {
+			t.Errorf("labelValue(%q) = %q, want %q", test.value, label, test.label)
+		}
+	}
+}
+
+func testNodeLabels(t *testing.T, c client.Client, nodeName string) map[string]string {
+	t.Helper()
+	node := &corev1.Node{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: nodeName}, node); err != nil {
+		t.Fatal(err)
+	}
+	return node.Labels
+}
+
+func TestOpenShiftNodeLabels(t *testing.T) {
+	nested := true
+	kataConfig := &kataconfigurationv1.KataConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig"},
+		Status: kataconfigurationv1.KataConfigStatus{
+			InstallationStatus: kataconfigurationv1.KataInstallationStatus{
+				Completed: kataconfigurationv1.KataConfigCompletedStatus{
+					CompletedNodesCount: 1,
+					CompletedNodesList:  []string{"worker-0"},
+				},
+			},
+			NodeStatus: []kataconfigurationv1.KataNodeStatus{
+				{Name: "worker-0", PayloadVersion: "4.8.2", KataVersion: "2.1.0"},
+				{Name: "worker-1", PayloadVersion: "4.8.2"},
+			},
+			OverheadStatus: kataconfigurationv1.KataOverheadStatus{
+				NodesList: []kataconfigurationv1.NodeSandboxConfig{{Name: "worker-0", Hypervisor: "qemu"}},
+			},
+			PreflightStatus: kataconfigurationv1.KataPreflightStatus{
+				NodeResults: []kataconfigurationv1.NodePreflightResult{{Name: "worker-0", NestedVirtualization: &nested}},
+			},
+		},
+	}
+	c := newTestClient(t, kataConfig,
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name:   "worker-0",
+			Labels: map[string]string{"node-role.kubernetes.io/worker": "", kataconfigurationv1.HypervisorLabelPrefix + "fc": "true"},
+		}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name:   "worker-1",
+			Labels: map[string]string{"node-role.kubernetes.io/worker": "", kataconfigurationv1.KataInstalledLabel: "true"},
+		}})
+
+	r := &KataConfigOpenShiftReconciler{Client: c, kataConfig: kataConfig}
+	if err := r.reconcileNodeLabels(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The version is the one of kata, not the one of the payload, a hypervisor not configured anymore is dropped
+	expected := map[string]string{
+		"node-role.kubernetes.io/worker":                   "",
+		kataconfigurationv1.KataInstalledLabel:             "true",
+		kataconfigurationv1.KataVersionLabel:               "2.1.0",
+		kataconfigurationv1.HypervisorLabelPrefix + "qemu": "true",
+		kataconfigurationv1.NestedVirtualizationLabel:      "true",
+	}
+	if labels := testNodeLabels(t, c, "worker-0"); !reflect.DeepEqual(labels, expected) {
+		t.Errorf("got labels %v, want %v", labels, expected)
+	}
+	if labels := testNodeLabels(t, c, "worker-1"); !reflect.DeepEqual(labels, map[string]string{"node-role.kubernetes.io/worker": ""}) {
+		t.Errorf("kata labels left on a node kata isn't installed on: %v", labels)
+	}
+}
+
+func TestKubernetesNodeLabels(t *testing.T) {
+	kataConfig := &kataconfigurationv1.KataConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig"},
+		Status: kataconfigurationv1.KataConfigStatus{
+			KataImage: "quay.io/kata-containers/kata-deploy:2.1.0",
+			InstallationStatus: kataconfigurationv1.KataInstallationStatus{
+				Completed: kataconfigurationv1.KataConfigCompletedStatus{
+					CompletedNodesCount: 1,
+					CompletedNodesList:  []string{"worker-0"},
+				},
+			},
+		},
+	}
+	worker := map[string]string{"node-role.kubernetes.io/worker": ""}
+	c := newTestClient(t, kataConfig,
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Labels: map[string]string{
+			"node-role.kubernetes.io/worker":     "",
+			kataconfigurationv1.KataVersionLabel: "2.0.0",
+		}}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-1", Labels: map[string]string{
+			"node-role.kubernetes.io/worker":       "",
+			kataconfigurationv1.KataInstalledLabel: "true",
+		}}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "master-0", Labels: map[string]string{
+			kataconfigurationv1.KataInstalledLabel: "true",
+		}}})
+
+	r := &KataConfigKubernetesReconciler{Client: c, kataConfig: kataConfig}
+	if err := r.reconcileNodeLabels(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing reports the kata version on Kubernetes, the tag of the kata-deploy image isn't taken for it
+	expected := map[string]string{"node-role.kubernetes.io/worker": "", kataconfigurationv1.KataInstalledLabel: "true"}
+	if labels := testNodeLabels(t, c, "worker-0"); !reflect.DeepEqual(labels, expected) {
+		t.Errorf("got labels %v, want %v", labels, expected)
+	}
+	if labels := testNodeLabels(t, c, "worker-1"); !reflect.DeepEqual(labels, worker) {
+		t.Errorf("kata labels left on a node kata isn't installed on: %v", labels)
+	}
+	// Nodes which aren't selected may belong to another installation
+	if labels := testNodeLabels(t, c, "master-0"); labels[kataconfigurationv1.KataInstalledLabel] != "true" {
+		t.Errorf("labels removed from a node which isn't selected: %v", labels)
+	}
+}
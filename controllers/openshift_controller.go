@@ -33,15 +33,17 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	nodeapi "k8s.io/api/node/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // blank assignment to verify that KataConfigOpenShiftReconciler implements reconcile.Reconciler
@@ -52,6 +54,8 @@ type KataConfigOpenShiftReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+	// Daemon configures the installation daemon and the validation pods
+	Daemon DaemonConfig
 
 	clientset  kubernetes.Interface
 	kataConfig *kataconfigurationv1.KataConfig
@@ -59,11 +63,13 @@ type KataConfigOpenShiftReconciler struct {
 
 // +kubebuilder:rbac:groups=kataconfiguration.openshift.io,resources=kataconfigs;kataconfigs/finalizers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=kataconfiguration.openshift.io,resources=kataconfigs/status,verbs=get;update;patch
-// +kubebuilder:rbac:groups=apps,resources=deployments;daemonsets;replicasets;statefulsets,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=apps,resources=daemonsets/finalizers,resourceNames=manager-role,verbs=update
+// +kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=node.k8s.io,resources=runtimeclasses,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=config.openshift.io,resources=clusterversions,verbs=get
-// +kubebuilder:rbac:groups="";machineconfiguration.openshift.io,resources=nodes;machineconfigs;machineconfigpools;pods;services;services/finalizers;endpoints;persistentvolumeclaims;events;configmaps;secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=machineconfiguration.openshift.io,resources=machineconfigs;machineconfigpools,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;delete
 
 func (r *KataConfigOpenShiftReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	_ = context.Background()
@@ -84,30 +90,107 @@ func (r *KataConfigOpenShiftReconciler) Reconcile(req ctrl.Request) (ctrl.Result
 		return ctrl.Result{}, err
 	}
 
-	return func() (ctrl.Result, error) {
-		oldest, err := r.isOldestCR()
-		if !oldest && err != nil {
+	var retryAfter time.Duration
+	res, err := func() (ctrl.Result, error) {
+		overlapping, err := r.checkOverlappingCRs()
+		if err != nil {
 			return reconcile.Result{Requeue: true}, err
-		} else if !oldest && err == nil {
+		} else if overlapping {
 			return reconcile.Result{}, nil
 		}
 
+		if err := r.assignPoolName(); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// The installation and the uninstallation daemons run as the daemon service account
+		if err := r.reconcileDaemonRBAC(); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if err := r.detectTopology(); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if err := r.reportMachineConfigPoolStatus(); err != nil {
+			return ctrl.Result{}, err
+		}
+
 		// Check if the KataConfig instance is marked to be deleted, which is
 		// indicated by the deletion timestamp being set.
 		if r.kataConfig.GetDeletionTimestamp() != nil {
 			return r.processKataConfigDeleteRequest()
 		}
 
+		// Control plane nodes can't be moved to a kata pool
+		refused, err := r.checkTopology()
+		if err != nil {
+			return ctrl.Result{}, err
+		} else if refused {
+			return ctrl.Result{}, nil
+		}
+
+		if err := r.processRollbackRequests(); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		machinePool, err := r.workerOrMaster()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		nodeSelector, err := metav1.LabelSelectorAsSelector(r.kataNodeSelector(machinePool))
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		retrier := &kataRetrier{
+			client:       r.Client,
+			log:          r.Log,
+			kataConfig:   r.kataConfig,
+			namespace:    r.daemonConfig().Namespace,
+			dsName:       r.daemonsetName(InstallOperation),
+			nodeSelector: nodeSelector,
+		}
+		retryAfter, err = retrier.process()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// Size the pod overhead from the sandbox VM settings the nodes reported so far
+		if err := r.updateOverhead(); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// Put back whatever got changed in the objects created for the KataConfig
+		if err := r.reconcileDrift(); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// Keep everything but sandboxed workloads off the nodes kata got installed on
+		if err := reconcileNodeTaints(r.Client, r.kataConfig, r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesList); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// Let schedulers and tooling find the kata nodes by their labels
+		if err := r.reconcileNodeLabels(); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// Nodes are released batch by batch if a rollout strategy is used
+		if r.kataConfig.Spec.RolloutStrategy != nil {
+			return r.processKataConfigRollout()
+		}
+
 		// if we are using openshift then make sure that MCO related things are
 		// handled only after kata binaries are installed on the nodes
-		if r.kataConfig.Status.TotalNodesCount > 0 &&
-			len(r.kataConfig.Status.InstallationStatus.InProgress.BinariesInstalledNodesList) == r.kataConfig.Status.TotalNodesCount {
-			return r.monitorKataConfigInstallation()
+		if r.kataNodesCount() > 0 &&
+			len(r.kataConfig.Status.InstallationStatus.InProgress.BinariesInstalledNodesList) == r.kataNodesCount() {
+			_, err := r.monitorKataConfigInstallation()
+			return ctrl.Result{}, err
 		}
 
 		// Once all the nodes have installed kata binaries and configured the CRI runtime create the runtime class
-		if r.kataConfig.Status.TotalNodesCount > 0 &&
-			r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesCount == r.kataConfig.Status.TotalNodesCount &&
+		if r.kataNodesCount() > 0 &&
+			r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesCount == r.kataNodesCount() &&
 			r.kataConfig.Status.RuntimeClass == "" {
 
 			err := r.deleteKataDaemonset(InstallOperation)
@@ -117,9 +200,23 @@ func (r *KataConfigOpenShiftReconciler) Reconcile(req ctrl.Request) (ctrl.Result
 
 			return r.setRuntimeClass()
 		}
+
+		// Make sure a kata pod can actually be started on every node kata got installed on
+		if r.kataConfig.Status.RuntimeClass != "" {
+			return r.validateKataNodes()
+		}
+
 		// Intiate the installation of kata runtime on the nodes if it doesn't exist already
 		return r.processKataConfigInstallRequest()
 	}()
+
+	// Come back in time for the next automatic retry of a failed node
+	if err == nil && retryAfter > 0 && (res.RequeueAfter == 0 || res.RequeueAfter > retryAfter) {
+		res.Requeue = true
+		res.RequeueAfter = retryAfter
+	}
+
+	return res, err
 }
 
 func (r *KataConfigOpenShiftReconciler) processDaemonsetForCR(operation DaemonOperation) *appsv1.DaemonSet {
@@ -127,10 +224,9 @@ func (r *KataConfigOpenShiftReconciler) processDaemonsetForCR(operation DaemonOp
 		runPrivileged           = true
 		configmapOptional       = true
 		runAsUser         int64 = 0
-		trueValue               = true
 	)
 
-	dsName := "sandboxed-containers-operator-daemon-" + string(operation)
+	dsName := r.daemonsetName(operation)
 	labels := map[string]string{
 		"name": dsName,
 	}
@@ -143,15 +239,20 @@ func (r *KataConfigOpenShiftReconciler) processDaemonsetForCR(operation DaemonOp
 			"node-role.kubernetes.io/worker": "",
 		}
 	}
+	nodeSelector = withRolloutLabel(r.kataConfig, nodeSelector, kataRolloutReleasedLabel)
 
-	return &appsv1.DaemonSet{
+	daemon := r.daemonConfig()
+	ds := &appsv1.DaemonSet{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "apps/v1",
 			Kind:       "DaemonSet",
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      dsName,
-			Namespace: "sandboxed-containers-operator-system",
+			Namespace: daemon.Namespace,
+			Labels: map[string]string{
+				kataConfigOwnerLabel: r.kataConfig.Name,
+			},
 		},
 		Spec: appsv1.DaemonSetSpec{
 			Selector: &metav1.LabelSelector{
@@ -162,13 +263,17 @@ func (r *KataConfigOpenShiftReconciler) processDaemonsetForCR(operation DaemonOp
 					Labels: labels,
 				},
 				Spec: corev1.PodSpec{
-					ServiceAccountName: "default",
+					ServiceAccountName: daemon.ServiceAccount,
+					ImagePullSecrets:   daemon.imagePullSecrets(),
 					NodeSelector:       nodeSelector,
+					Tolerations:        append(append([]corev1.Toleration{}, daemon.Tolerations...), kataTolerations(r.kataConfig)...),
+					PriorityClassName:  daemon.PriorityClassName,
 					Containers: []corev1.Container{
 						{
 							Name:            "kata-install-pod",
-							Image:           "quay.io/isolatedcontainers/sandboxed-containers-operator-daemon@sha256:84df0ddc078c3dee27074d419f85dae715f8667c95e37ebf01fb7e45b083c721",
-							ImagePullPolicy: "Always",
+							Image:           daemon.Image,
+							ImagePullPolicy: daemon.ImagePullPolicy,
+							Resources:       daemon.Resources,
 							SecurityContext: &corev1.SecurityContext{
 								Privileged: &runPrivileged,
 								RunAsUser:  &runAsUser,
@@ -180,7 +285,8 @@ func (r *KataConfigOpenShiftReconciler) processDaemonsetForCR(operation DaemonOp
 									},
 								},
 							},
-							Command: []string{"/bin/sh", "-c", fmt.Sprintf("/daemon --resource %s --operation %s", r.kataConfig.Name, operation)},
+							Command: []string{"/bin/sh", "-c", fmt.Sprintf("/daemon --resource %s --operation %s --log-level %s --log-format %s",
+								r.kataConfig.Name, operation, daemon.LogLevel, daemon.LogFormat)},
 							VolumeMounts: []corev1.VolumeMount{
 								{
 									Name:      "hostroot",
@@ -188,30 +294,6 @@ func (r *KataConfigOpenShiftReconciler) processDaemonsetForCR(operation DaemonOp
 								},
 							},
 							Env: []corev1.EnvVar{
-								{
-									Name: "PAYLOAD_REGISTRY_USERNAME",
-									ValueFrom: &corev1.EnvVarSource{
-										SecretKeyRef: &corev1.SecretKeySelector{
-											LocalObjectReference: corev1.LocalObjectReference{
-												Name: "payload-secret",
-											},
-											Key:      "username",
-											Optional: &trueValue,
-										},
-									},
-								},
-								{
-									Name: "PAYLOAD_REGISTRY_PASSWORD",
-									ValueFrom: &corev1.EnvVarSource{
-										SecretKeyRef: &corev1.SecretKeySelector{
-											LocalObjectReference: corev1.LocalObjectReference{
-												Name: "payload-secret",
-											},
-											Key:      "password",
-											Optional: &trueValue,
-										},
-									},
-								},
 								{
 									Name: "KATA_PAYLOAD_IMAGE",
 									ValueFrom: &corev1.EnvVarSource{
@@ -224,6 +306,15 @@ func (r *KataConfigOpenShiftReconciler) processDaemonsetForCR(operation DaemonOp
 										},
 									},
 								},
+								{
+									// The daemon keeps the diagnostics of a failed node in a ConfigMap of its namespace
+									Name: "POD_NAMESPACE",
+									ValueFrom: &corev1.EnvVarSource{
+										FieldRef: &corev1.ObjectFieldSelector{
+											FieldPath: "metadata.namespace",
+										},
+									},
+								},
 							},
 						},
 					},
@@ -244,19 +335,99 @@ func (r *KataConfigOpenShiftReconciler) processDaemonsetForCR(operation DaemonOp
 			},
 		},
 	}
+	r.addPayloadPullSecret(&ds.Spec.Template.Spec)
+	return ds
+}
+
+// addPayloadPullSecret passes the credentials for the payload image to the daemon. The pull secret is
+// mounted as a file rather than passed in the environment, which shows up in the pod spec and the logs.
+// Without a pull secret the username and password of the legacy payload-secret are passed as before.
+func (r *KataConfigOpenShiftReconciler) addPayloadPullSecret(podSpec *corev1.PodSpec) {
+	config := r.kataConfig.Spec.Config
+	container := &podSpec.Containers[0]
+
+	if config.PullSecret != nil && config.PullSecret.Name != "" {
+		// The secret is optional so that the daemon starts and reports a missing secret as a failure of the node
+		optional := true
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: "payload-pull-secret",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: config.PullSecret.Name,
+					Items: []corev1.KeyToPath{
+						{Key: corev1.DockerConfigJsonKey, Path: payloadAuthFileName},
+					},
+					Optional: &optional,
+				},
+			},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      "payload-pull-secret",
+			MountPath: payloadAuthDir,
+			ReadOnly:  true,
+		})
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  "PAYLOAD_AUTH_FILE",
+			Value: payloadAuthDir + "/" + payloadAuthFileName,
+		})
+	} else {
+		// Deprecated, the daemon warns when it pulls the payload with the credentials of the legacy secret
+		optional := true
+		for _, key := range []struct{ env, key string }{
+			{"PAYLOAD_REGISTRY_USERNAME", "username"},
+			{"PAYLOAD_REGISTRY_PASSWORD", "password"},
+		} {
+			container.Env = append(container.Env, corev1.EnvVar{
+				Name: key.env,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: legacyPayloadSecret},
+						Key:                  key.key,
+						Optional:             &optional,
+					},
+				},
+			})
+		}
+	}
+
+	if config.UseClusterPullSecret {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  "PAYLOAD_USE_CLUSTER_PULL_SECRET",
+			Value: "true",
+		})
+	}
+}
+
+// daemonConfig returns the configuration of the daemon with the defaults for what the operator didn't set
+func (r *KataConfigOpenShiftReconciler) daemonConfig() DaemonConfig {
+	return r.Daemon.withDefaults()
 }
 
 func (r *KataConfigOpenShiftReconciler) newMCPforCR() *mcfgv1.MachineConfigPool {
 	lsr := metav1.LabelSelectorRequirement{
 		Key:      "machineconfiguration.openshift.io/role",
 		Operator: metav1.LabelSelectorOpIn,
-		Values:   []string{"kata-oc", "worker"},
+		Values:   []string{r.poolName(), "worker"},
 	}
 
 	var nodeSelector *metav1.LabelSelector
 
-	if r.kataConfig.Spec.KataConfigPoolSelector != nil {
-		nodeSelector = r.kataConfig.Spec.KataConfigPoolSelector
+	if machinePool, err := r.workerOrMaster(); err == nil {
+		nodeSelector = r.kataNodeSelector(machinePool).DeepCopy()
+
+		// Keep the nodes that failed the preflight checks or got rolled back out
+		// of the pool so that they don't get the CRI-O configuration for kata
+		skippedNodes := r.excludedNodes()
+		if len(skippedNodes) > 0 {
+			nodeSelector.MatchExpressions = append(nodeSelector.MatchExpressions, metav1.LabelSelectorRequirement{
+				Key:      "kubernetes.io/hostname",
+				Operator: metav1.LabelSelectorOpNotIn,
+				Values:   skippedNodes,
+			})
+		}
+
+		// Only the nodes that got the kata binaries installed by the rollout join the pool
+		nodeSelector.MatchLabels = withRolloutLabel(r.kataConfig, nodeSelector.MatchLabels, kataRolloutPoolLabel)
 	}
 
 	mcp := &mcfgv1.MachineConfigPool{
@@ -265,7 +436,10 @@ func (r *KataConfigOpenShiftReconciler) newMCPforCR() *mcfgv1.MachineConfigPool
 			Kind:       "MachineConfigPool",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "kata-oc",
+			Name: r.poolName(),
+			Labels: map[string]string{
+				kataConfigOwnerLabel: r.kataConfig.Name,
+			},
 		},
 		Spec: mcfgv1.MachineConfigPoolSpec{
 			MachineConfigSelector: &metav1.LabelSelector{
@@ -298,12 +472,9 @@ WantedBy=multi-user.target
 		return nil, err
 	}
 
-	if kataOC {
-		machinePool = "kata-oc"
-	} else if _, ok := r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels["node-role.kubernetes.io/"+machinePool]; ok {
-		r.Log.Info("in newMCforCR machinePool" + machinePool)
-	} else {
-		r.Log.Error(err, "no valid role for mc found")
+	// The MachineConfig goes to the kata pool unless the nodes are configured through their parent pool
+	if kataOC || !r.usesParentPool(machinePool) {
+		machinePool = r.poolName()
 	}
 
 	file := ignTypes.File{}
@@ -344,12 +515,12 @@ WantedBy=multi-user.target
 			Kind:       "MachineConfig",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "50-kata-crio-dropin",
+			Name: r.machineConfigName(),
 			Labels: map[string]string{
 				"machineconfiguration.openshift.io/role": machinePool,
 				"app":                                    r.kataConfig.Name,
+				kataConfigOwnerLabel:                     r.kataConfig.Name,
 			},
-			Namespace: "sandboxed-containers-operator",
 		},
 		Spec: mcfgv1.MachineConfigSpec{
 			Config: runtime.RawExtension{
@@ -405,56 +576,110 @@ func (r *KataConfigOpenShiftReconciler) addFinalizer() error {
 	return nil
 }
 
-func (r *KataConfigOpenShiftReconciler) listKataPods() error {
-	podList := &corev1.PodList{}
-	listOpts := []client.ListOption{
-		client.InNamespace(corev1.NamespaceAll),
-	}
-	if err := r.Client.List(context.TODO(), podList, listOpts...); err != nil {
-		return fmt.Errorf("Failed to list kata pods: %v", err)
-	}
-	for _, pod := range podList.Items {
-		if pod.Spec.RuntimeClassName != nil {
-			if *pod.Spec.RuntimeClassName == r.kataConfig.Status.RuntimeClass {
-				return fmt.Errorf("Existing pods using Kata Runtime found. Please delete the pods manually for KataConfig deletion to proceed")
-			}
-		}
-	}
-	return nil
-}
-
 func (r *KataConfigOpenShiftReconciler) kataOcExists() (bool, error) {
 	kataOcMcp := &mcfgv1.MachineConfigPool{}
-	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: "kata-oc"}, kataOcMcp)
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: r.poolName()}, kataOcMcp)
 	if err != nil && errors.IsNotFound(err) {
-		r.Log.Info("No kata-oc machine config pool found!")
+		r.Log.Info("No kata machine config pool found!", "mcp.Name", r.poolName())
 		return false, nil
 	} else if err != nil {
-		r.Log.Error(err, "Could not get the kata-oc machine config pool!")
+		r.Log.Error(err, "Could not get the kata machine config pool!", "mcp.Name", r.poolName())
 		return false, err
 	}
 
 	return true, nil
 }
 
+// excludedNodes returns the nodes that are left out of the kata pool, these are the nodes
+// that failed the preflight checks and the nodes rolled back after a failed installation
+func (r *KataConfigOpenShiftReconciler) excludedNodes() []string {
+	var nodes []string
+	for _, fn := range r.kataConfig.Status.PreflightStatus.Failed.FailedNodesList {
+		nodes = append(nodes, fn.Name)
+	}
+	return append(nodes, r.kataConfig.Status.RollbackStatus.CompletedNodesList...)
+}
+
+// kataNodesCount returns the number of nodes kata is going to be installed on,
+// which excludes the nodes that failed the preflight checks or got rolled back
+func (r *KataConfigOpenShiftReconciler) kataNodesCount() int {
+	return r.kataConfig.Status.TotalNodesCount - len(r.excludedNodes())
+}
+
+// usesParentPool returns true if the kata MachineConfig is applied to the parent (worker or master)
+// pool directly instead of a dedicated kata-oc pool
+func (r *KataConfigOpenShiftReconciler) usesParentPool(machinePool string) bool {
+	if _, ok := r.kataNodeSelector(machinePool).MatchLabels["node-role.kubernetes.io/"+machinePool]; !ok {
+		return false
+	}
+
+	// Control plane nodes always stay in the master pool, excluded nodes can't be left out there
+	if r.controlPlaneCluster() {
+		return true
+	}
+
+	// A rollout adds the nodes batch by batch to the kata-oc pool
+	if r.kataConfig.Spec.RolloutStrategy != nil {
+		return false
+	}
+
+	// Excluded nodes can only be left out with a kata-oc pool
+	return len(r.excludedNodes()) == 0
+}
+
+// processRollbackRequests restarts the installation daemon on the failed nodes listed in the
+// rollback nodes, the daemon rolls back a failed node when it starts. The daemon is restarted
+// only once per request, a node removed from the rollback nodes can be requested again.
+func (r *KataConfigOpenShiftReconciler) processRollbackRequests() error {
+	if r.kataConfig.Spec.RollbackPolicy != kataconfigurationv1.RollbackPolicyManual {
+		return nil
+	}
+
+	status := &r.kataConfig.Status
+	var requested []string
+	for _, nodeName := range status.RollbackStatus.RequestedNodesList {
+		if contains(r.kataConfig.Spec.RollbackNodes, nodeName) {
+			requested = append(requested, nodeName)
+		}
+	}
+	changed := len(requested) != len(status.RollbackStatus.RequestedNodesList)
+
+	for _, nodeName := range r.kataConfig.Spec.RollbackNodes {
+		if contains(requested, nodeName) ||
+			contains(status.RollbackStatus.CompletedNodesList, nodeName) ||
+			failedNodesContain(status.RollbackStatus.Failed, nodeName) ||
+			!failedNodesContain(status.InstallationStatus.Failed, nodeName) {
+			continue
+		}
+
+		r.Log.Info("Restarting the installation daemon to roll back the node", "node", nodeName)
+		if err := deleteDaemonPod(r.Client, r.daemonConfig().Namespace, r.daemonsetName(InstallOperation), nodeName); err != nil {
+			return err
+		}
+		requested = append(requested, nodeName)
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	status.RollbackStatus.RequestedNodesList = requested
+	return r.Client.Status().Update(context.TODO(), r.kataConfig)
+}
+
+// workerOrMaster returns the machine config pool the selected nodes belong to without a kata pool,
+// this is the master pool on compact and single node clusters
 func (r *KataConfigOpenShiftReconciler) workerOrMaster() (string, error) {
-	var role string
-	workerMcp := &mcfgv1.MachineConfigPool{}
-	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: "worker"}, workerMcp)
-	if err != nil && errors.IsNotFound(err) {
-		r.Log.Error(err, "No worker machine config pool found!")
-		return "", err
-	} else if err != nil {
-		r.Log.Error(err, "Could not get the worker machine config pool!")
-		return "", err
+	if r.kataConfig.Status.Topology.MachinePool != "" {
+		return r.kataConfig.Status.Topology.MachinePool, nil
 	}
 
-	if workerMcp.Status.MachineCount > 0 {
-		role = "worker"
-	} else {
-		role = "master"
+	topology, err := r.readTopology()
+	if err != nil {
+		r.Log.Error(err, "Could not detect the cluster topology!")
+		return "", err
 	}
-	return role, nil
+	return topology.MachinePool, nil
 }
 
 func (r *KataConfigOpenShiftReconciler) processKataConfigInstallRequest() (ctrl.Result, error) {
@@ -502,7 +727,7 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigInstallRequest() (ctrl.
 
 	// Don't create the daemonset if kata is already installed on the cluster nodes
 	if r.kataConfig.Status.TotalNodesCount > 0 &&
-		r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesCount != r.kataConfig.Status.TotalNodesCount {
+		r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesCount != r.kataNodesCount() {
 		ds := r.processDaemonsetForCR(InstallOperation)
 		// Set KataConfig instance as the owner and controller
 		if err := controllerutil.SetControllerReference(r.kataConfig, ds, r.Scheme); err != nil {
@@ -531,36 +756,43 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigInstallRequest() (ctrl.
 	return ctrl.Result{}, nil
 }
 
-func (r *KataConfigOpenShiftReconciler) setRuntimeClass() (ctrl.Result, error) {
-	runtimeClassName := "kata"
-
-	rc := func() *nodeapi.RuntimeClass {
-		rc := &nodeapi.RuntimeClass{
-			TypeMeta: metav1.TypeMeta{
-				APIVersion: "node.k8s.io/v1beta1",
-				Kind:       "RuntimeClass",
-			},
-			ObjectMeta: metav1.ObjectMeta{
-				Name: runtimeClassName,
-			},
-			Handler: runtimeClassName,
-			// Use same values for Pod Overhead as upstream kata-deploy using, see
-			// https://github.com/kata-containers/packaging/blob/f17450317563b6e4d6b1a71f0559360b37783e19/kata-deploy/k8s-1.18/kata-runtimeClasses.yaml#L7
-			Overhead: &nodeapi.Overhead{
-				PodFixed: corev1.ResourceList{
-					corev1.ResourceCPU:    resource.MustParse("250m"),
-					corev1.ResourceMemory: resource.MustParse("160Mi"),
-				},
+func (r *KataConfigOpenShiftReconciler) newRuntimeClassForCR() *nodeapi.RuntimeClass {
+	rc := &nodeapi.RuntimeClass{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "node.k8s.io/v1beta1",
+			Kind:       "RuntimeClass",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: r.runtimeClassName(),
+			Labels: map[string]string{
+				kataConfigOwnerLabel: r.kataConfig.Name,
 			},
-		}
+		},
+		// The CRI-O runtime is called kata on all nodes, no matter which KataConfig selects them
+		Handler: "kata",
+		Overhead: &nodeapi.Overhead{
+			PodFixed: sandboxOverhead(r.kataConfig).PodFixed,
+		},
+	}
 
-		if r.kataConfig.Spec.KataConfigPoolSelector != nil {
-			rc.Scheduling = &nodeapi.Scheduling{
-				NodeSelector: r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels,
-			}
+	if r.kataConfig.Spec.KataConfigPoolSelector != nil {
+		rc.Scheduling = &nodeapi.Scheduling{
+			NodeSelector: withRolloutLabel(r.kataConfig, r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels, kataRolloutPoolLabel),
 		}
-		return rc
-	}()
+	}
+	// The pods using the runtime class get the tolerations for the taints of the kata nodes
+	if tolerations := kataTolerations(r.kataConfig); tolerations != nil {
+		if rc.Scheduling == nil {
+			rc.Scheduling = &nodeapi.Scheduling{}
+		}
+		rc.Scheduling.Tolerations = tolerations
+	}
+	return rc
+}
+
+func (r *KataConfigOpenShiftReconciler) setRuntimeClass() (ctrl.Result, error) {
+	runtimeClassName := r.runtimeClassName()
+	rc := r.newRuntimeClassForCR()
 
 	// Set Kataconfig r.kataConfig as the owner and controller
 	if err := controllerutil.SetControllerReference(r.kataConfig, rc, r.Scheme); err != nil {
@@ -588,21 +820,158 @@ func (r *KataConfigOpenShiftReconciler) setRuntimeClass() (ctrl.Result, error) {
 	return ctrl.Result{}, nil
 }
 
+func (r *KataConfigOpenShiftReconciler) newNodeValidator() *kataNodeValidator {
+	return &kataNodeValidator{
+		client:           r.Client,
+		scheme:           r.Scheme,
+		log:              r.Log,
+		kataConfig:       r.kataConfig,
+		namespace:        r.daemonConfig().Namespace,
+		image:            r.daemonConfig().Image,
+		pullSecrets:      r.daemonConfig().imagePullSecrets(),
+		runtimeClassName: r.kataConfig.Status.RuntimeClass,
+	}
+}
+
+func (r *KataConfigOpenShiftReconciler) validateKataNodes() (ctrl.Result, error) {
+	done, err := r.newNodeValidator().validate(r.kataConfig.Status.InstallationStatus.Completed.CompletedNodesList)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !done {
+		// pod updates trigger a reconcile too, this only catches validation pods that never start
+		return ctrl.Result{Requeue: true, RequeueAfter: 30 * time.Second}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// processKataConfigRollout drives the installation if a rollout strategy is used. Unlike the regular
+// installation the kata-oc pool, the MachineConfig and the runtime class are set up as soon as the
+// first nodes have the kata binaries, the nodes of later batches then join the pool one by one.
+func (r *KataConfigOpenShiftReconciler) processKataConfigRollout() (ctrl.Result, error) {
+	// Counts the nodes, creates the installation daemonset and adds the finalizer
+	res, err := r.processKataConfigInstallRequest()
+	if err != nil || res.Requeue {
+		return res, err
+	}
+
+	if r.kataConfig.Spec.KataConfigPoolSelector == nil {
+		machinePool, err := r.workerOrMaster()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		r.kataConfig.Spec.KataConfigPoolSelector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{"node-role.kubernetes.io/" + machinePool: ""},
+		}
+	}
+
+	nodesList := &corev1.NodeList{}
+	listOpts := []client.ListOption{
+		client.MatchingLabels(r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels),
+	}
+	if err := r.Client.List(context.TODO(), nodesList, listOpts...); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	status := &r.kataConfig.Status
+
+	// Nodes skipped by the preflight checks don't hold back the rollout
+	doneNodes := append([]string{}, status.ValidationStatus.ReadyNodesList...)
+	for _, fn := range status.PreflightStatus.Failed.FailedNodesList {
+		doneNodes = append(doneNodes, fn.Name)
+	}
+	var failedNodes []string
+	for _, fn := range status.InstallationStatus.Failed.FailedNodesList {
+		failedNodes = append(failedNodes, fn.Name)
+	}
+	for _, fn := range status.ValidationStatus.Failed.FailedNodesList {
+		failedNodes = append(failedNodes, fn.Name)
+	}
+
+	rollout := &kataRollout{
+		client:     r.Client,
+		log:        r.Log,
+		kataConfig: r.kataConfig,
+	}
+	if err := rollout.update(nodesList.Items, doneNodes, failedNodes); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Nodes with the kata binaries join the kata-oc pool, the MachineConfig
+	// adds the CRI-O configuration and the reboot activates the binaries
+	binariesInstalled := status.InstallationStatus.InProgress.BinariesInstalledNodesList
+	for _, nodeName := range binariesInstalled {
+		if err := setNodeLabels(r.Client, nodeName, map[string]string{kataRolloutPoolLabel: "true"}); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	for _, nodeName := range status.RollbackStatus.CompletedNodesList {
+		if err := removeNodeLabels(r.Client, nodeName, []string{kataRolloutPoolLabel}); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if len(binariesInstalled) > 0 {
+		configured, err := r.monitorKataConfigInstallation()
+		if err != nil || !configured {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if status.InstallationStatus.Completed.CompletedNodesCount > 0 && status.RuntimeClass == "" {
+		if res, err := r.setRuntimeClass(); err != nil {
+			return res, err
+		}
+	}
+
+	validated := false
+	if status.RuntimeClass != "" {
+		validated, err = r.newNodeValidator().validate(status.InstallationStatus.Completed.CompletedNodesList)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if validated && status.InstallationStatus.Completed.CompletedNodesCount == r.kataNodesCount() {
+		r.Log.Info("Kata rollout completed on all nodes")
+		return ctrl.Result{}, r.deleteKataDaemonset(InstallOperation)
+	}
+
+	if status.RolloutStatus.Paused {
+		r.Log.Info("Kata rollout is paused", "reason", status.RolloutStatus.Reason)
+	}
+
+	if status.RuntimeClass != "" && !validated {
+		// pod updates trigger a reconcile too, this only catches validation pods that never start
+		return ctrl.Result{Requeue: true, RequeueAfter: 30 * time.Second}, nil
+	}
+
+	// The daemon updates the status as nodes progress and the pool events bring
+	// the rollout back here, there is no need to poll
+	return ctrl.Result{}, nil
+}
+
 func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.Result, error) {
 	r.Log.Info("KataConfig deletion in progress: ")
 	machinePool, err := r.workerOrMaster()
 	if err != nil {
-		return reconcile.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
+		return reconcile.Result{}, err
 	}
 
 	if contains(r.kataConfig.GetFinalizers(), kataConfigFinalizer) {
-		// Get the list of pods that might be running using kata runtime
-		err := r.listKataPods()
+		// Kata is only uninstalled once no pod uses it anymore
+		result, err := r.reconcileBlockingPods()
 		if err != nil {
-			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, err
+			return ctrl.Result{}, err
+		}
+		if result != nil {
+			return *result, nil
 		}
 
 		ds := r.processDaemonsetForCR(UninstallOperation)
+		if err := controllerutil.SetControllerReference(r.kataConfig, ds, r.Scheme); err != nil {
+			return ctrl.Result{}, err
+		}
 
 		foundDs := &appsv1.DaemonSet{}
 		err = r.Client.Get(context.TODO(), types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace}, foundDs)
@@ -616,6 +985,16 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 			return ctrl.Result{}, err
 		}
 
+		// Regular workloads may go to the nodes again once kata is gone from them
+		uninstalled := append(append([]string{}, r.kataConfig.Status.UnInstallationStatus.InProgress.BinariesUnInstalledNodesList...),
+			r.kataConfig.Status.UnInstallationStatus.Completed.CompletedNodesList...)
+		if err := removeNodeTaints(r.Client, uninstalled); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := removeKataNodeLabels(r.Client, uninstalled); err != nil {
+			return ctrl.Result{}, err
+		}
+
 		if r.kataConfig.Status.UnInstallationStatus.Completed.CompletedNodesCount != r.kataConfig.Status.TotalNodesCount {
 			r.Log.Info("KataConfig uninstallation: ", "Number of nodes completed uninstallation ",
 				r.kataConfig.Status.UnInstallationStatus.Completed.CompletedNodesCount,
@@ -635,6 +1014,14 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 						continue
 					}
 
+					// The rollout labels keep the node in the kata-oc pool even if the pool selector is a node role
+					if r.kataConfig.Spec.RolloutStrategy != nil {
+						r.Log.Info("Removing the kata rollout labels from the node", "node name ", nodeName)
+						if err := removeNodeLabels(r.Client, nodeName, rolloutLabels()); err != nil {
+							return ctrl.Result{}, err
+						}
+					}
+
 					if _, ok := r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels["node-role.kubernetes.io/"+machinePool]; !ok {
 						r.Log.Info("Removing the kata pool selector label from the node", "node name ", nodeName)
 						node, err := r.clientset.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
@@ -660,7 +1047,7 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 		}
 
 		r.Log.Info("Making sure parent MCP is synced properly, KataNodeRole=" + machinePool)
-		if _, ok := r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels["node-role.kubernetes.io/"+machinePool]; ok {
+		if r.usesParentPool(machinePool) {
 			mc, err := r.newMCForCR(machinePool)
 			var isMcDeleted bool
 
@@ -675,14 +1062,13 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 				err = r.Client.Delete(context.TODO(), mc)
 				if err != nil {
 					// error during removing mc, don't block the uninstall. Just log the error and move on.
-					r.Log.Info("Error found deleting machine config. If the machine config exists after installation it can be safely deleted manually.",
+					r.Log.Info("Error found deleting machine config. The orphan sweeper removes the machine config once the KataConfig is gone.",
 						"mc", mc.Name, "error", err)
 				}
-				// Sleep for MCP to reflect the changes
-				r.Log.Info("Pausing for a minute to make sure worker mcp has started syncing up")
-				time.Sleep(60 * time.Second)
 			}
 
+			// The pool events bring us back once the pool rendered its configuration without the kata
+			// MachineConfig and all its machines were updated
 			workreMcp := &mcfgv1.MachineConfigPool{}
 			err = r.Client.Get(context.TODO(), types.NamespacedName{Name: machinePool}, workreMcp)
 			if err != nil {
@@ -690,35 +1076,38 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 			}
 			r.Log.Info("Monitoring worker mcp", "worker mcp name", workreMcp.Name, "ready machines", workreMcp.Status.ReadyMachineCount,
 				"total machines", workreMcp.Status.MachineCount)
-			if workreMcp.Status.ReadyMachineCount != workreMcp.Status.MachineCount {
-				return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+			if machineConfigRendered(workreMcp, mc.Name) || !machineConfigPoolReady(workreMcp) {
+				return ctrl.Result{}, nil
 			}
 		} else {
-			// Sleep for MCP to reflect the changes
+			// The daemon updates the status once it uninstalled the binaries, that brings us back here
 			if len(r.kataConfig.Status.UnInstallationStatus.InProgress.BinariesUnInstalledNodesList) > 0 {
-				r.Log.Info("Pausing for a minute to make sure parent mcp has started syncing up")
-				time.Sleep(60 * time.Second)
-
 				parentMcp := &mcfgv1.MachineConfigPool{}
 
 				err := r.Client.Get(context.TODO(), types.NamespacedName{Name: machinePool}, parentMcp)
 				if err != nil && errors.IsNotFound(err) {
-					return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, fmt.Errorf("Not able to find parent pool %s", parentMcp.GetName())
+					return ctrl.Result{}, fmt.Errorf("Not able to find parent pool %s", machinePool)
 				} else if err != nil {
 					return ctrl.Result{}, err
 				}
 
+				// The pool events bring us back once the nodes moved back to the parent pool
 				r.Log.Info("Monitoring parent mcp", "parent mcp name", parentMcp.Name, "ready machines", parentMcp.Status.ReadyMachineCount,
 					"total machines", parentMcp.Status.MachineCount)
-				if parentMcp.Status.ReadyMachineCount != parentMcp.Status.MachineCount {
-					return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+				backInParentPool, err := r.nodesBackInParentPool(parentMcp,
+					r.kataConfig.Status.UnInstallationStatus.InProgress.BinariesUnInstalledNodesList)
+				if err != nil {
+					return ctrl.Result{}, err
+				}
+				if !backInParentPool || !machineConfigPoolReady(parentMcp) {
+					return ctrl.Result{}, nil
 				}
 
 				mcp := r.newMCPforCR()
 				err = r.Client.Delete(context.TODO(), mcp)
 				if err != nil {
 					// error during removing mcp, don't block the uninstall. Just log the error and move on.
-					r.Log.Info("Error found deleting mcp. If the mcp exists after installation it can be safely deleted manually.",
+					r.Log.Info("Error found deleting mcp. The orphan sweeper removes the mcp once the KataConfig is gone.",
 						"mcp", mcp.Name, "error", err)
 				}
 
@@ -726,11 +1115,11 @@ func (r *KataConfigOpenShiftReconciler) processKataConfigDeleteRequest() (ctrl.R
 				err = r.Client.Delete(context.TODO(), mc)
 				if err != nil {
 					// error during removing mc, don't block the uninstall. Just log the error and move on.
-					r.Log.Info("Error found deleting machine config. If the machine config exists after installation it can be safely deleted manually.",
+					r.Log.Info("Error found deleting machine config. The orphan sweeper removes the machine config once the KataConfig is gone.",
 						"mc", mc.Name, "error", err)
 				}
 			} else {
-				return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+				return ctrl.Result{}, nil
 			}
 		}
 
@@ -787,127 +1176,107 @@ func (r *KataConfigOpenShiftReconciler) deleteKataDaemonset(operation DaemonOper
 	return nil
 }
 
-func (r *KataConfigOpenShiftReconciler) monitorKataConfigInstallation() (ctrl.Result, error) {
+// monitorKataConfigInstallation creates the kata pool and, once its machines are ready, the MachineConfig
+// with the CRI-O configuration. It returns true once the MachineConfig exists.
+func (r *KataConfigOpenShiftReconciler) monitorKataConfigInstallation() (bool, error) {
 	r.Log.Info("installation is complete on targetted nodes, now dropping in crio config using MCO")
 	machinePool, err := r.workerOrMaster()
 	if err != nil {
-		return reconcile.Result{}, err
+		return false, err
 	}
 
-	if _, ok := r.kataConfig.Spec.KataConfigPoolSelector.MatchLabels["node-role.kubernetes.io/"+machinePool]; !ok {
+	if !r.usesParentPool(machinePool) {
 		r.Log.Info("creating new Mcp")
 		mcp := r.newMCPforCR()
 
 		founcMcp := &mcfgv1.MachineConfigPool{}
 		err := r.Client.Get(context.TODO(), types.NamespacedName{Name: mcp.Name}, founcMcp)
 		if err != nil && errors.IsNotFound(err) {
+			if err := controllerutil.SetControllerReference(r.kataConfig, mcp, r.Scheme); err != nil {
+				return false, err
+			}
 			r.Log.Info("Creating a new Machine Config Pool ", "mcp.Name", mcp.Name)
 			err = r.Client.Create(context.TODO(), mcp)
 			if err != nil {
-				return ctrl.Result{}, err
+				return false, err
 			}
-			// mcp created successfully - requeue to check the status later
-			return ctrl.Result{Requeue: true, RequeueAfter: 20 * time.Second}, nil
+			// mcp created successfully - the pool status updates bring us back
+			return false, nil
 		} else if err != nil {
-			return ctrl.Result{}, err
+			return false, err
 		}
 
-		// Wait till MCP is ready
+		// Wait till MCP is ready, the pool status updates bring us back
 		if founcMcp.Status.MachineCount == 0 {
 			r.Log.Info("Waiting till Machine Config Pool is initialized ", "mcp.Name", mcp.Name)
-			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+			return false, nil
 		}
-		if founcMcp.Status.MachineCount != founcMcp.Status.ReadyMachineCount {
+		if !machineConfigPoolReady(founcMcp) {
 			r.Log.Info("Waiting till Machine Config Pool is ready ", "mcp.Name", mcp.Name)
-			return ctrl.Result{Requeue: true, RequeueAfter: 15 * time.Second}, nil
+			return false, nil
 		}
 	}
 
 	r.Log.Info("KataNodeRole is: " + machinePool)
 	mc, err := r.newMCForCR(machinePool)
 	if err != nil {
-		return ctrl.Result{}, err
+		return false, err
 	}
 
 	foundMc := &mcfgv1.MachineConfig{}
 	err = r.Client.Get(context.TODO(), types.NamespacedName{Name: mc.Name}, foundMc)
 	if err != nil && errors.IsNotFound(err) {
+		if err := controllerutil.SetControllerReference(r.kataConfig, mc, r.Scheme); err != nil {
+			return false, err
+		}
 		r.Log.Info("Creating a new Machine Config ", "mc.Name", mc.Name)
 		err = r.Client.Create(context.TODO(), mc)
 		if err != nil {
-			return ctrl.Result{}, err
+			return false, err
 		}
-		// mc created successfully - don't requeue
-		return ctrl.Result{}, nil
 	} else if err != nil {
-		return ctrl.Result{}, err
+		return false, err
 	}
 
-	return ctrl.Result{}, nil
+	return true, nil
 }
 
 func (r *KataConfigOpenShiftReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&kataconfigurationv1.KataConfig{}).
+		Owns(&corev1.Pod{}).
+		Owns(&appsv1.DaemonSet{}).
+		Owns(&nodeapi.RuntimeClass{}).
+		Owns(&mcfgv1.MachineConfig{}).
+		Watches(
+			&source.Kind{Type: &mcfgv1.MachineConfigPool{}},
+			&handler.EnqueueRequestsFromMapFunc{
+				ToRequests: handler.ToRequestsFunc(r.kataConfigsForPool),
+			}).
+		Watches(
+			&source.Kind{Type: &corev1.Node{}},
+			&handler.EnqueueRequestsFromMapFunc{
+				ToRequests: handler.ToRequestsFunc(r.retryRequestsForNode),
+			}).
+		Watches(
+			&source.Kind{Type: &corev1.Node{}},
+			&handler.EnqueueRequestsFromMapFunc{
+				ToRequests: handler.ToRequestsFunc(r.allKataConfigsForNode),
+			},
+			builder.WithPredicates(kataNodeLabelsChanged())).
 		Complete(r)
 }
 
-func (r *KataConfigOpenShiftReconciler) isOldestCR() (bool, error) {
-	kataConfigList := &kataconfigurationv1.KataConfigList{}
-	listOpts := []client.ListOption{
-		client.InNamespace(corev1.NamespaceAll),
-	}
-	if err := r.Client.List(context.TODO(), kataConfigList, listOpts...); err != nil {
-		return false, fmt.Errorf("Failed to list KataConfig custom resources: %v", err)
-	}
-
-	if len(kataConfigList.Items) == 1 {
-		return true, nil
-	}
-
-	// Creation time of the CR of the current reconciliation request
-	tkccd := r.kataConfig.GetCreationTimestamp()
-
-	// holds the oldest CR found so far
-	var oldestCR *kataconfigurationv1.KataConfig
-
-	for index := range kataConfigList.Items {
-		if kataConfigList.Items[index].Name == r.kataConfig.Name {
-			continue
-		}
-
-		// Creation time of this instance of CR in the loop
-		ckccd := kataConfigList.Items[index].GetCreationTimestamp()
-
-		if oldestCR == nil {
-			oldestCR = &kataConfigList.Items[index]
-		} else {
-			oldestCreationDateSoFar := oldestCR.GetCreationTimestamp()
-			if !oldestCreationDateSoFar.Before(&ckccd) {
-				oldestCR = &kataConfigList.Items[index]
-			}
-		}
-	}
-
-	oldestCRCreationDate := oldestCR.GetCreationTimestamp()
-	if !tkccd.Before(&oldestCRCreationDate) {
-		if r.kataConfig.Status.InstallationStatus.Failed.FailedNodesCount != -1 {
-			r.kataConfig.Status.InstallationStatus.Failed.FailedNodesCount = -1
-			r.kataConfig.Status.InstallationStatus.Failed.FailedNodesList = []kataconfigurationv1.FailedNodeStatus{
-				{
-					Name:  "",
-					Error: fmt.Sprintf("Multiple KataConfig CRs are not supported, %s already exists", oldestCR.Name),
-				},
-			}
-
-			err := r.Client.Status().Update(context.TODO(), r.kataConfig)
-			if err != nil {
-				return false, err
-			}
+// allKataConfigsForNode reconciles all KataConfigs, the kata labels of the node got changed
+func (r *KataConfigOpenShiftReconciler) allKataConfigsForNode(o handler.MapObject) []reconcile.Request {
+	return r.allKataConfigRequests()
+}
 
-			return false, nil
-		}
+// retryRequestsForNode reconciles all KataConfigs once the retry annotation is put on a node
+func (r *KataConfigOpenShiftReconciler) retryRequestsForNode(o handler.MapObject) []reconcile.Request {
+	if _, ok := o.Meta.GetAnnotations()[kataRetryAnnotation]; !ok {
+		return nil
 	}
 
-	return true, nil
+	return r.allKataConfigRequests()
 }
@@ -13,7 +13,7 @@ import (
 
 var _ = Describe("OpenShift KataConfig Controller", func() {
 	Context("KataConfig create", func() {
-		It("Should not support multiple KataConfig CRs", func() {
+		It("Should not support multiple KataConfig CRs with overlapping node selectors", func() {
 
 			const (
 				name = "example-kataconfig"
@@ -142,5 +142,73 @@ var _ = Describe("OpenShift KataConfig Controller", func() {
 
 		})
 	})
+	Context("Multiple KataConfig create", func() {
+		It("Should support multiple KataConfig CRs with disjoint node selectors", func() {
+
+			newKataConfig := func(name string, kata string) *kataconfigurationv1.KataConfig {
+				return &kataconfigurationv1.KataConfig{
+					TypeMeta: metav1.TypeMeta{
+						APIVersion: "kataconfiguration.openshift.io/v1",
+						Kind:       "KataConfig",
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name: name,
+					},
+					Spec: kataconfigurationv1.KataConfigSpec{
+						KataConfigPoolSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"kata": kata},
+						},
+					},
+				}
+			}
+
+			// waitForPool returns the KataConfig once it got a pool assigned or got rejected
+			waitForPool := func(kataconfig *kataconfigurationv1.KataConfig) {
+				key := types.NamespacedName{Name: kataconfig.Name}
+				Eventually(func() bool {
+					if err := k8sClient.Get(context.Background(), key, kataconfig); err != nil {
+						return false
+					}
+					return kataconfig.Status.PoolName != "" || rejectedKataConfig(kataconfig)
+				}, 10, time.Second).Should(BeTrue())
+			}
+
+			qemu := newKataConfig("qemu-kataconfig", "qemu")
+			fc := newKataConfig("fc-kataconfig", "fc")
+			overlapping := newKataConfig("qemu2-kataconfig", "qemu")
+
+			By("Creating the KataConfig CRs with disjoint node selectors successfully")
+			Expect(k8sClient.Create(context.Background(), qemu)).Should(Succeed())
+			waitForPool(qemu)
+			Expect(k8sClient.Create(context.Background(), fc)).Should(Succeed())
+			waitForPool(fc)
+
+			By("Assigning each KataConfig CR its own pool and runtime class")
+			Expect(rejectedKataConfig(qemu)).Should(BeFalse())
+			Expect(rejectedKataConfig(fc)).Should(BeFalse())
+			Expect(fc.Status.PoolName).ShouldNot(Equal(qemu.Status.PoolName))
+			qemuRuntimeClass := (&KataConfigOpenShiftReconciler{kataConfig: qemu}).runtimeClassName()
+			fcRuntimeClass := (&KataConfigOpenShiftReconciler{kataConfig: fc}).runtimeClassName()
+			Expect(fcRuntimeClass).ShouldNot(Equal(qemuRuntimeClass))
+
+			By("Rejecting a KataConfig CR whose node selector overlaps with an existing one")
+			Expect(k8sClient.Create(context.Background(), overlapping)).Should(Succeed())
+			waitForPool(overlapping)
+			Expect(overlapping.Status.InstallationStatus.Failed.FailedNodesCount).Should(Equal(overlappingKataConfigCount))
+
+			By("Deleting the KataConfig CRs successfully")
+			for _, kataconfig := range []*kataconfigurationv1.KataConfig{overlapping, fc, qemu} {
+				key := types.NamespacedName{Name: kataconfig.Name}
+				Eventually(func() error {
+					k8sClient.Get(context.Background(), key, kataconfig)
+					return k8sClient.Delete(context.Background(), kataconfig)
+				}, 5, time.Second).Should(Succeed())
+
+				Eventually(func() error {
+					return k8sClient.Get(context.Background(), key, kataconfig)
+				}, 5, time.Second).ShouldNot(Succeed())
+			}
+		})
+	})
 
 })
@@ -0,0 +1,144 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	nodeapi "k8s.io/api/node/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// cpuOverheadPerVCPU is the CPU overhead per vCPU of the sandbox VM, the upstream
+	// kata-deploy runtime class uses 250m for the single vCPU of the default VM
+	cpuOverheadPerVCPU = 250
+
+	// sandboxBaseMemoryMiB covers the hypervisor, virtiofsd and the kata shim processes
+	sandboxBaseMemoryMiB = 100
+
+	// guestMemoryOverheadDivisor takes one 32nd of the VM memory for the guest kernel and
+	// the memory map of the hypervisor, which grow with the VM memory
+	guestMemoryOverheadDivisor = 32
+)
+
+// sandboxOverhead returns the pod overhead of the runtime class and how it was derived. An overhead set
+// in the spec is used as is, otherwise it is computed from the largest sandbox VM the nodes reported,
+// since the runtime class serves all of them. The VM settings in the spec replace the reported ones.
+func sandboxOverhead(kataConfig *kataconfigurationv1.KataConfig) kataconfigurationv1.KataOverheadStatus {
+	overhead := kataconfigurationv1.KataOverheadStatus{
+		NodesList: kataConfig.Status.OverheadStatus.NodesList,
+	}
+
+	spec := kataConfig.Spec.Overhead
+	if spec != nil && len(spec.PodFixed) > 0 {
+		overhead.PodFixed = spec.PodFixed.DeepCopy()
+		overhead.Source = kataconfigurationv1.OverheadSourceSpec
+		overhead.Derivation = "Taken from spec.overhead.podFixed"
+		return overhead
+	}
+
+	var memory, vcpus int32
+	memorySource, vcpusSource := "kata default", "kata default"
+	for _, n := range overhead.NodesList {
+		if n.DefaultMemoryMiB > memory {
+			memory = n.DefaultMemoryMiB
+			memorySource = "default_memory of node " + n.Name
+		}
+		if n.DefaultVCPUs > vcpus {
+			vcpus = n.DefaultVCPUs
+			vcpusSource = "default_vcpus of node " + n.Name
+		}
+	}
+	if spec != nil && spec.DefaultMemoryMiB > 0 {
+		memory = spec.DefaultMemoryMiB
+		memorySource = "spec.overhead.defaultMemoryMiB"
+	}
+	if spec != nil && spec.DefaultVCPUs > 0 {
+		vcpus = spec.DefaultVCPUs
+		vcpusSource = "spec.overhead.defaultVCPUs"
+	}
+
+	if memory == 0 && vcpus == 0 {
+		// Use same values for Pod Overhead as upstream kata-deploy using, see
+		// https://github.com/kata-containers/packaging/blob/f17450317563b6e4d6b1a71f0559360b37783e19/kata-deploy/k8s-1.18/kata-runtimeClasses.yaml#L7
+		overhead.PodFixed = corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("250m"),
+			corev1.ResourceMemory: resource.MustParse("160Mi"),
+		}
+		overhead.Source = kataconfigurationv1.OverheadSourceDefault
+		overhead.Derivation = "No node reported its sandbox VM settings yet, using the overhead of the upstream kata-deploy runtime class"
+		return overhead
+	}
+	if memory == 0 {
+		memory = kataconfigurationv1.KataDefaultMemoryMiB
+	}
+	if vcpus == 0 {
+		vcpus = kataconfigurationv1.KataDefaultVCPUs
+	}
+
+	memoryOverhead := sandboxBaseMemoryMiB + (memory+guestMemoryOverheadDivisor-1)/guestMemoryOverheadDivisor
+	overhead.PodFixed = corev1.ResourceList{
+		corev1.ResourceCPU:    *resource.NewMilliQuantity(int64(vcpus)*cpuOverheadPerVCPU, resource.DecimalSI),
+		corev1.ResourceMemory: resource.MustParse(fmt.Sprintf("%dMi", memoryOverhead)),
+	}
+	overhead.Source = kataconfigurationv1.OverheadSourceComputed
+	overhead.Derivation = fmt.Sprintf("cpu: %d vCPUs (%s) x %dm, memory: %dMi + %dMiB VM memory (%s) / %d",
+		vcpus, vcpusSource, cpuOverheadPerVCPU, sandboxBaseMemoryMiB, memory, memorySource, guestMemoryOverheadDivisor)
+	return overhead
+}
+
+// updateOverhead records the pod overhead in the status and updates the runtime class once the
+// overhead changed, e.g. because more nodes reported their VM settings. Running pods keep the
+// overhead they were admitted with, only new pods get the new one.
+func (r *KataConfigOpenShiftReconciler) updateOverhead() error {
+	overhead := sandboxOverhead(r.kataConfig)
+	if equality.Semantic.DeepEqual(overhead, r.kataConfig.Status.OverheadStatus) {
+		return nil
+	}
+
+	r.Log.Info("Pod overhead of the runtime class changed", "overhead", overhead.PodFixed, "derivation", overhead.Derivation)
+	r.kataConfig.Status.OverheadStatus = overhead
+	if err := r.Client.Status().Update(context.TODO(), r.kataConfig); err != nil {
+		return err
+	}
+
+	// Other changes of the runtime class are left to the drift reconcile
+	if r.kataConfig.Status.RuntimeClass == "" {
+		return nil
+	}
+	foundRc := &nodeapi.RuntimeClass{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: r.runtimeClassName()}, foundRc)
+	if err != nil && errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if foundRc.Overhead != nil && equality.Semantic.DeepEqual(foundRc.Overhead.PodFixed, overhead.PodFixed) {
+		return nil
+	}
+
+	r.Log.Info("Updating the pod overhead of the RuntimeClass", "rc.Name", foundRc.Name)
+	foundRc.Overhead = &nodeapi.Overhead{PodFixed: overhead.PodFixed}
+	return r.Client.Update(context.TODO(), foundRc)
+}
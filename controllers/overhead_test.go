@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestSandboxOverhead(t *testing.T) {
+	nodes := []kataconfigurationv1.NodeSandboxConfig{
+		{Name: "worker-0", DefaultMemoryMiB: 4096, DefaultVCPUs: 1},
+		{Name: "worker-1", DefaultMemoryMiB: 2048, DefaultVCPUs: 2},
+	}
+
+	for _, test := range []struct {
+		name   string
+		spec   *kataconfigurationv1.KataOverheadConfig
+		nodes  []kataconfigurationv1.NodeSandboxConfig
+		cpu    string
+		memory string
+		source kataconfigurationv1.KataOverheadSource
+	}{
+		{"upstream default", nil, nil, "250m", "160Mi", kataconfigurationv1.OverheadSourceDefault},
+		{
+			"spec",
+			&kataconfigurationv1.KataOverheadConfig{PodFixed: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("1"),
+				corev1.ResourceMemory: resource.MustParse("1Gi"),
+			}},
+			nodes, "1", "1Gi", kataconfigurationv1.OverheadSourceSpec,
+		},
+		{"largest node", nil, nodes, "500m", "228Mi", kataconfigurationv1.OverheadSourceComputed},
+		{"spec memory", &kataconfigurationv1.KataOverheadConfig{DefaultMemoryMiB: 8192}, nodes, "500m", "356Mi", kataconfigurationv1.OverheadSourceComputed},
+		{"spec vcpus only", &kataconfigurationv1.KataOverheadConfig{DefaultVCPUs: 4}, nil, "1", "164Mi", kataconfigurationv1.OverheadSourceComputed},
+		{
+			"memory rounded up",
+			nil,
+			[]kataconfigurationv1.NodeSandboxConfig{{Name: "worker-0", DefaultMemoryMiB: 2050, DefaultVCPUs: 1}},
+			"250m", "165Mi", kataconfigurationv1.OverheadSourceComputed,
+		},
+	} {
+		kataConfig := &kataconfigurationv1.KataConfig{
+			Spec:   kataconfigurationv1.KataConfigSpec{Overhead: test.spec},
+			Status: kataconfigurationv1.KataConfigStatus{OverheadStatus: kataconfigurationv1.KataOverheadStatus{NodesList: test.nodes}},
+		}
+
+		overhead := sandboxOverhead(kataConfig)
+		cpu, memory := overhead.PodFixed[corev1.ResourceCPU], overhead.PodFixed[corev1.ResourceMemory]
+		if cpu.Cmp(resource.MustParse(test.cpu)) != 0 || memory.Cmp(resource.MustParse(test.memory)) != 0 {
+			t.Errorf("%s: got cpu %s, memory %s, want %s, %s", test.name, cpu.String(), memory.String(), test.cpu, test.memory)
+		}
+		if overhead.Source != test.source || overhead.Derivation == "" {
+			t.Errorf("%s: got source %s derived by %q, want %s", test.name, overhead.Source, overhead.Derivation, test.source)
+		}
+		if len(overhead.NodesList) != len(test.nodes) {
+			t.Errorf("%s: reported sandbox settings not kept: %+v", test.name, overhead.NodesList)
+		}
+	}
+}
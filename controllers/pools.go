@@ -0,0 +1,207 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// defaultKataPoolName is the MachineConfigPool of the first KataConfig
+	defaultKataPoolName = "kata-oc"
+
+	// overlappingKataConfigCount marks a KataConfig rejected because its node selector
+	// overlaps with the selector of an older KataConfig
+	overlappingKataConfigCount = -1
+)
+
+// poolName returns the name of the kata MachineConfigPool of the KataConfig
+func (r *KataConfigOpenShiftReconciler) poolName() string {
+	if r.kataConfig.Status.PoolName == "" {
+		return defaultKataPoolName
+	}
+	return r.kataConfig.Status.PoolName
+}
+
+// nameSuffix is appended to the names of the resources created for the KataConfig,
+// it is empty for the first KataConfig so that it keeps the original names
+func (r *KataConfigOpenShiftReconciler) nameSuffix() string {
+	return strings.TrimPrefix(r.poolName(), defaultKataPoolName)
+}
+
+func (r *KataConfigOpenShiftReconciler) daemonsetName(operation DaemonOperation) string {
+	return "sandboxed-containers-operator-daemon-" + string(operation) + r.nameSuffix()
+}
+
+func (r *KataConfigOpenShiftReconciler) machineConfigName() string {
+	return "50-kata-crio-dropin" + r.nameSuffix()
+}
+
+func (r *KataConfigOpenShiftReconciler) runtimeClassName() string {
+	return "kata" + r.nameSuffix()
+}
+
+// assignPoolName picks the pool name of a new KataConfig. The first KataConfig gets kata-oc,
+// KataConfigs that predate the pool name and are older than this one are treated as owning it.
+func (r *KataConfigOpenShiftReconciler) assignPoolName() error {
+	if r.kataConfig.Status.PoolName != "" {
+		return nil
+	}
+
+	kataConfigList := &kataconfigurationv1.KataConfigList{}
+	if err := r.Client.List(context.TODO(), kataConfigList); err != nil {
+		return fmt.Errorf("Failed to list KataConfig custom resources: %v", err)
+	}
+
+	poolName := defaultKataPoolName
+	for i := range kataConfigList.Items {
+		kc := &kataConfigList.Items[i]
+		if kc.Name == r.kataConfig.Name || rejectedKataConfig(kc) {
+			continue
+		}
+		if kc.Status.PoolName == defaultKataPoolName ||
+			(kc.Status.PoolName == "" && olderKataConfig(kc, r.kataConfig)) {
+			poolName = defaultKataPoolName + "-" + r.kataConfig.Name
+			break
+		}
+	}
+
+	r.Log.Info("Assigning kata machine config pool", "pool", poolName)
+	r.kataConfig.Status.PoolName = poolName
+	return r.Client.Status().Update(context.TODO(), r.kataConfig)
+}
+
+// checkOverlappingCRs returns true if the node selector of an older KataConfig overlaps with the
+// selector of this KataConfig. The KataConfig is marked as failed then and not processed further.
+func (r *KataConfigOpenShiftReconciler) checkOverlappingCRs() (bool, error) {
+	kataConfigList := &kataconfigurationv1.KataConfigList{}
+	if err := r.Client.List(context.TODO(), kataConfigList); err != nil {
+		return false, fmt.Errorf("Failed to list KataConfig custom resources: %v", err)
+	}
+
+	failed := &r.kataConfig.Status.InstallationStatus.Failed
+	for i := range kataConfigList.Items {
+		kc := &kataConfigList.Items[i]
+		if kc.Name == r.kataConfig.Name || rejectedKataConfig(kc) || !olderKataConfig(kc, r.kataConfig) {
+			continue
+		}
+		if selectorsDisjoint(kc.Spec.KataConfigPoolSelector, r.kataConfig.Spec.KataConfigPoolSelector) {
+			continue
+		}
+
+		r.Log.Info("KataConfig node selector overlaps with an existing KataConfig", "existing", kc.Name)
+		if failed.FailedNodesCount == overlappingKataConfigCount {
+			return true, nil
+		}
+		failed.FailedNodesCount = overlappingKataConfigCount
+		failed.FailedNodesList = []kataconfigurationv1.FailedNodeStatus{
+			{
+				Name:  "",
+				Error: fmt.Sprintf("The node selector overlaps with the node selector of KataConfig %s, multiple KataConfig CRs need disjoint node selectors", kc.Name),
+			},
+		}
+		return true, r.Client.Status().Update(context.TODO(), r.kataConfig)
+	}
+
+	// The overlapping KataConfig is gone, start over
+	if failed.FailedNodesCount == overlappingKataConfigCount {
+		r.kataConfig.Status.InstallationStatus.Failed = kataconfigurationv1.KataFailedNodeStatus{}
+		if err := r.Client.Status().Update(context.TODO(), r.kataConfig); err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+func rejectedKataConfig(kc *kataconfigurationv1.KataConfig) bool {
+	return kc.Status.InstallationStatus.Failed.FailedNodesCount == overlappingKataConfigCount
+}
+
+// olderKataConfig returns true if a was created before b, the name breaks ties
+func olderKataConfig(a, b *kataconfigurationv1.KataConfig) bool {
+	ta, tb := a.GetCreationTimestamp(), b.GetCreationTimestamp()
+	if ta.Equal(&tb) {
+		return a.Name < b.Name
+	}
+	return ta.Before(&tb)
+}
+
+// selectorsDisjoint returns true if no node can match both selectors. Selectors are only
+// considered disjoint if a label key is constrained by both in a way no label value satisfies.
+// A missing selector selects the worker nodes.
+func selectorsDisjoint(a, b *metav1.LabelSelector) bool {
+	for _, ra := range selectorRequirements(a) {
+		for _, rb := range selectorRequirements(b) {
+			if ra.Key == rb.Key && requirementsConflict(ra, rb) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func selectorRequirements(selector *metav1.LabelSelector) []metav1.LabelSelectorRequirement {
+	if selector == nil {
+		selector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{"node-role.kubernetes.io/worker": ""},
+		}
+	}
+
+	reqs := append([]metav1.LabelSelectorRequirement{}, selector.MatchExpressions...)
+	for k, v := range selector.MatchLabels {
+		reqs = append(reqs, metav1.LabelSelectorRequirement{
+			Key:      k,
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   []string{v},
+		})
+	}
+	return reqs
+}
+
+// requirementsConflict returns true if no value of the label satisfies both requirements on the same key
+func requirementsConflict(a, b metav1.LabelSelectorRequirement) bool {
+	if a.Operator == metav1.LabelSelectorOpDoesNotExist {
+		a, b = b, a
+	}
+
+	switch a.Operator {
+	case metav1.LabelSelectorOpIn:
+		switch b.Operator {
+		case metav1.LabelSelectorOpIn:
+			return !sets.NewString(a.Values...).HasAny(b.Values...)
+		case metav1.LabelSelectorOpNotIn:
+			return sets.NewString(b.Values...).HasAll(a.Values...)
+		case metav1.LabelSelectorOpDoesNotExist:
+			return true
+		}
+	case metav1.LabelSelectorOpNotIn:
+		if b.Operator == metav1.LabelSelectorOpIn {
+			return sets.NewString(a.Values...).HasAll(b.Values...)
+		}
+	case metav1.LabelSelectorOpExists:
+		return b.Operator == metav1.LabelSelectorOpDoesNotExist
+	}
+
+	return false
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSelectorsDisjoint(t *testing.T) {
+	matchLabels := func(labels map[string]string) *metav1.LabelSelector {
+		return &metav1.LabelSelector{MatchLabels: labels}
+	}
+	matchExpression := func(key string, op metav1.LabelSelectorOperator, values ...string) *metav1.LabelSelector {
+		return &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: key, Operator: op, Values: values},
+		}}
+	}
+
+	for _, test := range []struct {
+		name     string
+		a, b     *metav1.LabelSelector
+		disjoint bool
+	}{
+		{"both default", nil, nil, false},
+		{"default and custom label", nil, matchLabels(map[string]string{"kata": "true"}), false},
+		{"same labels", matchLabels(map[string]string{"kata": "qemu"}), matchLabels(map[string]string{"kata": "qemu"}), false},
+		{"different values", matchLabels(map[string]string{"kata": "qemu"}), matchLabels(map[string]string{"kata": "fc"}), true},
+		{"different keys", matchLabels(map[string]string{"qemu": ""}), matchLabels(map[string]string{"fc": ""}), false},
+		{"one differing key", matchLabels(map[string]string{"zone": "a", "kata": "qemu"}), matchLabels(map[string]string{"zone": "a", "kata": "fc"}), true},
+		{"in overlapping", matchExpression("kata", metav1.LabelSelectorOpIn, "qemu", "fc"), matchLabels(map[string]string{"kata": "fc"}), false},
+		{"in disjoint", matchExpression("kata", metav1.LabelSelectorOpIn, "qemu", "clh"), matchLabels(map[string]string{"kata": "fc"}), true},
+		{"not in all values", matchLabels(map[string]string{"kata": "fc"}), matchExpression("kata", metav1.LabelSelectorOpNotIn, "fc"), true},
+		{"not in other values", matchLabels(map[string]string{"kata": "fc"}), matchExpression("kata", metav1.LabelSelectorOpNotIn, "qemu"), false},
+		{"exists and does not exist", matchExpression("kata", metav1.LabelSelectorOpExists), matchExpression("kata", metav1.LabelSelectorOpDoesNotExist), true},
+		{"does not exist and in", matchExpression("kata", metav1.LabelSelectorOpDoesNotExist), matchLabels(map[string]string{"kata": "fc"}), true},
+		{"not in both", matchExpression("kata", metav1.LabelSelectorOpNotIn, "qemu"), matchExpression("kata", metav1.LabelSelectorOpNotIn, "fc"), false},
+	} {
+		if disjoint := selectorsDisjoint(test.a, test.b); disjoint != test.disjoint {
+			t.Errorf("%s: got %v, want %v", test.name, disjoint, test.disjoint)
+		}
+		if disjoint := selectorsDisjoint(test.b, test.a); disjoint != test.disjoint {
+			t.Errorf("%s reversed: got %v, want %v", test.name, disjoint, test.disjoint)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// kataRetryAnnotation requests a retry of failed nodes
+	kataRetryAnnotation = kataconfigurationv1.RetryAnnotation
+
+	retryTriggerAnnotation = "Annotation"
+	retryTriggerAutomatic  = "Automatic"
+
+	defaultRetryBackoff = 60 * time.Second
+	maxRetryBackoff     = time.Hour
+)
+
+// kataRetrier clears the failure of nodes the kata installation failed on and
+// restarts the installation daemon on them, so that the installation runs again
+type kataRetrier struct {
+	client     client.Client
+	log        logr.Logger
+	kataConfig *kataconfigurationv1.KataConfig
+
+	// namespace and dsName identify the daemonset whose pods are restarted
+	namespace string
+	dsName    string
+	// nodeSelector selects the nodes of the KataConfig, the retry annotation of other
+	// nodes is left for the KataConfig they belong to
+	nodeSelector labels.Selector
+}
+
+// process retries the failed nodes requested through the retry annotation and the failed nodes
+// due for an automatic retry. It returns the time until the next automatic retry, zero if none is scheduled.
+func (rt *kataRetrier) process() (time.Duration, error) {
+	requested, annotatedNodes, err := rt.requestedNodes()
+	if err != nil {
+		return 0, err
+	}
+
+	status := &rt.kataConfig.Status
+	now := metav1.Now()
+	var retried []string
+	var nextRetry time.Duration
+	statusChanged := false
+
+	for _, fn := range append([]kataconfigurationv1.FailedNodeStatus{}, status.InstallationStatus.Failed.FailedNodesList...) {
+		if contains(requested, fn.Name) {
+			rt.retry(fn, retryTriggerAnnotation, now)
+			retried = append(retried, fn.Name)
+			statusChanged = true
+			continue
+		}
+
+		policy := rt.kataConfig.Spec.RetryPolicy
+		if policy == nil {
+			continue
+		}
+
+		history := rt.history(fn.Name)
+		attempts := automaticAttempts(history)
+		if attempts >= policy.MaxAttempts {
+			continue
+		}
+
+		if history.NextRetryTime == nil {
+			next := metav1.NewTime(now.Add(retryBackoff(policy, attempts)))
+			history.NextRetryTime = &next
+			statusChanged = true
+			rt.log.Info("Scheduling automatic retry of failed node", "node", fn.Name, "at", next)
+		}
+
+		wait := history.NextRetryTime.Sub(now.Time)
+		if wait > 0 {
+			if nextRetry == 0 || wait < nextRetry {
+				nextRetry = wait
+			}
+			continue
+		}
+
+		rt.retry(fn, retryTriggerAutomatic, now)
+		retried = append(retried, fn.Name)
+		statusChanged = true
+	}
+
+	if statusChanged {
+		if err := rt.client.Status().Update(context.TODO(), rt.kataConfig); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, nodeName := range retried {
+		rt.log.Info("Restarting the installation daemon to retry the node", "node", nodeName)
+		if err := deleteDaemonPod(rt.client, rt.namespace, rt.dsName, nodeName); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := rt.clearAnnotations(annotatedNodes, retried); err != nil {
+		return 0, err
+	}
+
+	return nextRetry, nil
+}
+
+// requestedNodes returns the nodes requested through the retry annotation of the KataConfig
+// or of the nodes, and separately the nodes carrying the annotation
+func (rt *kataRetrier) requestedNodes() ([]string, []string, error) {
+	var requested []string
+	for _, n := range strings.Split(rt.kataConfig.GetAnnotations()[kataRetryAnnotation], ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			requested = append(requested, n)
+		}
+	}
+
+	nodesList := &corev1.NodeList{}
+	if err := rt.client.List(context.TODO(), nodesList); err != nil {
+		return nil, nil, err
+	}
+
+	var annotatedNodes []string
+	for _, node := range nodesList.Items {
+		if _, ok := node.GetAnnotations()[kataRetryAnnotation]; ok {
+			annotatedNodes = append(annotatedNodes, node.Name)
+			requested = append(requested, node.Name)
+		}
+	}
+
+	return requested, annotatedNodes, nil
+}
+
+// retry removes the failure of the node from the status, the node is installed again once
+// the daemon restarts. A rolled back node is no longer left out of the kata pool.
+func (rt *kataRetrier) retry(failed kataconfigurationv1.FailedNodeStatus, trigger string, now metav1.Time) {
+	status := &rt.kataConfig.Status
+
+	removeFailedNode(&status.InstallationStatus.Failed, failed.Name)
+	removeFailedNode(&status.RollbackStatus.Failed, failed.Name)
+	status.RollbackStatus.CompletedNodesList = removeNode(status.RollbackStatus.CompletedNodesList, failed.Name)
+	status.RollbackStatus.RequestedNodesList = removeNode(status.RollbackStatus.RequestedNodesList, failed.Name)
+
+	history := rt.history(failed.Name)
+	history.Attempts = append(history.Attempts, kataconfigurationv1.RetryAttempt{
+		Time:    now,
+		Trigger: trigger,
+		Error:   failed.Error,
+	})
+	history.NextRetryTime = nil
+}
+
+// history returns the retry history of the node, it is added to the status if the node has none yet
+func (rt *kataRetrier) history(nodeName string) *kataconfigurationv1.NodeRetryStatus {
+	status := &rt.kataConfig.Status.RetryStatus
+	for i := range status.Nodes {
+		if status.Nodes[i].Name == nodeName {
+			return &status.Nodes[i]
+		}
+	}
+
+	status.Nodes = append(status.Nodes, kataconfigurationv1.NodeRetryStatus{Name: nodeName})
+	return &status.Nodes[len(status.Nodes)-1]
+}
+
+// clearAnnotations removes the retry annotation from the KataConfig and from the annotated nodes once the
+// retry is done. Only the annotation of the retried nodes and of the nodes selected by the KataConfig is removed.
+func (rt *kataRetrier) clearAnnotations(annotatedNodes []string, retried []string) error {
+	if _, ok := rt.kataConfig.GetAnnotations()[kataRetryAnnotation]; ok {
+		patch := client.MergeFrom(rt.kataConfig.DeepCopy())
+		annotations := rt.kataConfig.GetAnnotations()
+		delete(annotations, kataRetryAnnotation)
+		rt.kataConfig.SetAnnotations(annotations)
+		if err := rt.client.Patch(context.TODO(), rt.kataConfig, patch); err != nil {
+			return err
+		}
+	}
+
+	for _, nodeName := range annotatedNodes {
+		node := &corev1.Node{}
+		if err := rt.client.Get(context.TODO(), client.ObjectKey{Name: nodeName}, node); err != nil {
+			return err
+		}
+		if !contains(retried, nodeName) && (rt.nodeSelector == nil || !rt.nodeSelector.Matches(labels.Set(node.GetLabels()))) {
+			continue
+		}
+		patch := client.MergeFrom(node.DeepCopy())
+		annotations := node.GetAnnotations()
+		delete(annotations, kataRetryAnnotation)
+		node.SetAnnotations(annotations)
+		if err := rt.client.Patch(context.TODO(), node, patch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func automaticAttempts(history *kataconfigurationv1.NodeRetryStatus) int {
+	attempts := 0
+	for _, a := range history.Attempts {
+		if a.Trigger == retryTriggerAutomatic {
+			attempts++
+		}
+	}
+	return attempts
+}
+
+// retryBackoff returns the delay before the next automatic retry, it doubles with every attempt
+func retryBackoff(policy *kataconfigurationv1.KataRetryPolicy, attempts int) time.Duration {
+	backoff := defaultRetryBackoff
+	if policy.BackoffSeconds > 0 {
+		backoff = time.Duration(policy.BackoffSeconds) * time.Second
+	}
+
+	for i := 0; i < attempts && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	return backoff
+}
+
+func removeNode(nodeNames []string, nodeName string) []string {
+	for i, n := range nodeNames {
+		if n == nodeName {
+			return append(nodeNames[:i], nodeNames[i+1:]...)
+		}
+	}
+	return nodeNames
+}
+
+func removeFailedNode(failed *kataconfigurationv1.KataFailedNodeStatus, nodeName string) {
+	for i, fn := range failed.FailedNodesList {
+		if fn.Name == nodeName {
+			failed.FailedNodesList = append(failed.FailedNodesList[:i], failed.FailedNodesList[i+1:]...)
+			break
+		}
+	}
+	failed.FailedNodesCount = len(failed.FailedNodesList)
+}
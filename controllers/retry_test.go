@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	for _, test := range []struct {
+		backoffSeconds int
+		attempts       int
+		backoff        time.Duration
+	}{
+		{0, 0, defaultRetryBackoff},
+		{0, 1, 2 * defaultRetryBackoff},
+		{0, 3, 8 * defaultRetryBackoff},
+		{30, 0, 30 * time.Second},
+		{30, 2, 2 * time.Minute},
+		{0, 10, maxRetryBackoff},
+		{7200, 0, maxRetryBackoff},
+	} {
+		policy := &kataconfigurationv1.KataRetryPolicy{MaxAttempts: 5, BackoffSeconds: test.backoffSeconds}
+		if backoff := retryBackoff(policy, test.attempts); backoff != test.backoff {
+			t.Errorf("retryBackoff(%ds, %d) = %v, want %v", test.backoffSeconds, test.attempts, backoff, test.backoff)
+		}
+	}
+}
+
+func TestAutomaticAttempts(t *testing.T) {
+	for _, test := range []struct {
+		triggers []string
+		attempts int
+	}{
+		{nil, 0},
+		{[]string{retryTriggerAnnotation}, 0},
+		{[]string{retryTriggerAutomatic, retryTriggerAnnotation, retryTriggerAutomatic}, 2},
+	} {
+		history := &kataconfigurationv1.NodeRetryStatus{Name: "worker-0"}
+		for _, trigger := range test.triggers {
+			history.Attempts = append(history.Attempts, kataconfigurationv1.RetryAttempt{Trigger: trigger})
+		}
+		if attempts := automaticAttempts(history); attempts != test.attempts {
+			t.Errorf("automaticAttempts(%v) = %d, want %d", test.triggers, attempts, test.attempts)
+		}
+	}
+}
+
+func TestRetryKeepsAnnotationOfOtherKataConfigs(t *testing.T) {
+	kataConfig := &kataconfigurationv1.KataConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "qemu-kataconfig"},
+		Status: kataconfigurationv1.KataConfigStatus{
+			InstallationStatus: kataconfigurationv1.KataInstallationStatus{
+				Failed: kataconfigurationv1.KataFailedNodeStatus{
+					FailedNodesCount: 1,
+					FailedNodesList:  []kataconfigurationv1.FailedNodeStatus{{Name: "qemu-0", Error: "exit status 1"}},
+				},
+			},
+		},
+	}
+	newNode := func(name string, pool string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{"pool": pool},
+			Annotations: map[string]string{kataRetryAnnotation: "true"},
+		}}
+	}
+	c := newTestClient(t, kataConfig, newNode("qemu-0", "qemu"), newNode("qemu-1", "qemu"), newNode("fc-0", "fc"))
+
+	rt := &kataRetrier{
+		client:       c,
+		log:          ctrl.Log.WithName("test"),
+		kataConfig:   kataConfig,
+		namespace:    "sandboxed-containers-operator-system",
+		dsName:       "sandboxed-containers-operator-daemon-install",
+		nodeSelector: labels.SelectorFromSet(labels.Set{"pool": "qemu"}),
+	}
+	if _, err := rt.process(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(kataConfig.Status.InstallationStatus.Failed.FailedNodesList) != 0 {
+		t.Errorf("failed node not retried: %+v", kataConfig.Status.InstallationStatus.Failed)
+	}
+	for nodeName, annotated := range map[string]bool{"qemu-0": false, "qemu-1": false, "fc-0": true} {
+		node := &corev1.Node{}
+		if err := c.Get(context.TODO(), client.ObjectKey{Name: nodeName}, node); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := node.GetAnnotations()[kataRetryAnnotation]; ok != annotated {
+			t.Errorf("node %s annotated: %v, want %v", nodeName, ok, annotated)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestProcessRollbackRequests(t *testing.T) {
+	kataConfig := &kataconfigurationv1.KataConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig"},
+		Spec: kataconfigurationv1.KataConfigSpec{
+			RollbackPolicy: kataconfigurationv1.RollbackPolicyManual,
+			RollbackNodes:  []string{"worker-0", "worker-1"},
+		},
+		Status: kataconfigurationv1.KataConfigStatus{
+			InstallationStatus: kataconfigurationv1.KataInstallationStatus{
+				Failed: kataconfigurationv1.KataFailedNodeStatus{
+					FailedNodesCount: 1,
+					FailedNodesList:  []kataconfigurationv1.FailedNodeStatus{{Name: "worker-0", Error: "exit status 1"}},
+				},
+			},
+			RollbackStatus: kataconfigurationv1.KataRollbackStatus{
+				// Left over from a request that got withdrawn
+				RequestedNodesList: []string{"worker-2"},
+			},
+		},
+	}
+	r := &KataConfigOpenShiftReconciler{Log: ctrl.Log.WithName("test"), kataConfig: kataConfig}
+	newDaemonPod := func() *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "daemon-worker-0",
+				Namespace: r.daemonConfig().Namespace,
+				Labels:    map[string]string{"name": r.daemonsetName(InstallOperation)},
+			},
+			Spec: corev1.PodSpec{NodeName: "worker-0"},
+		}
+	}
+	r.Client = newTestClient(t, kataConfig, newDaemonPod())
+
+	if err := r.processRollbackRequests(); err != nil {
+		t.Fatal(err)
+	}
+	pod := newDaemonPod()
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, pod); !errors.IsNotFound(err) {
+		t.Errorf("daemon pod of the failed node not deleted: %v", err)
+	}
+	if requested := kataConfig.Status.RollbackStatus.RequestedNodesList; !reflect.DeepEqual(requested, []string{"worker-0"}) {
+		t.Errorf("got requested nodes %v, want the failed rollback node", requested)
+	}
+
+	// The restarted daemon is rolling back the node, it must not be restarted again
+	if err := r.Client.Create(context.TODO(), newDaemonPod()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.processRollbackRequests(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, pod); err != nil {
+		t.Errorf("daemon pod deleted again during the rollback: %v", err)
+	}
+}
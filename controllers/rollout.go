@@ -0,0 +1,155 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/go-logr/logr"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// kataRolloutReleasedLabel marks the nodes released to the rollout, the install daemonset only runs on them
+	kataRolloutReleasedLabel = "kataconfiguration.openshift.io/rollout-released"
+
+	// kataRolloutPoolLabel marks the released nodes that have the kata binaries installed,
+	// only these nodes are part of the kata-oc machine config pool
+	kataRolloutPoolLabel = "kataconfiguration.openshift.io/rollout-pool"
+)
+
+// kataRollout releases the nodes selected by a KataConfig batch by batch to the kata installation
+type kataRollout struct {
+	client     client.Client
+	log        logr.Logger
+	kataConfig *kataconfigurationv1.KataConfig
+}
+
+// update releases the next batch of nodes once all released nodes are either done or failed, unless
+// the rollout is paused. The rollout pauses once the number of failed nodes reaches the failure threshold.
+func (ro *kataRollout) update(nodes []corev1.Node, doneNodes []string, failedNodes []string) error {
+	strategy := ro.kataConfig.Spec.RolloutStrategy
+	status := &ro.kataConfig.Status.RolloutStatus
+	oldStatus := status.DeepCopy()
+
+	failures := 0
+	batchDone := true
+	for _, nodeName := range status.ReleasedNodesList {
+		if contains(failedNodes, nodeName) {
+			failures++
+		} else if !contains(doneNodes, nodeName) {
+			batchDone = false
+		}
+	}
+
+	threshold := strategy.FailureThreshold
+	if threshold == 0 {
+		threshold = 1
+	}
+
+	status.Paused = false
+	status.Reason = ""
+	switch {
+	case strategy.Paused:
+		status.Paused = true
+		status.Reason = "Rollout is paused by the rollout strategy"
+	case failures >= threshold:
+		status.Paused = true
+		status.Reason = fmt.Sprintf("%d failed nodes reached the failure threshold of %d", failures, threshold)
+	case batchDone:
+		batch, err := ro.nextBatch(nodes)
+		if err != nil {
+			return err
+		}
+		if len(batch) > 0 {
+			ro.log.Info("Releasing the next batch of nodes to the kata rollout", "batch", status.CurrentBatch+1, "nodes", batch)
+			status.ReleasedNodesList = append(status.ReleasedNodesList, batch...)
+			status.CurrentBatch++
+		}
+	}
+
+	// The label is applied to all released nodes on every pass in case a node got re-created
+	for _, nodeName := range status.ReleasedNodesList {
+		if err := setNodeLabels(ro.client, nodeName, map[string]string{kataRolloutReleasedLabel: "true"}); err != nil {
+			return err
+		}
+	}
+
+	if !reflect.DeepEqual(oldStatus, status) {
+		return ro.client.Status().Update(context.TODO(), ro.kataConfig)
+	}
+
+	return nil
+}
+
+// nextBatch picks the nodes to be released next, the canary nodes make up the first batch
+func (ro *kataRollout) nextBatch(nodes []corev1.Node) ([]string, error) {
+	strategy := ro.kataConfig.Spec.RolloutStrategy
+	released := ro.kataConfig.Status.RolloutStatus.ReleasedNodesList
+
+	var candidates []string
+	for _, node := range nodes {
+		if !contains(released, node.Name) {
+			candidates = append(candidates, node.Name)
+		}
+	}
+	sort.Strings(candidates)
+
+	size := len(candidates)
+	if len(released) == 0 && strategy.CanaryNodes > 0 {
+		size = strategy.CanaryNodes
+	} else if strategy.BatchSize != nil {
+		batchSize, err := intstr.GetValueFromIntOrPercent(strategy.BatchSize, len(nodes), true)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rollout batch size: %v", err)
+		}
+		if batchSize > 0 {
+			size = batchSize
+		}
+	}
+	if size > len(candidates) {
+		size = len(candidates)
+	}
+
+	return candidates[:size], nil
+}
+
+// rolloutLabels returns the labels the rollout put on the nodes
+func rolloutLabels() []string {
+	return []string{kataRolloutReleasedLabel, kataRolloutPoolLabel}
+}
+
+// withRolloutLabel returns a copy of the node selector extended by the given rollout label
+// if the KataConfig uses a rollout strategy
+func withRolloutLabel(kataConfig *kataconfigurationv1.KataConfig, nodeSelector map[string]string, label string) map[string]string {
+	if kataConfig.Spec.RolloutStrategy == nil {
+		return nodeSelector
+	}
+
+	selector := map[string]string{}
+	for k, v := range nodeSelector {
+		selector[k] = v
+	}
+	selector[label] = "true"
+	return selector
+}
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func newTestNodes(names ...string) []corev1.Node {
+	var nodes []corev1.Node
+	for _, name := range names {
+		nodes = append(nodes, corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	return nodes
+}
+
+func TestNextBatch(t *testing.T) {
+	nodes := newTestNodes("worker-3", "worker-0", "worker-2", "worker-1")
+	percent := intstr.FromString("50%")
+	one := intstr.FromInt(1)
+	invalid := intstr.FromString("half")
+
+	for _, test := range []struct {
+		name     string
+		strategy kataconfigurationv1.KataRolloutStrategy
+		released []string
+		batch    []string
+		valid    bool
+	}{
+		{"all at once", kataconfigurationv1.KataRolloutStrategy{}, nil, []string{"worker-0", "worker-1", "worker-2", "worker-3"}, true},
+		{"canary", kataconfigurationv1.KataRolloutStrategy{CanaryNodes: 1, BatchSize: &percent}, nil, []string{"worker-0"}, true},
+		{"more canaries than nodes", kataconfigurationv1.KataRolloutStrategy{CanaryNodes: 5}, nil, []string{"worker-0", "worker-1", "worker-2", "worker-3"}, true},
+		{"batch after canary", kataconfigurationv1.KataRolloutStrategy{CanaryNodes: 1, BatchSize: &percent}, []string{"worker-0"}, []string{"worker-1", "worker-2"}, true},
+		{"last batch", kataconfigurationv1.KataRolloutStrategy{BatchSize: &percent}, []string{"worker-0", "worker-1", "worker-3"}, []string{"worker-2"}, true},
+		{"fixed batch size", kataconfigurationv1.KataRolloutStrategy{BatchSize: &one}, []string{"worker-0"}, []string{"worker-1"}, true},
+		{"all released", kataconfigurationv1.KataRolloutStrategy{BatchSize: &one}, []string{"worker-0", "worker-1", "worker-2", "worker-3"}, []string{}, true},
+		{"invalid batch size", kataconfigurationv1.KataRolloutStrategy{BatchSize: &invalid}, []string{"worker-0"}, nil, false},
+	} {
+		strategy := test.strategy
+		ro := &kataRollout{kataConfig: &kataconfigurationv1.KataConfig{
+			Spec:   kataconfigurationv1.KataConfigSpec{RolloutStrategy: &strategy},
+			Status: kataconfigurationv1.KataConfigStatus{RolloutStatus: kataconfigurationv1.KataRolloutStatus{ReleasedNodesList: test.released}},
+		}}
+
+		batch, err := ro.nextBatch(nodes)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if test.valid && len(batch)+len(test.batch) > 0 && !reflect.DeepEqual(batch, test.batch) {
+			t.Errorf("%s: got batch %v, want %v", test.name, batch, test.batch)
+		}
+	}
+}
+
+func TestWithRolloutLabel(t *testing.T) {
+	nodeSelector := map[string]string{"node-role.kubernetes.io/worker": ""}
+
+	kataConfig := &kataconfigurationv1.KataConfig{}
+	if selector := withRolloutLabel(kataConfig, nodeSelector, kataRolloutPoolLabel); !reflect.DeepEqual(selector, nodeSelector) {
+		t.Errorf("selector %v changed without a rollout strategy", selector)
+	}
+
+	kataConfig.Spec.RolloutStrategy = &kataconfigurationv1.KataRolloutStrategy{}
+	selector := withRolloutLabel(kataConfig, nodeSelector, kataRolloutPoolLabel)
+	expected := map[string]string{"node-role.kubernetes.io/worker": "", kataRolloutPoolLabel: "true"}
+	if !reflect.DeepEqual(selector, expected) {
+		t.Errorf("got selector %v, want %v", selector, expected)
+	}
+	if len(nodeSelector) != 1 {
+		t.Errorf("node selector %v got modified", nodeSelector)
+	}
+}
+
+func TestKubernetesRolloutPausesOnFailure(t *testing.T) {
+	kataConfig := &kataconfigurationv1.KataConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig"},
+		Spec: kataconfigurationv1.KataConfigSpec{
+			RolloutStrategy: &kataconfigurationv1.KataRolloutStrategy{CanaryNodes: 1},
+		},
+		Status: kataconfigurationv1.KataConfigStatus{
+			RolloutStatus: kataconfigurationv1.KataRolloutStatus{ReleasedNodesList: []string{"worker-0"}, CurrentBatch: 1},
+		},
+	}
+	workerLabels := map[string]string{"node-role.kubernetes.io/worker": ""}
+	worker0 := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Labels: workerLabels}}
+	worker1 := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-1", Labels: workerLabels}}
+
+	r := &KataConfigKubernetesReconciler{kataConfig: kataConfig}
+	ds := r.processDaemonset(InstallOperation)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "kata-deploy", Namespace: ds.Namespace, Labels: ds.Spec.Selector.MatchLabels},
+		Spec:       corev1.PodSpec{NodeName: "worker-0"},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{State: corev1.ContainerState{
+			Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+		}}}},
+	}
+	r.Client = newTestClient(t, kataConfig, worker0, worker1, pod)
+
+	if err := r.updateRollout(); err != nil {
+		t.Fatal(err)
+	}
+
+	status := kataConfig.Status.RolloutStatus
+	if !status.Paused || !reflect.DeepEqual(status.ReleasedNodesList, []string{"worker-0"}) {
+		t.Errorf("rollout not paused on the failed canary node: %+v", status)
+	}
+	if kataConfig.Spec.KataConfigPoolSelector != nil {
+		t.Errorf("default node selector written to the spec")
+	}
+}
+
+func TestKataDeployFailed(t *testing.T) {
+	waiting := func(reason string) corev1.PodStatus {
+		return corev1.PodStatus{Phase: corev1.PodPending, ContainerStatuses: []corev1.ContainerStatus{{State: corev1.ContainerState{
+			Waiting: &corev1.ContainerStateWaiting{Reason: reason},
+		}}}}
+	}
+
+	for _, test := range []struct {
+		name   string
+		status corev1.PodStatus
+		failed bool
+	}{
+		{"running", corev1.PodStatus{Phase: corev1.PodRunning}, false},
+		{"creating", waiting("ContainerCreating"), false},
+		{"crash loop", waiting("CrashLoopBackOff"), true},
+		{"image pull", waiting("ImagePullBackOff"), true},
+		{"failed", corev1.PodStatus{Phase: corev1.PodFailed}, true},
+	} {
+		if failed := kataDeployFailed(&corev1.Pod{Status: test.status}); failed != test.failed {
+			t.Errorf("%s: got %v, want %v", test.name, failed, test.failed)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	nodeapi "k8s.io/api/node/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// kataConfigOwnerLabel is put on every object the operator creates for a KataConfig, it holds the
+// name of the KataConfig. Together with the owner references it lets the sweeper find orphans.
+const kataConfigOwnerLabel = kataconfigurationv1.OwnerLabel
+
+// OrphanSweeper periodically deletes the objects the operator created for KataConfigs that
+// no longer exist. They are left behind if a deletion failed during the uninstallation or
+// the operator crashed in the middle of a reconcile.
+type OrphanSweeper struct {
+	Client    client.Client
+	Log       logr.Logger
+	Interval  time.Duration
+	OpenShift bool
+}
+
+var _ manager.Runnable = &OrphanSweeper{}
+var _ manager.LeaderElectionRunnable = &OrphanSweeper{}
+
+// Start sweeps the orphans every interval until the stop channel is closed
+func (s *OrphanSweeper) Start(stop <-chan struct{}) error {
+	wait.Until(func() {
+		if err := s.sweep(); err != nil {
+			s.Log.Error(err, "Failed to sweep orphaned objects")
+		}
+	}, s.Interval, stop)
+	return nil
+}
+
+// NeedLeaderElection makes sure only the active operator instance deletes objects
+func (s *OrphanSweeper) NeedLeaderElection() bool {
+	return true
+}
+
+func (s *OrphanSweeper) sweep() error {
+	kataConfigList := &kataconfigurationv1.KataConfigList{}
+	if err := s.Client.List(context.TODO(), kataConfigList); err != nil {
+		return err
+	}
+	kataConfigs := sets.NewString()
+	for _, kc := range kataConfigList.Items {
+		kataConfigs.Insert(kc.Name)
+	}
+
+	// The MachineConfigs go before the pools, so that the nodes of a pool are configured back first
+	var lists []runtime.Object
+	if s.OpenShift {
+		lists = append(lists, &mcfgv1.MachineConfigList{}, &mcfgv1.MachineConfigPoolList{})
+	}
+	lists = append(lists, &appsv1.DaemonSetList{}, &nodeapi.RuntimeClassList{}, &corev1.PodList{}, &corev1.ConfigMapList{})
+
+	for _, list := range lists {
+		if err := s.Client.List(context.TODO(), list, client.HasLabels{kataConfigOwnerLabel}); err != nil {
+			return err
+		}
+		objs, err := meta.ExtractList(list)
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range objs {
+			accessor, err := meta.Accessor(obj)
+			if err != nil {
+				return err
+			}
+			owner := accessor.GetLabels()[kataConfigOwnerLabel]
+			if kataConfigs.Has(owner) {
+				continue
+			}
+
+			if mcp, ok := obj.(*mcfgv1.MachineConfigPool); ok && mcp.Status.MachineCount > 0 {
+				s.Log.Info("Orphaned machine config pool still has nodes, not deleting it", "mcp.Name", mcp.Name,
+					"kataconfig", owner, "machines", mcp.Status.MachineCount)
+				continue
+			}
+
+			s.Log.Info("Deleting orphaned object", "type", fmt.Sprintf("%T", obj),
+				"namespace", accessor.GetNamespace(), "name", accessor.GetName(), "kataconfig", owner)
+			if err := s.Client.Delete(context.TODO(), obj); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
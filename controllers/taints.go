@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// kataTaintAnnotation holds the taint the operator put on the node, so that it is
+	// removed even if the taint in the KataConfig is changed or dropped
+	kataTaintAnnotation = "kataconfiguration.openshift.io/taint"
+)
+
+// kataTolerations returns the tolerations of the daemons and of the runtime class, they tolerate
+// the taints of the dedicated nodes and the taint the operator puts on the kata nodes
+func kataTolerations(kataConfig *kataconfigurationv1.KataConfig) []corev1.Toleration {
+	tolerations := append([]corev1.Toleration{}, kataConfig.Spec.Tolerations...)
+
+	if taint := kataConfig.Spec.NodeTaint; taint != nil {
+		toleration := corev1.Toleration{
+			Key:      taint.Key,
+			Operator: corev1.TolerationOpExists,
+			Effect:   taint.Effect,
+		}
+		if taint.Value != "" {
+			toleration.Operator = corev1.TolerationOpEqual
+			toleration.Value = taint.Value
+		}
+		tolerations = append(tolerations, toleration)
+	}
+
+	if len(tolerations) == 0 {
+		return nil
+	}
+	return tolerations
+}
+
+// reconcileNodeTaints puts the taint of the KataConfig on the nodes kata is installed on. A taint the
+// operator put on a node earlier and which isn't the one of the KataConfig anymore is removed.
+func reconcileNodeTaints(c client.Client, kataConfig *kataconfigurationv1.KataConfig, nodeNames []string) error {
+	for _, nodeName := range nodeNames {
+		if err := setNodeTaint(c, nodeName, kataConfig.Spec.NodeTaint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeNodeTaints removes the taint the operator put on the nodes
+func removeNodeTaints(c client.Client, nodeNames []string) error {
+	for _, nodeName := range nodeNames {
+		if err := setNodeTaint(c, nodeName, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setNodeTaint makes the taint the only one the operator put on the node, nil removes it.
+// The patch carries the whole list of taints, the resource version makes sure no taint
+// added meanwhile by someone else gets dropped.
+func setNodeTaint(c client.Client, nodeName string, taint *corev1.Taint) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node := &corev1.Node{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Name: nodeName}, node); err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+
+		patch := client.MergeFromWithOptions(node.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		if !updateNodeTaint(node, taint) {
+			return nil
+		}
+		return c.Patch(context.TODO(), node, patch)
+	})
+}
+
+// updateNodeTaint replaces the taint the operator put on the node, it returns false if the node already has it
+func updateNodeTaint(node *corev1.Node, taint *corev1.Taint) bool {
+	changed := false
+
+	if applied := parseTaint(node.Annotations[kataTaintAnnotation]); applied != nil &&
+		(taint == nil || !applied.MatchTaint(taint)) {
+		var taints []corev1.Taint
+		for _, t := range node.Spec.Taints {
+			if !t.MatchTaint(applied) {
+				taints = append(taints, t)
+			}
+		}
+		node.Spec.Taints = taints
+		delete(node.Annotations, kataTaintAnnotation)
+		changed = true
+	}
+
+	if taint != nil {
+		found := false
+		for i := range node.Spec.Taints {
+			if node.Spec.Taints[i].MatchTaint(taint) {
+				found = true
+				if node.Spec.Taints[i].Value != taint.Value {
+					node.Spec.Taints[i].Value = taint.Value
+					changed = true
+				}
+			}
+		}
+		if !found {
+			node.Spec.Taints = append(node.Spec.Taints, corev1.Taint{Key: taint.Key, Value: taint.Value, Effect: taint.Effect})
+			changed = true
+		}
+		if node.Annotations[kataTaintAnnotation] != taint.ToString() {
+			if node.Annotations == nil {
+				node.Annotations = map[string]string{}
+			}
+			node.Annotations[kataTaintAnnotation] = taint.ToString()
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// parseTaint parses a taint in the key=value:effect format of Taint.ToString
+func parseTaint(value string) *corev1.Taint {
+	i := strings.LastIndex(value, ":")
+	if i <= 0 {
+		return nil
+	}
+	taint := &corev1.Taint{Effect: corev1.TaintEffect(value[i+1:])}
+	parts := strings.SplitN(value[:i], "=", 2)
+	taint.Key = parts[0]
+	if len(parts) == 2 {
+		taint.Value = parts[1]
+	}
+	return taint
+}
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestParseTaint(t *testing.T) {
+	for _, test := range []struct {
+		value string
+		taint *corev1.Taint
+	}{
+		{"", nil},
+		{"kata", nil},
+		{":NoSchedule", nil},
+		{"kata:NoSchedule", &corev1.Taint{Key: "kata", Effect: corev1.TaintEffectNoSchedule}},
+		{"kata=true:NoExecute", &corev1.Taint{Key: "kata", Value: "true", Effect: corev1.TaintEffectNoExecute}},
+		{"kata=a=b:NoSchedule", &corev1.Taint{Key: "kata", Value: "a=b", Effect: corev1.TaintEffectNoSchedule}},
+	} {
+		if taint := parseTaint(test.value); !reflect.DeepEqual(taint, test.taint) {
+			t.Errorf("parseTaint(%q) = %+v, want %+v", test.value, taint, test.taint)
+		}
+	}
+
+	// What the operator writes to the annotation reads back the same
+	taint := &corev1.Taint{Key: "kataconfiguration.openshift.io/kata", Value: "true", Effect: corev1.TaintEffectNoSchedule}
+	if parsed := parseTaint(taint.ToString()); !reflect.DeepEqual(parsed, taint) {
+		t.Errorf("parseTaint(%q) = %+v", taint.ToString(), parsed)
+	}
+}
+
+func TestSetNodeTaint(t *testing.T) {
+	other := corev1.Taint{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoSchedule}
+	kata := &corev1.Taint{Key: "kata", Value: "true", Effect: corev1.TaintEffectNoSchedule}
+	node := &corev1.Node{
+		// The API server always sets a resource version, the fake client only does on updates
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-0", ResourceVersion: "1"},
+		Spec:       corev1.NodeSpec{Taints: []corev1.Taint{other}},
+	}
+	c := newTestClient(t, node)
+
+	check := func(step string, taints []corev1.Taint, annotation string) {
+		t.Helper()
+		node := &corev1.Node{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Name: "worker-0"}, node); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(node.Spec.Taints, taints) || node.Annotations[kataTaintAnnotation] != annotation {
+			t.Errorf("%s: got taints %+v annotated %q, want %+v annotated %q",
+				step, node.Spec.Taints, node.Annotations[kataTaintAnnotation], taints, annotation)
+		}
+	}
+
+	if err := setNodeTaint(c, "worker-0", kata); err != nil {
+		t.Fatal(err)
+	}
+	check("taint", []corev1.Taint{other, *kata}, "kata=true:NoSchedule")
+
+	// A changed value replaces the taint rather than adding another one
+	changed := &corev1.Taint{Key: "kata", Value: "sandboxed", Effect: corev1.TaintEffectNoSchedule}
+	if err := setNodeTaint(c, "worker-0", changed); err != nil {
+		t.Fatal(err)
+	}
+	check("changed value", []corev1.Taint{other, *changed}, "kata=sandboxed:NoSchedule")
+
+	// A changed key or effect removes the taint the operator put on the node before
+	effect := &corev1.Taint{Key: "kata", Value: "sandboxed", Effect: corev1.TaintEffectNoExecute}
+	if err := setNodeTaint(c, "worker-0", effect); err != nil {
+		t.Fatal(err)
+	}
+	check("changed effect", []corev1.Taint{other, *effect}, "kata=sandboxed:NoExecute")
+
+	// Only the taint of the operator is removed
+	if err := removeNodeTaints(c, []string{"worker-0", "deleted-node"}); err != nil {
+		t.Fatal(err)
+	}
+	check("removed", []corev1.Taint{other}, "")
+}
+
+// concurrentTaintClient adds a taint to the node right before the first patch of the operator
+type concurrentTaintClient struct {
+	client.Client
+	taint   corev1.Taint
+	patches int
+}
+
+func (c *concurrentTaintClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.patches++
+	if c.patches == 1 {
+		node := &corev1.Node{}
+		if err := c.Client.Get(ctx, client.ObjectKey{Name: obj.(metav1.Object).GetName()}, node); err != nil {
+			return err
+		}
+		node.Spec.Taints = append(node.Spec.Taints, c.taint)
+		if err := c.Client.Update(ctx, node); err != nil {
+			return err
+		}
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func TestSetNodeTaintConflict(t *testing.T) {
+	c := &concurrentTaintClient{
+		Client: newTestClient(t, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", ResourceVersion: "1"}}),
+		taint:  corev1.Taint{Key: "node.kubernetes.io/unschedulable", Effect: corev1.TaintEffectNoSchedule},
+	}
+	kata := &corev1.Taint{Key: "kata", Value: "true", Effect: corev1.TaintEffectNoSchedule}
+	if err := setNodeTaint(c, "worker-0", kata); err != nil {
+		t.Fatal(err)
+	}
+
+	// The stale patch is refused and retried on top of the taint added meanwhile
+	node := &corev1.Node{}
+	if err := c.Get(context.TODO(), client.ObjectKey{Name: "worker-0"}, node); err != nil {
+		t.Fatal(err)
+	}
+	if want := []corev1.Taint{c.taint, *kata}; !reflect.DeepEqual(node.Spec.Taints, want) {
+		t.Errorf("got taints %+v, want %+v", node.Spec.Taints, want)
+	}
+	if c.patches != 2 {
+		t.Errorf("got %d patches, want the conflicting one to be retried", c.patches)
+	}
+}
+
+func TestKubernetesDeleteCleansUpNodes(t *testing.T) {
+	kataConfig := &kataconfigurationv1.KataConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "example-kataconfig",
+			Finalizers: []string{kataConfigFinalizer},
+		},
+		Spec: kataconfigurationv1.KataConfigSpec{
+			NodeTaint: &corev1.Taint{Key: "kata", Effect: corev1.TaintEffectNoSchedule},
+		},
+		Status: kataconfigurationv1.KataConfigStatus{
+			InstallationStatus: kataconfigurationv1.KataInstallationStatus{
+				Completed: kataconfigurationv1.KataConfigCompletedStatus{
+					CompletedNodesCount: 1,
+					CompletedNodesList:  []string{"worker-0"},
+				},
+			},
+		},
+	}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "worker-0",
+			ResourceVersion: "1",
+			Labels:          map[string]string{kataconfigurationv1.KataInstalledLabel: "true"},
+			Annotations:     map[string]string{kataTaintAnnotation: "kata:NoSchedule"},
+		},
+		Spec: corev1.NodeSpec{Taints: []corev1.Taint{{Key: "kata", Effect: corev1.TaintEffectNoSchedule}}},
+	}
+	c := newTestClient(t, kataConfig, node)
+
+	now := metav1.NewTime(time.Now())
+	kataConfig.DeletionTimestamp = &now
+	r := &KataConfigKubernetesReconciler{Client: c, Log: ctrl.Log.WithName("test"), kataConfig: kataConfig}
+	if _, err := r.processKataConfigDeleteRequest(); err != nil {
+		t.Fatal(err)
+	}
+
+	node = &corev1.Node{}
+	if err := c.Get(context.TODO(), client.ObjectKey{Name: "worker-0"}, node); err != nil {
+		t.Fatal(err)
+	}
+	if len(node.Spec.Taints) != 0 {
+		t.Errorf("taint left on the node: %+v", node.Spec.Taints)
+	}
+	if len(node.Labels) != 0 {
+		t.Errorf("labels left on the node: %v", node.Labels)
+	}
+	deleted := &kataconfigurationv1.KataConfig{}
+	if err := c.Get(context.TODO(), client.ObjectKey{Name: kataConfig.Name}, deleted); err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted.Finalizers) != 0 {
+		t.Errorf("finalizer not removed: %v", deleted.Finalizers)
+	}
+}
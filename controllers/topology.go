@@ -0,0 +1,212 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// kataConfigTopologyCondition is true if the KataConfig can't be installed on the cluster topology
+	kataConfigTopologyCondition = "UnsupportedTopology"
+
+	// singleReplicaTopology is the topology value of the infrastructure config for a single node control plane
+	singleReplicaTopology = "SingleReplica"
+)
+
+// controlPlaneNodeLabels mark the control plane nodes, the second one is used by newer releases
+var controlPlaneNodeLabels = []string{"node-role.kubernetes.io/master", "node-role.kubernetes.io/control-plane"}
+
+// +kubebuilder:rbac:groups=config.openshift.io,resources=infrastructures,verbs=get
+
+// detectTopology records the cluster topology in the status. Once kata is being installed on the nodes
+// the topology is kept, switching the machine config pool midway would leave the nodes half configured.
+func (r *KataConfigOpenShiftReconciler) detectTopology() error {
+	if r.kataConfig.Status.Topology.Type != "" && r.kataConfig.Status.TotalNodesCount > 0 {
+		return nil
+	}
+
+	topology, err := r.readTopology()
+	if err != nil {
+		return err
+	}
+	if topology == r.kataConfig.Status.Topology {
+		return nil
+	}
+
+	r.Log.Info("Detected cluster topology", "type", topology.Type, "controlPlaneTopology", topology.ControlPlaneTopology,
+		"machinePool", topology.MachinePool)
+	r.kataConfig.Status.Topology = topology
+	return r.Client.Status().Update(context.TODO(), r.kataConfig)
+}
+
+// readTopology derives the topology from the infrastructure config and the nodes. A cluster without
+// dedicated worker nodes is compact, a compact cluster with a single node control plane is a single node cluster.
+func (r *KataConfigOpenShiftReconciler) readTopology() (kataconfigurationv1.KataTopologyStatus, error) {
+	topology := kataconfigurationv1.KataTopologyStatus{}
+
+	// The vendored openshift/api predates the topology fields, read them without the typed API
+	infra := &unstructured.Unstructured{}
+	infra.SetGroupVersionKind(schema.GroupVersionKind{Group: "config.openshift.io", Version: "v1", Kind: "Infrastructure"})
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: "cluster"}, infra)
+	if err != nil && !errors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+		return topology, err
+	} else if err == nil {
+		topology.ControlPlaneTopology, _, _ = unstructured.NestedString(infra.Object, "status", "controlPlaneTopology")
+		topology.InfrastructureTopology, _, _ = unstructured.NestedString(infra.Object, "status", "infrastructureTopology")
+	}
+
+	nodesList := &corev1.NodeList{}
+	if err := r.Client.List(context.TODO(), nodesList); err != nil {
+		return topology, err
+	}
+	workers := 0
+	for _, node := range nodesList.Items {
+		if !isControlPlaneNode(&node) {
+			workers++
+		}
+	}
+
+	switch {
+	case topology.ControlPlaneTopology == singleReplicaTopology ||
+		(topology.ControlPlaneTopology == "" && len(nodesList.Items) == 1 && workers == 0):
+		topology.Type = kataconfigurationv1.ClusterTopologySingleNode
+		topology.MachinePool = "master"
+	case len(nodesList.Items) > 0 && workers == 0:
+		topology.Type = kataconfigurationv1.ClusterTopologyCompact
+		topology.MachinePool = "master"
+	default:
+		topology.Type = kataconfigurationv1.ClusterTopologyStandard
+		topology.MachinePool = "worker"
+	}
+	return topology, nil
+}
+
+// controlPlaneCluster returns true on compact and single node clusters, where kata goes on the control plane nodes
+func (r *KataConfigOpenShiftReconciler) controlPlaneCluster() bool {
+	t := r.kataConfig.Status.Topology.Type
+	return t == kataconfigurationv1.ClusterTopologyCompact || t == kataconfigurationv1.ClusterTopologySingleNode
+}
+
+// kataNodeSelector returns the node selector of the KataConfig, the nodes of the machine pool if none is set
+func (r *KataConfigOpenShiftReconciler) kataNodeSelector(machinePool string) *metav1.LabelSelector {
+	if r.kataConfig.Spec.KataConfigPoolSelector != nil {
+		return r.kataConfig.Spec.KataConfigPoolSelector
+	}
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{"node-role.kubernetes.io/" + machinePool: ""},
+	}
+}
+
+// checkTopology returns true if the KataConfig would need a kata pool holding control plane nodes.
+// The machine config operator doesn't move control plane nodes out of the master pool, so the kata
+// configuration has to be applied to the master pool itself. The refusal is reported in a condition.
+func (r *KataConfigOpenShiftReconciler) checkTopology() (bool, error) {
+	machinePool, err := r.workerOrMaster()
+	if err != nil {
+		return false, err
+	}
+
+	var message string
+	if r.controlPlaneCluster() && r.kataConfig.Spec.RolloutStrategy != nil {
+		message = fmt.Sprintf("A rollout strategy needs a kata machine config pool, which can't hold the control plane nodes of a %s cluster",
+			r.kataConfig.Status.Topology.Type)
+	} else if !r.usesParentPool(machinePool) {
+		kataOC, err := r.kataOcExists()
+		if err != nil {
+			return false, err
+		}
+
+		// An existing pool was accepted before, don't take it away
+		if !kataOC {
+			nodes, err := r.selectedControlPlaneNodes(machinePool)
+			if err != nil {
+				return false, err
+			}
+			if len(nodes) > 0 {
+				message = fmt.Sprintf("The kata machine config pool would take the control plane nodes %s, select the nodes with node-role.kubernetes.io/%s instead",
+					strings.Join(nodes, ", "), machinePool)
+			}
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:               kataConfigTopologyCondition,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: r.kataConfig.Generation,
+		Reason:             "TopologySupported",
+		Message:            fmt.Sprintf("Kata can be installed on the %s cluster", r.kataConfig.Status.Topology.Type),
+	}
+	if message != "" {
+		r.Log.Info("KataConfig refused on the cluster topology", "reason", message)
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "ControlPlaneNodesInKataPool"
+		condition.Message = message
+	}
+
+	current := meta.FindStatusCondition(r.kataConfig.Status.Conditions, kataConfigTopologyCondition)
+	if current == nil || current.Status != condition.Status || current.Message != condition.Message ||
+		current.ObservedGeneration != condition.ObservedGeneration {
+		meta.SetStatusCondition(&r.kataConfig.Status.Conditions, condition)
+		if err := r.Client.Status().Update(context.TODO(), r.kataConfig); err != nil {
+			return false, err
+		}
+	}
+
+	return message != "", nil
+}
+
+// selectedControlPlaneNodes returns the control plane nodes the KataConfig selects
+func (r *KataConfigOpenShiftReconciler) selectedControlPlaneNodes(machinePool string) ([]string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(r.kataNodeSelector(machinePool))
+	if err != nil {
+		return nil, err
+	}
+	nodesList := &corev1.NodeList{}
+	if err := r.Client.List(context.TODO(), nodesList, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+
+	var nodes []string
+	for _, node := range nodesList.Items {
+		if isControlPlaneNode(&node) {
+			nodes = append(nodes, node.Name)
+		}
+	}
+	return nodes, nil
+}
+
+func isControlPlaneNode(node *corev1.Node) bool {
+	for _, label := range controlPlaneNodeLabels {
+		if _, ok := node.GetLabels()[label]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,245 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	nodeapi "k8s.io/api/node/v1beta1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// kataConfigUninstallBlockedCondition is true while pods using a kata runtime class keep
+	// kata from being uninstalled
+	kataConfigUninstallBlockedCondition = "UninstallBlocked"
+
+	// maxBlockingPods is the number of pods listed in the status, the count covers all of them
+	maxBlockingPods = 50
+
+	defaultEvictionDeadlineSeconds = 600
+
+	// There is no pod event for the pods going away, they are listed again after these intervals
+	blockingPodsRecheckInterval = 30 * time.Second
+	evictionRecheckInterval     = 10 * time.Second
+)
+
+// +kubebuilder:rbac:groups="",resources=pods/eviction,verbs=create
+
+// reconcileBlockingPods records the pods using a kata runtime class of the KataConfig in the
+// uninstallation status and evicts them if the uninstall policy is Evict. It returns the result
+// to requeue with as long as pods are left, nil once kata can be uninstalled.
+func (r *KataConfigOpenShiftReconciler) reconcileBlockingPods() (*ctrl.Result, error) {
+	runtimeClasses, err := kataRuntimeClassNames(r.Client, r.kataConfig)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := blockingPods(r.Client, runtimeClasses)
+	if err != nil {
+		return nil, err
+	}
+
+	original := r.kataConfig.Status.DeepCopy()
+	inProgress := &r.kataConfig.Status.UnInstallationStatus.InProgress
+	condition := metav1.Condition{
+		Type:               kataConfigUninstallBlockedCondition,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: r.kataConfig.Generation,
+		Reason:             "NoKataPods",
+		Message:            "No pod uses a kata runtime class",
+	}
+	requeue := blockingPodsRecheckInterval
+
+	// Eviction errors of earlier attempts are kept once the deadline passed
+	evictionErrors := map[string]string{}
+	for _, pod := range inProgress.BlockingPods {
+		evictionErrors[pod.Namespace+"/"+pod.Name] = pod.EvictionError
+	}
+
+	if len(pods) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "KataPodsRunning"
+		condition.Message = fmt.Sprintf("%d pods use a kata runtime class, delete them or set the uninstall policy to Evict",
+			len(pods))
+
+		if r.kataConfig.Spec.UninstallPolicy == kataconfigurationv1.UninstallPolicyEvict {
+			if inProgress.EvictionStartTime == nil {
+				now := metav1.Now()
+				inProgress.EvictionStartTime = &now
+			}
+			deadline := inProgress.EvictionStartTime.Add(r.evictionDeadline())
+			if time.Now().Before(deadline) {
+				if evictionErrors, err = r.evictPods(pods); err != nil {
+					return nil, err
+				}
+				condition.Reason = "EvictingKataPods"
+				condition.Message = fmt.Sprintf("Evicting %d pods using a kata runtime class until %s",
+					len(pods), deadline.UTC().Format(time.RFC3339))
+				requeue = evictionRecheckInterval
+			} else {
+				condition.Reason = "EvictionDeadlineExceeded"
+				condition.Message = fmt.Sprintf("%d pods using a kata runtime class could not be evicted until %s, delete them by hand",
+					len(pods), deadline.UTC().Format(time.RFC3339))
+			}
+		}
+	}
+
+	inProgress.BlockingPodsCount = len(pods)
+	inProgress.BlockingPods = nil
+	for i := range pods {
+		if i == maxBlockingPods {
+			break
+		}
+		inProgress.BlockingPods = append(inProgress.BlockingPods, kataconfigurationv1.KataBlockingPod{
+			Namespace:     pods[i].Namespace,
+			Name:          pods[i].Name,
+			RuntimeClass:  *pods[i].Spec.RuntimeClassName,
+			Owner:         podOwner(&pods[i]),
+			EvictionError: evictionErrors[pods[i].Namespace+"/"+pods[i].Name],
+		})
+	}
+
+	// The condition is only set once pods got in the way, it keeps the transition time otherwise
+	if len(pods) > 0 || meta.FindStatusCondition(r.kataConfig.Status.Conditions, kataConfigUninstallBlockedCondition) != nil {
+		meta.SetStatusCondition(&r.kataConfig.Status.Conditions, condition)
+	}
+	if !equality.Semantic.DeepEqual(original, &r.kataConfig.Status) {
+		if err := r.Client.Status().Update(context.TODO(), r.kataConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(pods) == 0 {
+		return nil, nil
+	}
+	r.Log.Info("Pods using a kata runtime class block the uninstallation", "pods", len(pods),
+		"reason", condition.Reason)
+	return &ctrl.Result{RequeueAfter: requeue}, nil
+}
+
+// evictionDeadline returns how long the blocking pods are evicted for
+func (r *KataConfigOpenShiftReconciler) evictionDeadline() time.Duration {
+	seconds := r.kataConfig.Spec.EvictionDeadlineSeconds
+	if seconds == 0 {
+		seconds = defaultEvictionDeadlineSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// evictPods evicts the pods through the eviction API, which keeps to their PodDisruptionBudgets.
+// It returns why the eviction of a pod failed by namespace/name.
+func (r *KataConfigOpenShiftReconciler) evictPods(pods []corev1.Pod) (map[string]string, error) {
+	if r.clientset == nil {
+		var err error
+		r.clientset, err = getClientSet()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	evictionErrors := map[string]string{}
+	for _, pod := range pods {
+		// Evicted earlier, the pod is terminating
+		if pod.GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		eviction := &policyv1beta1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+		}
+		err := r.clientset.CoreV1().Pods(pod.Namespace).Evict(context.TODO(), eviction)
+		if err != nil && !errors.IsNotFound(err) {
+			// Too many requests means the PodDisruptionBudget of the pod doesn't allow it to go yet
+			r.Log.Info("Unable to evict pod", "namespace", pod.Namespace, "pod", pod.Name, "error", err)
+			evictionErrors[pod.Namespace+"/"+pod.Name] = err.Error()
+			continue
+		}
+		r.Log.Info("Evicted pod using kata", "namespace", pod.Namespace, "pod", pod.Name)
+	}
+	return evictionErrors, nil
+}
+
+// kataRuntimeClassNames returns the runtime classes of the KataConfig: the ones in its status and
+// the ones labelled with its name, which covers a runtime class created before the status got updated.
+func kataRuntimeClassNames(c client.Client, kataConfig *kataconfigurationv1.KataConfig) ([]string, error) {
+	names := map[string]bool{}
+	for _, name := range kataConfig.Status.RuntimeClassNames() {
+		names[name] = true
+	}
+
+	runtimeClassList := &nodeapi.RuntimeClassList{}
+	if err := c.List(context.TODO(), runtimeClassList, client.MatchingLabels{kataConfigOwnerLabel: kataConfig.Name}); err != nil {
+		return nil, err
+	}
+	for _, runtimeClass := range runtimeClassList.Items {
+		names[runtimeClass.Name] = true
+	}
+
+	var runtimeClasses []string
+	for name := range names {
+		runtimeClasses = append(runtimeClasses, name)
+	}
+	sort.Strings(runtimeClasses)
+	return runtimeClasses, nil
+}
+
+// blockingPods returns the pods using one of the runtime classes, sorted by namespace and name.
+// Pods which ran to completion don't have a VM anymore and don't count.
+func blockingPods(c client.Client, runtimeClasses []string) ([]corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	if err := c.List(context.TODO(), podList, client.InNamespace(corev1.NamespaceAll)); err != nil {
+		return nil, fmt.Errorf("Failed to list kata pods: %v", err)
+	}
+
+	var pods []corev1.Pod
+	for _, pod := range podList.Items {
+		if pod.Spec.RuntimeClassName == nil || !contains(runtimeClasses, *pod.Spec.RuntimeClassName) {
+			continue
+		}
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		pods = append(pods, pod)
+	}
+
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].Namespace != pods[j].Namespace {
+			return pods[i].Namespace < pods[j].Namespace
+		}
+		return pods[i].Name < pods[j].Name
+	})
+	return pods, nil
+}
+
+// podOwner returns the kind and name of the controller of the pod, or "" for a bare pod
+func podOwner(pod *corev1.Pod) string {
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		return owner.Kind + "/" + owner.Name
+	}
+	return ""
+}
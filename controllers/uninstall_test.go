@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	nodeapi "k8s.io/api/node/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newTestKataPod(namespace, name, runtimeClass string, phase corev1.PodPhase) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+	if runtimeClass != "" {
+		pod.Spec.RuntimeClassName = &runtimeClass
+	}
+	return pod
+}
+
+func TestKataRuntimeClassNames(t *testing.T) {
+	kataConfig := &kataconfigurationv1.KataConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig"},
+		Status:     kataconfigurationv1.KataConfigStatus{RuntimeClass: "kata"},
+	}
+	newRuntimeClass := func(name, owner string) *nodeapi.RuntimeClass {
+		return &nodeapi.RuntimeClass{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{kataConfigOwnerLabel: owner}},
+			Handler:    name,
+		}
+	}
+	c := newTestClient(t, kataConfig,
+		newRuntimeClass("kata", "example-kataconfig"),
+		newRuntimeClass("kata-fc", "example-kataconfig"),
+		newRuntimeClass("kata-qemu", "other-kataconfig"))
+
+	names, err := kataRuntimeClassNames(c, kataConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"kata", "kata-fc"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got runtime classes %v, want %v", names, want)
+	}
+
+	// The runtime class is known from the status before it is listed
+	kataConfig.Status.RuntimeClass = "kata,kata-clh"
+	if names, err = kataRuntimeClassNames(c, kataConfig); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"kata", "kata-clh", "kata-fc"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got runtime classes %v, want %v", names, want)
+	}
+}
+
+func TestBlockingPods(t *testing.T) {
+	c := newTestClient(t,
+		newTestKataPod("web", "frontend", "kata", corev1.PodRunning),
+		newTestKataPod("batch", "job", "kata-fc", corev1.PodPending),
+		newTestKataPod("batch", "done", "kata", corev1.PodSucceeded),
+		newTestKataPod("batch", "crashed", "kata", corev1.PodFailed),
+		newTestKataPod("web", "runc", "", corev1.PodRunning),
+		newTestKataPod("web", "gvisor", "gvisor", corev1.PodRunning))
+
+	pods, err := blockingPods(c, []string{"kata", "kata-fc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, pod := range pods {
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
+	if want := []string{"batch/job", "web/frontend"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got blocking pods %v, want %v", names, want)
+	}
+}
+
+func TestPodOwner(t *testing.T) {
+	pod := newTestKataPod("web", "frontend", "kata", corev1.PodRunning)
+	if owner := podOwner(pod); owner != "" {
+		t.Errorf("bare pod owned by %s", owner)
+	}
+
+	controller := true
+	pod.OwnerReferences = []metav1.OwnerReference{
+		{Kind: "ConfigMap", Name: "settings"},
+		{Kind: "ReplicaSet", Name: "frontend-5d8f7c9b4", Controller: &controller},
+	}
+	if owner := podOwner(pod); owner != "ReplicaSet/frontend-5d8f7c9b4" {
+		t.Errorf("got owner %s, want the controller of the pod", owner)
+	}
+}
+
+func TestReconcileBlockingPods(t *testing.T) {
+	kataConfig := &kataconfigurationv1.KataConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig", Generation: 2},
+		Status:     kataconfigurationv1.KataConfigStatus{RuntimeClass: "kata"},
+	}
+	protected := newTestKataPod("web", "protected", "kata", corev1.PodRunning)
+	evicted := newTestKataPod("web", "evicted", "kata", corev1.PodRunning)
+	c := newTestClient(t, kataConfig, protected, evicted)
+
+	// The PodDisruptionBudget of the protected pod doesn't let it go
+	clientset := kubefake.NewSimpleClientset()
+	var evictions []string
+	clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		name := action.(k8stesting.CreateAction).GetObject().(metav1.Object).GetName()
+		evictions = append(evictions, name)
+		if name == protected.Name {
+			return true, nil, apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 10)
+		}
+		return true, nil, apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, name)
+	})
+
+	r := &KataConfigOpenShiftReconciler{
+		Client:     c,
+		Log:        ctrl.Log.WithName("test"),
+		clientset:  clientset,
+		kataConfig: kataConfig,
+	}
+	inProgress := &kataConfig.Status.UnInstallationStatus.InProgress
+	reconcile := func(step string, requeue time.Duration, reason string) {
+		t.Helper()
+		result, err := r.reconcileBlockingPods()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result == nil || result.RequeueAfter != requeue {
+			t.Errorf("%s: got result %+v, want requeue after %v", step, result, requeue)
+		}
+		condition := meta.FindStatusCondition(kataConfig.Status.Conditions, kataConfigUninstallBlockedCondition)
+		if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != reason ||
+			condition.ObservedGeneration != kataConfig.Generation {
+			t.Errorf("%s: got condition %+v, want reason %s", step, condition, reason)
+		}
+		if inProgress.BlockingPodsCount != 2 || len(inProgress.BlockingPods) != 2 {
+			t.Errorf("%s: got blocking pods %+v", step, inProgress.BlockingPods)
+		}
+
+		// The status is stored, not only updated in memory
+		stored := &kataconfigurationv1.KataConfig{}
+		if err := c.Get(context.TODO(), client.ObjectKey{Name: kataConfig.Name}, stored); err != nil {
+			t.Fatal(err)
+		}
+		if stored.Status.UnInstallationStatus.InProgress.BlockingPodsCount != 2 {
+			t.Errorf("%s: blocking pods not stored in the status", step)
+		}
+	}
+
+	// The default policy waits for the pods to be deleted
+	reconcile("block", blockingPodsRecheckInterval, "KataPodsRunning")
+	if len(evictions) != 0 || inProgress.EvictionStartTime != nil {
+		t.Errorf("pods evicted with the Block policy: %v", evictions)
+	}
+
+	kataConfig.Spec.UninstallPolicy = kataconfigurationv1.UninstallPolicyEvict
+	reconcile("evict", evictionRecheckInterval, "EvictingKataPods")
+	if want := []string{"evicted", "protected"}; !reflect.DeepEqual(evictions, want) {
+		t.Errorf("got evictions %v, want %v", evictions, want)
+	}
+	if inProgress.EvictionStartTime == nil {
+		t.Error("eviction start time not recorded")
+	}
+	for _, pod := range inProgress.BlockingPods {
+		if (pod.EvictionError != "") != (pod.Name == protected.Name) {
+			t.Errorf("unexpected eviction error %q for pod %s", pod.EvictionError, pod.Name)
+		}
+	}
+
+	// Once the deadline passed nothing is evicted anymore, the errors of the last attempt are kept
+	evictions = nil
+	started := metav1.NewTime(time.Now().Add(-defaultEvictionDeadlineSeconds * time.Second))
+	inProgress.EvictionStartTime = &started
+	reconcile("deadline", blockingPodsRecheckInterval, "EvictionDeadlineExceeded")
+	if len(evictions) != 0 {
+		t.Errorf("pods evicted after the deadline: %v", evictions)
+	}
+	if inProgress.BlockingPods[1].Name != protected.Name || inProgress.BlockingPods[1].EvictionError == "" {
+		t.Errorf("eviction error not kept after the deadline: %+v", inProgress.BlockingPods)
+	}
+
+	// Kata can be uninstalled once the pods are gone
+	for _, pod := range []*corev1.Pod{protected, evicted} {
+		if err := c.Delete(context.TODO(), pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, err := r.reconcileBlockingPods()
+	if err != nil || result != nil {
+		t.Fatalf("got %+v, %v without blocking pods", result, err)
+	}
+	condition := meta.FindStatusCondition(kataConfig.Status.Conditions, kataConfigUninstallBlockedCondition)
+	if condition == nil || condition.Status != metav1.ConditionFalse || inProgress.BlockingPodsCount != 0 || inProgress.BlockingPods != nil {
+		t.Errorf("blocking pods left in the status: %+v, %+v", condition, inProgress)
+	}
+}
+
+func TestReconcileBlockingPodsNoPods(t *testing.T) {
+	kataConfig := &kataconfigurationv1.KataConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-kataconfig"},
+		Status:     kataconfigurationv1.KataConfigStatus{RuntimeClass: "kata"},
+	}
+	r := &KataConfigOpenShiftReconciler{
+		Client:     newTestClient(t, kataConfig, newTestKataPod("web", "runc", "", corev1.PodRunning)),
+		Log:        ctrl.Log.WithName("test"),
+		kataConfig: kataConfig,
+	}
+
+	result, err := r.reconcileBlockingPods()
+	if err != nil || result != nil {
+		t.Fatalf("got %+v, %v without kata pods", result, err)
+	}
+	// Nothing got in the way, so there is no condition to report
+	if condition := meta.FindStatusCondition(kataConfig.Status.Conditions, kataConfigUninstallBlockedCondition); condition != nil {
+		t.Errorf("unexpected condition %+v", condition)
+	}
+}
@@ -0,0 +1,253 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	validationPodPrefix = "kata-validation-"
+	// maxValidationPodNameLength keeps the name usable as the hostname of the pod
+	maxValidationPodNameLength = 63
+
+	// validationTimeout is how long a validation pod may take before the node is marked as failed
+	validationTimeout = 5 * time.Minute
+)
+
+// kataNodeValidator starts a short-lived kata pod on the nodes kata got installed on to
+// prove that a VM can actually be booted there. The guest kernel reported by the pod has
+// to differ from the kernel of the node, otherwise the pod did not run in a VM.
+type kataNodeValidator struct {
+	client     client.Client
+	scheme     *runtime.Scheme
+	log        logr.Logger
+	kataConfig *kataconfigurationv1.KataConfig
+
+	// namespace the validation pods are created in
+	namespace string
+	// image used for the validation pods, it only needs to provide a shell and uname
+	image string
+	// pullSecrets used to pull the image
+	pullSecrets []corev1.LocalObjectReference
+	// runtimeClassName the validation pods are started with
+	runtimeClassName string
+}
+
+// validate makes sure a validation pod ran on each of the given nodes and records the
+// result in the KataConfig status. It returns true once all nodes have been validated.
+func (v *kataNodeValidator) validate(nodeNames []string) (bool, error) {
+	status := &v.kataConfig.Status.ValidationStatus
+	done := true
+	statusChanged := false
+
+	for _, nodeName := range nodeNames {
+		if contains(status.ReadyNodesList, nodeName) || v.validationFailed(nodeName) {
+			continue
+		}
+
+		pod := v.newValidationPod(nodeName)
+		foundPod := &corev1.Pod{}
+		err := v.client.Get(context.TODO(), types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, foundPod)
+		if err != nil && errors.IsNotFound(err) {
+			if err := controllerutil.SetControllerReference(v.kataConfig, pod, v.scheme); err != nil {
+				return false, err
+			}
+			v.log.Info("Creating kata validation pod", "pod.Name", pod.Name, "node", nodeName)
+			if err := v.client.Create(context.TODO(), pod); err != nil {
+				return false, err
+			}
+			done = false
+			continue
+		} else if err != nil {
+			return false, err
+		}
+
+		validationErr, finished, err := v.checkValidationPod(foundPod, nodeName)
+		if err != nil {
+			return false, err
+		}
+		if !finished {
+			done = false
+			continue
+		}
+
+		if validationErr == "" {
+			v.log.Info("Kata validation succeeded", "node", nodeName)
+			status.ReadyNodesList = append(status.ReadyNodesList, nodeName)
+		} else {
+			v.log.Info("Kata validation failed", "node", nodeName, "error", validationErr)
+			status.Failed.FailedNodesList = append(status.Failed.FailedNodesList, kataconfigurationv1.FailedNodeStatus{
+				Name:  nodeName,
+				Error: validationErr,
+			})
+			status.Failed.FailedNodesCount = len(status.Failed.FailedNodesList)
+		}
+		statusChanged = true
+
+		if err := v.client.Delete(context.TODO(), foundPod); err != nil && !errors.IsNotFound(err) {
+			return false, err
+		}
+	}
+
+	if statusChanged {
+		if err := v.client.Status().Update(context.TODO(), v.kataConfig); err != nil {
+			return false, err
+		}
+	}
+
+	return done, nil
+}
+
+func (v *kataNodeValidator) validationFailed(nodeName string) bool {
+	for _, fn := range v.kataConfig.Status.ValidationStatus.Failed.FailedNodesList {
+		if fn.Name == nodeName {
+			return true
+		}
+	}
+	return false
+}
+
+// checkValidationPod returns the validation error of a finished pod, an empty error means the node is ready
+func (v *kataNodeValidator) checkValidationPod(pod *corev1.Pod, nodeName string) (string, bool, error) {
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+		guestKernel := ""
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.State.Terminated != nil {
+				guestKernel = strings.TrimSpace(cs.State.Terminated.Message)
+			}
+		}
+
+		node := &corev1.Node{}
+		if err := v.client.Get(context.TODO(), types.NamespacedName{Name: nodeName}, node); err != nil {
+			return "", false, err
+		}
+		hostKernel := node.Status.NodeInfo.KernelVersion
+
+		if guestKernel == "" {
+			return "validation pod did not report the guest kernel version", true, nil
+		}
+		if guestKernel == hostKernel {
+			return fmt.Sprintf("validation pod reported the host kernel %s, it did not run in a kata VM", hostKernel), true, nil
+		}
+		return "", true, nil
+
+	case corev1.PodFailed:
+		return "validation pod failed: " + podError(pod), true, nil
+	}
+
+	// Sandbox creation errors leave the pod pending, so give up after a while
+	if time.Since(pod.CreationTimestamp.Time) > validationTimeout {
+		return fmt.Sprintf("validation pod did not complete within %v: %s", validationTimeout, podError(pod)), true, nil
+	}
+
+	return "", false, nil
+}
+
+// podError collects the reasons and messages the pod and its containers report
+func podError(pod *corev1.Pod) string {
+	var msgs []string
+	if pod.Status.Reason != "" || pod.Status.Message != "" {
+		msgs = append(msgs, strings.TrimSpace(pod.Status.Reason+" "+pod.Status.Message))
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Waiting != nil {
+			msgs = append(msgs, strings.TrimSpace(cs.State.Waiting.Reason+" "+cs.State.Waiting.Message))
+		}
+		if cs.State.Terminated != nil {
+			msgs = append(msgs, strings.TrimSpace(fmt.Sprintf("%s (exit code %d) %s",
+				cs.State.Terminated.Reason, cs.State.Terminated.ExitCode, cs.State.Terminated.Message)))
+		}
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Status != corev1.ConditionTrue && c.Message != "" {
+			msgs = append(msgs, c.Message)
+		}
+	}
+	if len(msgs) == 0 {
+		return "phase " + string(pod.Status.Phase)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// validationPodName returns the name of the validation pod of the node. Node names can be longer than
+// a hostname, those get truncated and a hash of the full node name keeps the pod names unique.
+func validationPodName(nodeName string) string {
+	name := validationPodPrefix + nodeName
+	if len(name) <= maxValidationPodNameLength {
+		return name
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(nodeName)))[:8]
+	name = strings.TrimRight(name[:maxValidationPodNameLength-len(hash)-1], "-.")
+	return name + "-" + hash
+}
+
+func (v *kataNodeValidator) newValidationPod(nodeName string) *corev1.Pod {
+	runtimeClassName := v.runtimeClassName
+
+	return &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      validationPodName(nodeName),
+			Namespace: v.namespace,
+			Labels: map[string]string{
+				"app":                "kata-validation",
+				kataConfigOwnerLabel: v.kataConfig.Name,
+			},
+		},
+		Spec: corev1.PodSpec{
+			RuntimeClassName: &runtimeClassName,
+			NodeName:         nodeName,
+			RestartPolicy:    corev1.RestartPolicyNever,
+			ImagePullSecrets: v.pullSecrets,
+			Containers: []corev1.Container{
+				{
+					Name:  "kata-validation",
+					Image: v.image,
+					// The termination message is read back by the controller, no need to fetch logs
+					Command:                  []string{"/bin/sh", "-c", "uname -r > /dev/termination-log"},
+					TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("10m"),
+							corev1.ResourceMemory: resource.MustParse("32Mi"),
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newTestClient returns a fake client knowing the kata and the core types
+func newTestClient(t *testing.T, objs ...runtime.Object) client.Client {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := kataconfigurationv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewFakeClientWithScheme(scheme, objs...)
+}
+
+func TestValidationPodName(t *testing.T) {
+	if name := validationPodName("worker-0"); name != "kata-validation-worker-0" {
+		t.Errorf("unexpected pod name %s", name)
+	}
+
+	long := strings.Repeat("worker.", 36)
+	name := validationPodName(long + "a")
+	if len(name) > maxValidationPodNameLength || !strings.HasPrefix(name, validationPodPrefix+"worker.") {
+		t.Errorf("pod name %s not truncated", name)
+	}
+	if strings.Contains(name, ".-") {
+		t.Errorf("pod name %s ends the truncated node name with a dot", name)
+	}
+	if other := validationPodName(long + "b"); other == name {
+		t.Errorf("nodes with the same prefix got the same pod name %s", name)
+	}
+}
+
+func TestCheckValidationPod(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-0"},
+		Status:     corev1.NodeStatus{NodeInfo: corev1.NodeSystemInfo{KernelVersion: "4.18.0-305.el8.x86_64"}},
+	}
+	v := &kataNodeValidator{
+		client: newTestClient(t, node),
+		log:    ctrl.Log.WithName("test"),
+	}
+
+	terminated := func(message string) []corev1.ContainerStatus {
+		return []corev1.ContainerStatus{{State: corev1.ContainerState{
+			Terminated: &corev1.ContainerStateTerminated{Message: message},
+		}}}
+	}
+
+	for _, test := range []struct {
+		name     string
+		phase    corev1.PodPhase
+		age      time.Duration
+		statuses []corev1.ContainerStatus
+		err      string
+		finished bool
+	}{
+		{"guest kernel", corev1.PodSucceeded, 0, terminated("5.10.25\n"), "", true},
+		{"host kernel", corev1.PodSucceeded, 0, terminated("4.18.0-305.el8.x86_64"), "it did not run in a kata VM", true},
+		{"no kernel", corev1.PodSucceeded, 0, nil, "did not report the guest kernel", true},
+		{"failed", corev1.PodFailed, 0, nil, "validation pod failed: phase Failed", true},
+		{"pending", corev1.PodPending, time.Minute, nil, "", false},
+		{"timed out", corev1.PodPending, validationTimeout + time.Minute, nil, "did not complete within", true},
+	} {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(time.Now().Add(-test.age))},
+			Status:     corev1.PodStatus{Phase: test.phase, ContainerStatuses: test.statuses},
+		}
+
+		validationErr, finished, err := v.checkValidationPod(pod, node.Name)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if finished != test.finished || (test.err == "") != (validationErr == "") || !strings.Contains(validationErr, test.err) {
+			t.Errorf("%s: got %q, %v, want %q, %v", test.name, validationErr, finished, test.err, test.finished)
+		}
+	}
+
+	pod := &corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodSucceeded}}
+	if _, _, err := v.checkValidationPod(pod, "missing"); err == nil {
+		t.Error("no error for a missing node")
+	}
+}
+
+func TestPodError(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		status corev1.PodStatus
+		err    string
+	}{
+		{"no details", corev1.PodStatus{Phase: corev1.PodPending}, "phase Pending"},
+		{"pod reason", corev1.PodStatus{Reason: "Evicted", Message: "low on memory"}, "Evicted low on memory"},
+		{
+			"containers",
+			corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+				{State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}},
+				{State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}}},
+			}},
+			"ImagePullBackOff; Error (exit code 1)",
+		},
+		{
+			"conditions",
+			corev1.PodStatus{Conditions: []corev1.PodCondition{
+				{Type: corev1.PodScheduled, Status: corev1.ConditionTrue, Message: "ignored"},
+				{Type: corev1.ContainersReady, Status: corev1.ConditionFalse, Message: "failed to create sandbox"},
+			}},
+			"failed to create sandbox",
+		},
+	} {
+		if err := podError(&corev1.Pod{Status: test.status}); err != test.err {
+			t.Errorf("%s: got %q, want %q", test.name, err, test.err)
+		}
+	}
+}
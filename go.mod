@@ -11,6 +11,7 @@ require (
 	github.com/onsi/ginkgo v1.12.1
 	github.com/onsi/gomega v1.10.1
 	github.com/openshift/machine-config-operator v0.0.1-0.20200918082730-c08c048584ef
+	github.com/prometheus/client_golang v1.7.1
 	github.com/vincent-petithory/dataurl v0.0.0-20191104211930-d1553a71de50 // indirect
 	go4.org v0.0.0-20200411211856-f5505b9728dd // indirect
 	k8s.io/api v0.19.0
@@ -18,6 +19,7 @@ require (
 	k8s.io/client-go v0.19.0
 	k8s.io/kubernetes v0.19.0
 	sigs.k8s.io/controller-runtime v0.6.3
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace (
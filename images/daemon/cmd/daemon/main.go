@@ -5,14 +5,19 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/go-logr/logr"
+	configv1 "github.com/openshift/api/config/v1"
 	kataDaemon "github.com/openshift/kata-operator-daemon/pkg/daemon"
-	kataTypes "github.com/openshift/kata-operator/api/v1"
 	mcfgapi "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io"
+	kataTypes "github.com/openshift/sandboxed-containers-operator/api/v1"
+	uberzap "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	nodeapi "k8s.io/kubernetes/pkg/apis/node/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
 
 func main() {
@@ -22,44 +27,72 @@ func main() {
 
 	var kataConfigResourceName string
 	flag.StringVar(&kataConfigResourceName, "resource", "", "Kata Config Custom Resource Name")
+
+	var logLevel, logFormat string
+	flag.StringVar(&logLevel, "log-level", "info", "Log level. Valid options are 'debug', 'info', 'error'")
+	flag.StringVar(&logFormat, "log-format", "console", "Log format. Valid options are 'console', 'json'")
 	flag.Parse()
 
+	logger, err := newLogger(logLevel, logFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v. Check -h for more information.\n", err)
+		os.Exit(1)
+	}
+	ctrl.SetLogger(logger)
+	setupLog := ctrl.Log.WithName("setup")
+
 	if kataOperation == "" {
-		fmt.Println("Operation type must be specified. Check -h for more information.")
+		setupLog.Error(nil, "Operation type must be specified. Check -h for more information.")
 		os.Exit(1)
 	}
 	if kataConfigResourceName == "" {
-		fmt.Println("Kata Custom Resource name must be specified. Check -h for more information.")
+		setupLog.Error(nil, "Kata Custom Resource name must be specified. Check -h for more information.")
 		os.Exit(1)
 	}
 
-	var kataActions kataDaemon.KataActions
+	nodeName, err := kataDaemon.NodeName()
+	if err != nil {
+		setupLog.Error(err, "Unable to get the node name")
+		os.Exit(1)
+	}
 
 	kataClient, err := getKataConfigClient()
 	if err != nil {
-		fmt.Printf("Unable to get dynamic kata config client, %+v", err)
+		setupLog.Error(err, "Unable to get dynamic kata config client")
 		os.Exit(1)
 	}
 
-	kataActions = &kataDaemon.KataOpenShift{
+	kataActions := &kataDaemon.KataOpenShift{
 		KataClient: kataClient,
+		Log: ctrl.Log.WithName("daemon").WithValues("node", nodeName, "operation", kataOperation,
+			"kataconfig", kataConfigResourceName),
 	}
 
+	// The status of the node is the only place the failure shows up in, report it before going down
+	defer func() {
+		if r := recover(); r != nil {
+			kataActions.ReportFailure(kataConfigResourceName, kataOperation, fmt.Errorf("panic: %v", r))
+			panic(r)
+		}
+	}()
+
 	switch kataOperation {
 	case "install":
 		err := kataActions.Install(kataConfigResourceName)
 		if err != nil {
-			fmt.Printf("Error while installation: %+v", err)
+			kataActions.Log.Error(err, "Error while installation")
+			kataActions.ReportFailure(kataConfigResourceName, kataOperation, err)
 		}
 	case "upgrade":
 		kataActions.Upgrade()
 	case "uninstall":
 		err := kataActions.Uninstall(kataConfigResourceName)
 		if err != nil {
-			fmt.Printf("Error while uninstallation: %+v", err)
+			kataActions.Log.Error(err, "Error while uninstallation")
+			kataActions.ReportFailure(kataConfigResourceName, kataOperation, err)
 		}
 	default:
-		fmt.Println("invalid operation. Check -h for more information.")
+		setupLog.Error(nil, "invalid operation. Check -h for more information.")
 	}
 
 	// Wait till controller kills us
@@ -69,12 +102,40 @@ func main() {
 	}
 }
 
+// newLogger builds the zap logger of the daemon, the console format is meant for people reading the pod logs
+func newLogger(level string, format string) (logr.Logger, error) {
+	var zapLevel zapcore.Level
+	switch level {
+	case "debug":
+		zapLevel = zapcore.DebugLevel
+	case "info":
+		zapLevel = zapcore.InfoLevel
+	case "error":
+		zapLevel = zapcore.ErrorLevel
+	default:
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+
+	var encoder zapcore.Encoder
+	switch format {
+	case "console":
+		encoder = zapcore.NewConsoleEncoder(uberzap.NewDevelopmentEncoderConfig())
+	case "json":
+		encoder = zapcore.NewJSONEncoder(uberzap.NewProductionEncoderConfig())
+	default:
+		return nil, fmt.Errorf("invalid log format %q", format)
+	}
+
+	return zap.New(zap.Level(zapLevel), zap.Encoder(encoder), zap.WriteTo(os.Stdout)), nil
+}
+
 func getKataConfigClient() (client.Client, error) {
 	scheme := runtime.NewScheme()
 	_ = clientgoscheme.AddToScheme(scheme)
 	_ = kataTypes.AddToScheme(scheme)
 	_ = nodeapi.AddToScheme(scheme)
 	_ = mcfgapi.Install(scheme)
+	_ = configv1.Install(scheme)
 
 	kubeconfig := ctrl.GetConfigOrDie()
 	kubeclient, err := client.New(kubeconfig, client.Options{Scheme: scheme})
@@ -3,14 +3,19 @@ module github.com/openshift/kata-operator-daemon
 go 1.13
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/Showmax/go-fqdn v1.0.0
 	github.com/containers/image/v5 v5.5.1
 	github.com/coreos/go-semver v0.3.0
 	github.com/dsnet/compress v0.0.1 // indirect
+	github.com/go-logr/logr v0.2.1
 	github.com/opencontainers/image-tools v1.0.0-rc1.0.20190306063041-93db3b16e673
-	github.com/openshift/client-go v0.0.0-20200827190008-3062137373b5
-	github.com/openshift/kata-operator v0.0.0-20201106123035-a3bf549cd866
+	github.com/openshift/api v0.0.0-20200829102639-8a3a835f1acf
 	github.com/openshift/machine-config-operator v0.0.1-0.20200918082730-c08c048584ef
+	github.com/openshift/sandboxed-containers-operator v0.0.0-00010101000000-000000000000
+	go.uber.org/zap v1.10.0
+	golang.org/x/sys v0.0.0-20200622214017-ed371f2e16b4
+	k8s.io/api v0.19.0
 	k8s.io/apimachinery v0.19.0
 	k8s.io/client-go v12.0.0+incompatible
 	k8s.io/kubernetes v0.19.0
@@ -24,6 +29,8 @@ replace (
 	github.com/docker/docker => github.com/moby/moby v0.7.3-0.20190826074503-38ab9da00309 // Required by Helm
 	github.com/go-log/log => github.com/go-log/log v0.1.1-0.20181211034820-a514cf01a3eb
 	github.com/openshift/api => github.com/openshift/api v0.0.0-20200916161728-83f0cb093902
+	// Build against the operator API types of this repository
+	github.com/openshift/sandboxed-containers-operator => ../../
 
 	// So that we can import MCO
 	k8s.io/api => k8s.io/api v0.19.0
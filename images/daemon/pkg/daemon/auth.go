@@ -0,0 +1,105 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/containers/image/v5/types"
+)
+
+// clusterPullSecretPath is where the kubelet keeps the global pull secret of the cluster on the node
+const clusterPullSecretPath = "/var/lib/kubelet/config.json"
+
+// dockerConfig is the format of a kubernetes.io/dockerconfigjson secret and of the auth file of
+// containers/image. The credentials are kept as they are, they are never decoded or logged.
+type dockerConfig struct {
+	Auths map[string]json.RawMessage `json:"auths"`
+}
+
+// payloadAuthFile returns the auth file to pull the payload image with, or "" if no credentials are
+// configured. The pull secret mounted by the operator takes precedence over the cluster pull secret
+// for the registries both have credentials for. The returned cleanup removes the merged file.
+func (k *KataOpenShift) payloadAuthFile() (string, func(), error) {
+	secretPath := os.Getenv("PAYLOAD_AUTH_FILE")
+	useClusterSecret := os.Getenv("PAYLOAD_USE_CLUSTER_PULL_SECRET") == "true"
+	noCleanup := func() {}
+
+	if !useClusterSecret {
+		if secretPath == "" {
+			return "", noCleanup, nil
+		}
+		// Make sure the file is a valid pull secret before the pull fails with an unrelated error
+		if _, err := readDockerConfig(secretPath); err != nil {
+			return "", noCleanup, err
+		}
+		return secretPath, noCleanup, nil
+	}
+
+	merged, err := readDockerConfig(k.Host.Path(clusterPullSecretPath))
+	if err != nil {
+		return "", noCleanup, err
+	}
+	if secretPath != "" {
+		secret, err := readDockerConfig(secretPath)
+		if err != nil {
+			return "", noCleanup, err
+		}
+		for registry, auth := range secret.Auths {
+			merged.Auths[registry] = auth
+		}
+	}
+
+	content, err := json.Marshal(merged)
+	if err != nil {
+		return "", noCleanup, err
+	}
+	// The merged file stays in the container, it is created readable by the daemon only
+	f, err := ioutil.TempFile("", "payload-auth-*.json")
+	if err != nil {
+		return "", noCleanup, err
+	}
+	cleanup := func() { os.Remove(f.Name()) }
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		cleanup()
+		return "", noCleanup, err
+	}
+	if err := f.Close(); err != nil {
+		cleanup()
+		return "", noCleanup, err
+	}
+	return f.Name(), cleanup, nil
+}
+
+// readDockerConfig reads a pull secret, the errors name the file but never its content
+func readDockerConfig(path string) (*dockerConfig, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("pull secret %s not found, check that the secret exists and has the key .dockerconfigjson", path)
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read the pull secret %s: %v", path, err)
+	}
+
+	config := &dockerConfig{}
+	if err := json.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("pull secret %s is not a valid docker config.json", path)
+	}
+	if config.Auths == nil {
+		config.Auths = map[string]json.RawMessage{}
+	}
+	return config, nil
+}
+
+// legacyPayloadCredentials returns the username and password of the deprecated payload-secret, or nil
+// if the secret doesn't exist. The operator only passes them if the KataConfig has no pull secret.
+func legacyPayloadCredentials() *types.DockerAuthConfig {
+	username := strings.Replace(os.Getenv("PAYLOAD_REGISTRY_USERNAME"), "\n", "", -1)
+	password := strings.Replace(os.Getenv("PAYLOAD_REGISTRY_PASSWORD"), "\n", "", -1)
+	if username == "" || password == "" {
+		return nil
+	}
+	return &types.DockerAuthConfig{Username: username, Password: password}
+}
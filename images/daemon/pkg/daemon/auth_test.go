@@ -0,0 +1,95 @@
+package daemon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPayloadAuthFile(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	k := &KataOpenShift{Host: host}
+	defer os.Unsetenv("PAYLOAD_AUTH_FILE")
+	defer os.Unsetenv("PAYLOAD_USE_CLUSTER_PULL_SECRET")
+
+	authFile, cleanup, err := k.payloadAuthFile()
+	if err != nil || authFile != "" {
+		t.Fatalf("unexpected auth file %q, %v without a pull secret", authFile, err)
+	}
+	cleanup()
+
+	host.writeFile(t, "secret/auth.json", `{"auths":{"quay.io":{"auth":"c2VjcmV0"}}}`)
+	os.Setenv("PAYLOAD_AUTH_FILE", host.Path("secret/auth.json"))
+	authFile, cleanup, err = k.payloadAuthFile()
+	if err != nil || authFile != host.Path("secret/auth.json") {
+		t.Fatalf("unexpected auth file %q, %v with a pull secret", authFile, err)
+	}
+	cleanup()
+
+	// The pull secret wins over the cluster pull secret
+	host.writeFile(t, clusterPullSecretPath,
+		`{"auths":{"quay.io":{"auth":"Y2x1c3Rlcg=="},"registry.redhat.io":{"auth":"Y2x1c3Rlcg=="}}}`)
+	os.Setenv("PAYLOAD_USE_CLUSTER_PULL_SECRET", "true")
+	authFile, cleanup, err = k.payloadAuthFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(authFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := &dockerConfig{}
+	if err := json.Unmarshal(content, merged); err != nil {
+		t.Fatal(err)
+	}
+	if string(merged.Auths["quay.io"]) != `{"auth":"c2VjcmV0"}` || len(merged.Auths) != 2 {
+		t.Errorf("unexpected merged auth file %s", content)
+	}
+	if fi, err := os.Stat(authFile); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("merged auth file readable by others: %v", err)
+	}
+	cleanup()
+	if _, err := os.Stat(authFile); !os.IsNotExist(err) {
+		t.Error("merged auth file not removed")
+	}
+}
+
+func TestPayloadAuthFileInvalid(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	k := &KataOpenShift{Host: host}
+	defer os.Unsetenv("PAYLOAD_AUTH_FILE")
+
+	os.Setenv("PAYLOAD_AUTH_FILE", host.Path("secret/auth.json"))
+	if _, _, err := k.payloadAuthFile(); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("unexpected error %v for a missing pull secret", err)
+	}
+
+	// The content of an invalid secret doesn't end up in the error
+	host.writeFile(t, "secret/auth.json", `{"auths":"c2VjcmV0"`)
+	if _, _, err := k.payloadAuthFile(); err == nil || strings.Contains(err.Error(), "c2VjcmV0") {
+		t.Errorf("unexpected error %v for an invalid pull secret", err)
+	}
+}
+
+func TestLegacyPayloadCredentials(t *testing.T) {
+	defer os.Unsetenv("PAYLOAD_REGISTRY_USERNAME")
+	defer os.Unsetenv("PAYLOAD_REGISTRY_PASSWORD")
+
+	if auth := legacyPayloadCredentials(); auth != nil {
+		t.Errorf("credentials %+v without the legacy secret", auth)
+	}
+
+	// Secrets created with a trailing newline worked before, so they still do
+	os.Setenv("PAYLOAD_REGISTRY_USERNAME", "kata\n")
+	if auth := legacyPayloadCredentials(); auth != nil {
+		t.Error("credentials without a password")
+	}
+	os.Setenv("PAYLOAD_REGISTRY_PASSWORD", "secret\n")
+	if auth := legacyPayloadCredentials(); auth == nil || auth.Username != "kata" || auth.Password != "secret" {
+		t.Errorf("unexpected credentials %+v from the legacy secret", auth)
+	}
+}
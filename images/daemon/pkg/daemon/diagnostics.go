@@ -0,0 +1,253 @@
+package daemon
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	kataTypes "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DiagnosticsCollector gathers the state of the node after a failed operation into a gzipped tarball
+type DiagnosticsCollector func(k *KataOpenShift, opErr error) ([]byte, error)
+
+const (
+	// diagnosticsDir is the directory of the node the tarballs are kept in
+	diagnosticsDir = "/var/log/kata-operator"
+	// diagnosticsKept is the number of tarballs kept on the node, the oldest ones are removed
+	diagnosticsKept = 5
+
+	diagnosticsConfigMapPrefix = "kata-diagnostics-"
+	diagnosticsConfigMapKey    = "diagnostics.tar.gz"
+	// diagnosticsConfigMapMaxSize leaves room for the metadata below the 1MiB limit of a ConfigMap
+	diagnosticsConfigMapMaxSize = 900 * 1024
+
+	// journalLines limits the journal of each unit to the last lines of the current boot
+	journalLines = "5000"
+)
+
+// diagnosticsCommands are run on the node, the output of each goes to a file of the tarball
+var diagnosticsCommands = []struct {
+	file    string
+	command []string
+}{
+	{"rpm-ostree-status.txt", []string{"rpm-ostree", "status", "--verbose"}},
+	{"journal-rpm-ostreed.txt", []string{"journalctl", "--no-pager", "--boot", "--lines", journalLines, "--unit", "rpm-ostreed"}},
+	{"journal-crio.txt", []string{"journalctl", "--no-pager", "--boot", "--lines", journalLines, "--unit", "crio"}},
+	{"journal-kata.txt", []string{"journalctl", "--no-pager", "--boot", "--lines", journalLines, "--unit", "kata*"}},
+}
+
+// kataCheckCommands check if the node is able to run kata VMs, kata 2 ships kata-ctl
+// next to or instead of kata-runtime. The first one installed on the node is run.
+var kataCheckCommands = [][]string{
+	{"/usr/bin/kata-runtime", "check"},
+	{"/usr/bin/kata-ctl", "check"},
+}
+
+// collectDiagnostics collects the diagnostics of the failed operation, keeps the tarball on the node and
+// in a ConfigMap and returns where to find it. Collecting is best effort, failures are only logged.
+func (k *KataOpenShift) collectDiagnostics(kataConfigResourceName string, nodeName string, opErr error) *kataTypes.KataNodeDiagnostics {
+	if k.KataDiagnosticsCollector == nil {
+		k.KataDiagnosticsCollector = collectHostDiagnostics
+	}
+
+	k.Log.Info("Collecting the diagnostics of the failed operation")
+	tarball, err := k.KataDiagnosticsCollector(k, opErr)
+	if err != nil {
+		k.Log.Error(err, "Unable to collect the diagnostics")
+		return nil
+	}
+
+	now := metav1.Now()
+	diagnostics := &kataTypes.KataNodeDiagnostics{CollectionTime: now}
+
+	name := fmt.Sprintf("%s-%s.tar.gz", nodeName, now.UTC().Format("20060102T150405Z"))
+	if err := saveDiagnostics(k.Host, name, tarball); err != nil {
+		k.Log.Error(err, "Unable to save the diagnostics on the node")
+	} else {
+		diagnostics.HostPath = filepath.Join(diagnosticsDir, name)
+		k.Log.Info("Saved the diagnostics on the node", "path", diagnostics.HostPath)
+	}
+
+	if len(tarball) > diagnosticsConfigMapMaxSize {
+		k.Log.Info("The diagnostics are too large for a ConfigMap", "size", len(tarball))
+	} else if cmName, err := k.storeDiagnostics(kataConfigResourceName, nodeName, tarball); err != nil {
+		k.Log.Error(err, "Unable to store the diagnostics in a ConfigMap")
+	} else {
+		diagnostics.ConfigMap = cmName
+	}
+
+	if diagnostics.HostPath == "" && diagnostics.ConfigMap == "" {
+		return nil
+	}
+	return diagnostics
+}
+
+// collectHostDiagnostics runs the diagnostics commands on the node and adds the
+// CRI-O drop-in files and the KVM messages of the kernel log
+func collectHostDiagnostics(k *KataOpenShift, opErr error) ([]byte, error) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	add := func(name string, data []byte) error {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: time.Now(),
+		}); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	if err := add("error.txt", []byte(fmt.Sprintf("%+v\n", opErr))); err != nil {
+		return nil, err
+	}
+
+	for _, c := range diagnosticsCommands {
+		if err := add(c.file, commandOutput(k.Host, c.command)); err != nil {
+			return nil, err
+		}
+	}
+
+	kataCheck := []byte("no kata runtime found on the node\n")
+	for _, c := range kataCheckCommands {
+		if _, err := os.Stat(k.Host.Path(c[0])); err == nil {
+			kataCheck = commandOutput(k.Host, c)
+			break
+		}
+	}
+	if err := add("kata-check.txt", kataCheck); err != nil {
+		return nil, err
+	}
+
+	dropins, err := ioutil.ReadDir(k.Host.Path("/etc/crio/crio.conf.d"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, fi := range dropins {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		data, err := ioutil.ReadFile(k.Host.Path(filepath.Join("/etc/crio/crio.conf.d", fi.Name())))
+		if err != nil {
+			return nil, err
+		}
+		if err := add(filepath.Join("crio.conf.d", fi.Name()), data); err != nil {
+			return nil, err
+		}
+	}
+
+	var kvmMessages []string
+	for _, line := range strings.Split(string(commandOutput(k.Host, []string{"dmesg", "--ctime"})), "\n") {
+		if strings.Contains(strings.ToLower(line), "kvm") {
+			kvmMessages = append(kvmMessages, line)
+		}
+	}
+	if err := add("dmesg-kvm.txt", []byte(strings.Join(kvmMessages, "\n"))); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// commandOutput runs a command on the node and returns its combined output, a failure is
+// recorded in the output so that a missing tool doesn't stop the collection
+func commandOutput(host HostExecutor, command []string) []byte {
+	out, err := host.Run(command[0], command[1:]...)
+	if err != nil {
+		out = append(out, []byte(fmt.Sprintf("\n%s: %v\n", strings.Join(command, " "), err))...)
+	}
+	return out
+}
+
+// saveDiagnostics writes the tarball to the diagnostics directory of the node and removes the oldest tarballs
+func saveDiagnostics(host HostExecutor, name string, tarball []byte) error {
+	dir := host.Path(diagnosticsDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name), tarball, 0600); err != nil {
+		return err
+	}
+
+	tarballs, err := filepath.Glob(filepath.Join(dir, "*.tar.gz"))
+	if err != nil {
+		return err
+	}
+	// The names end with the collection time, sorting them by name sorts them by age
+	sort.Strings(tarballs)
+	for len(tarballs) > diagnosticsKept {
+		if err := os.Remove(tarballs[0]); err != nil {
+			return err
+		}
+		tarballs = tarballs[1:]
+	}
+	return nil
+}
+
+// storeDiagnostics keeps the tarball in a ConfigMap of the daemon namespace, one per node, so
+// that it can be retrieved without access to the node. The ConfigMap is owned by the KataConfig.
+func (k *KataOpenShift) storeDiagnostics(kataConfigResourceName string, nodeName string, tarball []byte) (string, error) {
+	namespace := os.Getenv("POD_NAMESPACE")
+	if namespace == "" {
+		return "", fmt.Errorf("POD_NAMESPACE is not set")
+	}
+
+	var kataConfig kataTypes.KataConfig
+	if err := getKataConfig(k.KataClient, kataConfigResourceName, &kataConfig); err != nil {
+		return "", err
+	}
+
+	cm := &corev1.ConfigMap{}
+	err := k.KataClient.Get(context.Background(), client.ObjectKey{
+		Namespace: namespace,
+		Name:      diagnosticsConfigMapPrefix + nodeName,
+	}, cm)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return "", err
+	}
+	exists := err == nil
+
+	cm.Name = diagnosticsConfigMapPrefix + nodeName
+	cm.Namespace = namespace
+	cm.Labels = map[string]string{kataTypes.OwnerLabel: kataConfigResourceName}
+	cm.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: kataTypes.GroupVersion.String(),
+		Kind:       "KataConfig",
+		Name:       kataConfig.Name,
+		UID:        kataConfig.UID,
+	}}
+	cm.BinaryData = map[string][]byte{diagnosticsConfigMapKey: tarball}
+
+	if exists {
+		err = k.KataClient.Update(context.Background(), cm)
+	} else {
+		err = k.KataClient.Create(context.Background(), cm)
+	}
+	if err != nil {
+		return "", err
+	}
+	return cm.Name, nil
+}
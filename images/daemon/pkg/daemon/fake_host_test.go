@@ -0,0 +1,83 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeHost records the commands run on the node instead of running them. The root filesystem
+// of the node is a temporary directory.
+type fakeHost struct {
+	root     string
+	commands []string
+
+	// failures makes the commands starting with a key fail with the error
+	failures map[string]error
+	// outputs is the output of the commands starting with a key
+	outputs map[string]string
+}
+
+var _ HostExecutor = (*fakeHost)(nil)
+
+func newFakeHost(t *testing.T) *fakeHost {
+	root, err := ioutil.TempDir("", "kata-host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeHost{root: root, failures: map[string]error{}, outputs: map[string]string{
+		// the node did not boot into a kata deployment yet
+		"rpm-ostree status --json": `{"deployments": [{"booted": true}]}`,
+	}}
+}
+
+func (h *fakeHost) Run(name string, arg ...string) ([]byte, error) {
+	command := strings.Join(append([]string{name}, arg...), " ")
+	h.commands = append(h.commands, command)
+	for prefix, err := range h.failures {
+		if strings.HasPrefix(command, prefix) {
+			return []byte(command + " failed"), err
+		}
+	}
+	for prefix, out := range h.outputs {
+		if strings.HasPrefix(command, prefix) {
+			return []byte(out), nil
+		}
+	}
+	return nil, nil
+}
+
+func (h *fakeHost) Path(path string) string {
+	return filepath.Join(h.root, path)
+}
+
+func (h *fakeHost) cleanup() {
+	os.RemoveAll(h.root)
+}
+
+// writeFile creates a file on the node along with its directory
+func (h *fakeHost) writeFile(t *testing.T, path string, content string) {
+	if err := os.MkdirAll(filepath.Dir(h.Path(path)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(h.Path(path), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (h *fakeHost) exists(path string) bool {
+	_, err := os.Stat(h.Path(path))
+	return err == nil
+}
+
+// ran returns true if a command starting with the prefix was run
+func (h *fakeHost) ran(prefix string) bool {
+	for _, c := range h.commands {
+		if strings.HasPrefix(c, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,298 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// stagingSuffix names the directory a tree is copied to before it is renamed into place.
+	// It is kept if the daemon is killed during the copy, the next copy resumes from it.
+	stagingSuffix = ".staging"
+	// partialPrefix names a file while it is written, it is renamed once complete
+	partialPrefix = ".partial."
+
+	// selinuxXattr holds the SELinux label of a file
+	selinuxXattr = "security.selinux"
+)
+
+// FileOperationError is returned by the file operations on the node, it holds the
+// operation and the path that failed along with the underlying error
+type FileOperationError struct {
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *FileOperationError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Path, e.Err)
+}
+
+func (e *FileOperationError) Unwrap() error {
+	return e.Err
+}
+
+func fileOperationError(op string, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &FileOperationError{Op: op, Path: path, Err: err}
+}
+
+// mkdirAll creates a directory along with its parents
+func mkdirAll(path string, perm os.FileMode) error {
+	return fileOperationError("mkdir", path, os.MkdirAll(path, perm))
+}
+
+// removeAll removes a file or a directory with its content, it is no error if the path doesn't exist
+func removeAll(path string) error {
+	return fileOperationError("remove", path, os.RemoveAll(path))
+}
+
+// installFile copies a regular file with its metadata into another tree, the destination is replaced
+// atomically. The SELinux label is not copied, the file gets the default label of its new directory.
+func installFile(src string, dst string) error {
+	fi, err := os.Lstat(src)
+	if err != nil {
+		return fileOperationError("copy", src, err)
+	}
+	if !fi.Mode().IsRegular() {
+		return fileOperationError("copy", src, fmt.Errorf("not a regular file"))
+	}
+	return copyRegularFile(src, dst, fi, selinuxXattr)
+}
+
+// copyTree copies a directory tree preserving the modes, the ownership, the modification times,
+// the symlinks and the extended attributes, which hold the SELinux labels. The tree is copied to
+// a staging directory next to the destination and renamed into place once complete, so the
+// destination is either missing, the old tree or the complete new tree. The files already in
+// the staging directory are not copied again.
+func copyTree(src string, dst string) error {
+	staging := dst + stagingSuffix
+
+	var dirs []string
+	err := filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return fileOperationError("copy", path, err)
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return fileOperationError("copy", path, err)
+		}
+		target := filepath.Join(staging, rel)
+
+		switch mode := fi.Mode(); {
+		case mode.IsDir():
+			if err := os.Mkdir(target, 0700); err != nil && !os.IsExist(err) {
+				return fileOperationError("mkdir", target, err)
+			}
+			// The metadata of a directory is copied once its content is complete
+			dirs = append(dirs, rel)
+			return nil
+		case mode&os.ModeSymlink != 0:
+			return copySymlink(path, target, fi)
+		case mode.IsRegular():
+			if copied(target, fi) {
+				return nil
+			}
+			return copyRegularFile(path, target, fi)
+		default:
+			return fileOperationError("copy", path, fmt.Errorf("unsupported file type %v", mode.Type()))
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := pruneStaging(src, staging); err != nil {
+		return err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := copyMetadata(filepath.Join(src, dirs[i]), filepath.Join(staging, dirs[i])); err != nil {
+			return err
+		}
+	}
+
+	// Move the old tree aside rather than removing it, so that the destination never holds a partial tree
+	old := dst + ".old"
+	if err := removeAll(old); err != nil {
+		return err
+	}
+	if err := os.Rename(dst, old); err != nil && !os.IsNotExist(err) {
+		return fileOperationError("rename", dst, err)
+	}
+	if err := os.Rename(staging, dst); err != nil {
+		return fileOperationError("rename", staging, err)
+	}
+	return removeAll(old)
+}
+
+// pruneStaging removes what an interrupted copy left in the staging directory that isn't in the
+// source, the partial files and the files of a source that changed in the meantime
+func pruneStaging(src string, staging string) error {
+	return filepath.Walk(staging, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return fileOperationError("prune", path, err)
+		}
+		rel, err := filepath.Rel(staging, path)
+		if err != nil {
+			return fileOperationError("prune", path, err)
+		}
+		if _, err := os.Lstat(filepath.Join(src, rel)); !os.IsNotExist(err) {
+			return nil
+		}
+		if err := removeAll(path); err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+// copied returns true if the file was copied by an earlier, interrupted copy
+func copied(target string, srcInfo os.FileInfo) bool {
+	fi, err := os.Lstat(target)
+	if err != nil || !fi.Mode().IsRegular() {
+		return false
+	}
+	return fi.Size() == srcInfo.Size() && fi.ModTime().Equal(srcInfo.ModTime())
+}
+
+// copyRegularFile writes the file to a partial file next to the destination and renames it once
+// its content and metadata are complete, the excluded extended attributes are not copied
+func copyRegularFile(src string, dst string, fi os.FileInfo, excludedXattrs ...string) error {
+	partial := filepath.Join(filepath.Dir(dst), partialPrefix+filepath.Base(dst))
+
+	in, err := os.Open(src)
+	if err != nil {
+		return fileOperationError("copy", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(partial, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fileOperationError("copy", partial, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fileOperationError("copy", src, err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return fileOperationError("sync", partial, err)
+	}
+	if err := out.Close(); err != nil {
+		return fileOperationError("close", partial, err)
+	}
+
+	if err := copyMetadata(src, partial, excludedXattrs...); err != nil {
+		return err
+	}
+	return fileOperationError("rename", partial, os.Rename(partial, dst))
+}
+
+// copySymlink recreates the symlink unless the staging directory has it already
+func copySymlink(src string, dst string, fi os.FileInfo) error {
+	link, err := os.Readlink(src)
+	if err != nil {
+		return fileOperationError("readlink", src, err)
+	}
+	if existing, err := os.Readlink(dst); err == nil && existing == link {
+		return nil
+	}
+	if err := removeAll(dst); err != nil {
+		return err
+	}
+	if err := os.Symlink(link, dst); err != nil {
+		return fileOperationError("symlink", dst, err)
+	}
+
+	st := fi.Sys().(*syscall.Stat_t)
+	if err := os.Lchown(dst, int(st.Uid), int(st.Gid)); err != nil {
+		return fileOperationError("chown", dst, err)
+	}
+	return copyXattrs(src, dst)
+}
+
+// copyMetadata copies the ownership, the mode, the extended attributes but the excluded ones and the modification time
+func copyMetadata(src string, dst string, excludedXattrs ...string) error {
+	fi, err := os.Lstat(src)
+	if err != nil {
+		return fileOperationError("stat", src, err)
+	}
+
+	st := fi.Sys().(*syscall.Stat_t)
+	// The ownership goes first, changing it clears the setuid and setgid bits
+	if err := os.Lchown(dst, int(st.Uid), int(st.Gid)); err != nil {
+		return fileOperationError("chown", dst, err)
+	}
+	if err := os.Chmod(dst, fi.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+		return fileOperationError("chmod", dst, err)
+	}
+	if err := copyXattrs(src, dst, excludedXattrs...); err != nil {
+		return err
+	}
+	return fileOperationError("chtimes", dst, os.Chtimes(dst, fi.ModTime(), fi.ModTime()))
+}
+
+// copyXattrs copies the extended attributes, among them security.selinux unless it is excluded.
+// Filesystems without extended attributes are not an error.
+func copyXattrs(src string, dst string, excluded ...string) error {
+	size, err := unix.Llistxattr(src, nil)
+	if xattrUnsupported(err) || size == 0 {
+		return nil
+	} else if err != nil {
+		return fileOperationError("listxattr", src, err)
+	}
+	buf := make([]byte, size)
+	size, err = unix.Llistxattr(src, buf)
+	if err != nil {
+		return fileOperationError("listxattr", src, err)
+	}
+
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		attr := string(name)
+		if xattrExcluded(attr, excluded) {
+			continue
+		}
+
+		size, err := unix.Lgetxattr(src, attr, nil)
+		if err != nil {
+			return fileOperationError("getxattr "+attr, src, err)
+		}
+		value := make([]byte, size)
+		size, err = unix.Lgetxattr(src, attr, value)
+		if err != nil {
+			return fileOperationError("getxattr "+attr, src, err)
+		}
+		if err := unix.Lsetxattr(dst, attr, value[:size], 0); err != nil && !xattrUnsupported(err) {
+			return fileOperationError("setxattr "+attr, dst, err)
+		}
+	}
+	return nil
+}
+
+func xattrExcluded(attr string, excluded []string) bool {
+	for _, e := range excluded {
+		if attr == e {
+			return true
+		}
+	}
+	return false
+}
+
+func xattrUnsupported(err error) bool {
+	return err == unix.ENOTSUP || err == unix.EOPNOTSUPP
+}
@@ -0,0 +1,216 @@
+package daemon
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// newTestTree creates a tree with a nested directory, an executable and a symlink
+func newTestTree(t *testing.T, root string) {
+	for path, content := range map[string]string{
+		"packages/kata-containers.rpm": "kata-containers",
+		"packages/repodata/repomd.xml": "<repomd/>",
+		"packages/bin/kata-runtime":    "#!/bin/sh",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(path)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(root, "packages/bin/kata-runtime"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(root, "packages/repodata"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("bin/kata-runtime", filepath.Join(root, "packages/kata-runtime")); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "packages/kata-containers.rpm"), past, past); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCopyTree(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	newTestTree(t, host.root)
+	src := host.Path("packages")
+	dst := host.Path("opt/kata-install/packages")
+
+	// The SELinux labels are extended attributes, check with a user attribute if the filesystem has them
+	xattrs := unix.Lsetxattr(filepath.Join(src, "kata-containers.rpm"), "user.kata", []byte("label"), 0) == nil
+
+	// An old tree is replaced as a whole
+	host.writeFile(t, "opt/kata-install/packages/stale.rpm", "stale")
+
+	if err := copyTree(src, dst); err != nil {
+		t.Fatalf("copyTree failed: %v", err)
+	}
+
+	err := filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		copied, err := os.Lstat(filepath.Join(dst, rel))
+		if err != nil {
+			t.Errorf("%s not copied: %v", rel, err)
+			return nil
+		}
+		if copied.Mode() != fi.Mode() {
+			t.Errorf("%s has mode %v, want %v", rel, copied.Mode(), fi.Mode())
+		}
+		if fi.Mode().IsRegular() && !copied.ModTime().Equal(fi.ModTime()) {
+			t.Errorf("%s has modification time %v, want %v", rel, copied.ModTime(), fi.ModTime())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if link, err := os.Readlink(filepath.Join(dst, "kata-runtime")); err != nil || link != "bin/kata-runtime" {
+		t.Errorf("symlink not copied: %q, %v", link, err)
+	}
+	if host.exists("opt/kata-install/packages/stale.rpm") {
+		t.Error("the old tree is left in the destination")
+	}
+	for _, leftover := range []string{"opt/kata-install/packages" + stagingSuffix, "opt/kata-install/packages.old"} {
+		if host.exists(leftover) {
+			t.Errorf("%s is left behind", leftover)
+		}
+	}
+	if xattrs {
+		value := make([]byte, 16)
+		size, err := unix.Lgetxattr(filepath.Join(dst, "kata-containers.rpm"), "user.kata", value)
+		if err != nil || string(value[:size]) != "label" {
+			t.Errorf("extended attribute not copied: %q, %v", value[:size], err)
+		}
+	}
+}
+
+func TestCopyTreeResume(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	newTestTree(t, host.root)
+	src := host.Path("packages")
+	dst := host.Path("opt/kata-install/packages")
+	staging := dst + stagingSuffix
+
+	// The daemon was killed after the rpm was copied and while the repository metadata was written
+	if err := os.MkdirAll(filepath.Join(staging, "repodata"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := installFile(filepath.Join(src, "kata-containers.rpm"), filepath.Join(staging, "kata-containers.rpm")); err != nil {
+		t.Fatal(err)
+	}
+	host.writeFile(t, "opt/kata-install/packages"+stagingSuffix+"/repodata/"+partialPrefix+"repomd.xml", "<rep")
+	rpmInfo, err := os.Stat(filepath.Join(staging, "kata-containers.rpm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := copyTree(src, dst); err != nil {
+		t.Fatalf("copyTree failed: %v", err)
+	}
+
+	// The rpm copied before is kept rather than copied again
+	if fi, err := os.Stat(filepath.Join(dst, "kata-containers.rpm")); err != nil || !os.SameFile(fi, rpmInfo) {
+		t.Errorf("the rpm was copied again")
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(dst, "repodata/repomd.xml")); err != nil || string(content) != "<repomd/>" {
+		t.Errorf("unexpected repository metadata %q, %v", content, err)
+	}
+	if host.exists("opt/kata-install/packages/repodata/" + partialPrefix + "repomd.xml") {
+		t.Error("the partial file is left in the destination")
+	}
+}
+
+func TestCopyTreeErrors(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+
+	err := copyTree(host.Path("missing"), host.Path("opt/kata-install/packages"))
+	var fileErr *FileOperationError
+	if !errors.As(err, &fileErr) || !os.IsNotExist(errors.Unwrap(err)) || fileErr.Path != host.Path("missing") {
+		t.Errorf("unexpected error %v", err)
+	}
+	if host.exists("opt/kata-install/packages") {
+		t.Error("destination created for a failed copy")
+	}
+
+	host.writeFile(t, "file", "")
+	err = installFile(host.Path("file"), host.Path("missing/file"))
+	if !errors.As(err, &fileErr) || fileErr.Op != "copy" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestInstallFileLabel(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	host.writeFile(t, "usr/local/kata/latest/packages.repo", "[packages]\n")
+	host.writeFile(t, "etc/yum.repos.d/other.repo", "")
+	src := host.Path("usr/local/kata/latest/packages.repo")
+	dst := host.Path("etc/yum.repos.d/packages.repo")
+
+	xattrs := unix.Lsetxattr(src, "user.kata", []byte("kata"), 0) == nil
+	labeled := unix.Lsetxattr(src, selinuxXattr, []byte("system_u:object_r:usr_t:s0"), 0) == nil
+
+	if err := installFile(src, dst); err != nil {
+		t.Fatalf("installFile failed: %v", err)
+	}
+
+	if content, err := ioutil.ReadFile(dst); err != nil || string(content) != "[packages]\n" {
+		t.Errorf("unexpected content %q, %v", content, err)
+	}
+	value := make([]byte, 256)
+	if xattrs {
+		size, err := unix.Lgetxattr(dst, "user.kata", value)
+		if err != nil || string(value[:size]) != "kata" {
+			t.Errorf("extended attribute not copied: %q, %v", value[:size], err)
+		}
+	}
+	// The file gets the same label as any other file created in the directory
+	if labeled && fileLabel(dst) != fileLabel(host.Path("etc/yum.repos.d/other.repo")) {
+		t.Errorf("got label %q, want the default label %q of the directory",
+			fileLabel(dst), fileLabel(host.Path("etc/yum.repos.d/other.repo")))
+	}
+}
+
+// fileLabel returns the SELinux label of the file, "" if it has none
+func fileLabel(path string) string {
+	value := make([]byte, 256)
+	size, err := unix.Lgetxattr(path, selinuxXattr, value)
+	if err != nil {
+		return ""
+	}
+	return string(value[:size])
+}
+
+func TestCopyXattrsExcluded(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	host.writeFile(t, "src", "")
+	host.writeFile(t, "dst", "")
+
+	if err := unix.Lsetxattr(host.Path("src"), "user.kata", []byte("kata"), 0); err != nil {
+		t.Skipf("filesystem without extended attributes: %v", err)
+	}
+	if err := copyXattrs(host.Path("src"), host.Path("dst"), "user.kata"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unix.Lgetxattr(host.Path("dst"), "user.kata", nil); err == nil {
+		t.Error("excluded extended attribute copied")
+	}
+}
@@ -0,0 +1,33 @@
+package daemon
+
+import (
+	"os/exec"
+	"path/filepath"
+)
+
+// HostExecutor gives the daemon access to the node it configures. The daemon stays in the root of
+// its container so that it keeps its own binaries and the service account credentials to reach the
+// API server, the node is only changed through the host root mount and the commands run on the node.
+type HostExecutor interface {
+	// Run runs a command on the node and returns its combined output
+	Run(name string, arg ...string) ([]byte, error)
+
+	// Path returns where a path of the node is found in the daemon container
+	Path(path string) string
+}
+
+// nodeExecutor runs the commands in the mount namespace of the node, the daemon shares
+// the PID namespace of the node so PID 1 is the init process of the node
+type nodeExecutor struct {
+	root string
+}
+
+var _ HostExecutor = (*nodeExecutor)(nil)
+
+func (e *nodeExecutor) Run(name string, arg ...string) ([]byte, error) {
+	return exec.Command("nsenter", append([]string{"--target", "1", "--mount", "--", name}, arg...)...).CombinedOutput()
+}
+
+func (e *nodeExecutor) Path(path string) string {
+	return filepath.Join(e.root, path)
+}
@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"time"
 
-	kataTypes "github.com/openshift/kata-operator/api/v1"
+	kataTypes "github.com/openshift/sandboxed-containers-operator/api/v1"
 
 	"github.com/Showmax/go-fqdn"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -16,34 +19,107 @@ type KataActions interface {
 	Install(kataConfigResourceName string) error
 	Upgrade() error
 	Uninstall(kataConfigResourceName string) error
+	ReportFailure(kataConfigResourceName string, operation string, err error)
 }
 
 type updateStatus = func(a *kataTypes.KataConfigStatus)
 
-func updateKataConfigStatus(kataClient client.Client, kataConfigResourceName string, us updateStatus) (err error) {
-	var kataConfig kataTypes.KataConfig
-	attempts := 5
-	for i := 0; i < attempts; i++ {
-		err = kataClient.Get(context.Background(), client.ObjectKey{
+// apiServerBackoff keeps retrying long enough for the API server to come back after a reboot,
+// on a single node cluster it runs on the very node the daemon configures
+var apiServerBackoff = wait.Backoff{
+	Duration: 5 * time.Second,
+	Factor:   1.5,
+	Steps:    12,
+	Cap:      time.Minute,
+}
+
+// retryAPIServer runs the API call until it succeeds, retrying while the API server can't be reached.
+// A missing object is not going to show up by retrying, the NotFound error is returned right away.
+func retryAPIServer(call func() error) (err error) {
+	backoff := apiServerBackoff
+	for backoff.Steps > 0 {
+		err = call()
+		if err == nil || errors.IsNotFound(err) {
+			break
+		}
+		time.Sleep(backoff.Step())
+	}
+	return err
+}
+
+// getKataConfig gets the KataConfig, retrying while the API server can't be reached
+func getKataConfig(kataClient client.Client, kataConfigResourceName string, kataConfig *kataTypes.KataConfig) error {
+	return retryAPIServer(func() error {
+		return kataClient.Get(context.Background(), client.ObjectKey{
 			Name: kataConfigResourceName,
-		}, &kataConfig)
+		}, kataConfig)
+	})
+}
 
-		if err != nil {
-			continue // Error, let's attempt to get the object again. TODO - maybe check error type and decide?
+// updateKataConfigStatus applies the update to the status of the latest KataConfig, it is applied
+// again to a fresh copy if the update conflicts or the API server can't be reached
+func updateKataConfigStatus(kataClient client.Client, kataConfigResourceName string, us updateStatus) error {
+	return retryAPIServer(func() error {
+		var kataConfig kataTypes.KataConfig
+		if err := kataClient.Get(context.Background(), client.ObjectKey{
+			Name: kataConfigResourceName,
+		}, &kataConfig); err != nil {
+			return err
 		}
 
 		us(&kataConfig.Status)
 
-		err = kataClient.Status().Update(context.Background(), &kataConfig)
+		return kataClient.Status().Update(context.Background(), &kataConfig)
+	})
+}
 
-		if err == nil {
-			break
+// setNodeStatus updates the status entry of the node, it is added if the node has none yet
+func setNodeStatus(ks *kataTypes.KataConfigStatus, nodeName string, update func(ns *kataTypes.KataNodeStatus)) {
+	for i := range ks.NodeStatus {
+		if ks.NodeStatus[i].Name == nodeName {
+			update(&ks.NodeStatus[i])
+			return
 		}
-
-		time.Sleep(5 * time.Second)
 	}
 
-	return err
+	ks.NodeStatus = append(ks.NodeStatus, kataTypes.KataNodeStatus{Name: nodeName})
+	update(&ks.NodeStatus[len(ks.NodeStatus)-1])
+}
+
+// startNodeOperation marks the start of an installation or uninstallation on the node
+func startNodeOperation(ks *kataTypes.KataConfigStatus, nodeName string, phase kataTypes.KataNodePhase, payloadVersion string) {
+	now := metav1.Now()
+	setNodeStatus(ks, nodeName, func(ns *kataTypes.KataNodeStatus) {
+		ns.Phase = phase
+		ns.StartTime = &now
+		ns.CompletionTime = nil
+		ns.Error = ""
+		ns.Diagnostics = nil
+		if payloadVersion != "" {
+			ns.PayloadVersion = payloadVersion
+		}
+	})
+}
+
+// completeNodeOperation marks the end of an installation or uninstallation on the node
+func completeNodeOperation(ks *kataTypes.KataConfigStatus, nodeName string, phase kataTypes.KataNodePhase, errMsg string) {
+	now := metav1.Now()
+	setNodeStatus(ks, nodeName, func(ns *kataTypes.KataNodeStatus) {
+		ns.Phase = phase
+		ns.CompletionTime = &now
+		ns.Error = errMsg
+	})
+}
+
+// setNodeDiagnostics records where the diagnostics of the failed operation are kept, the
+// diagnostics of an earlier failure are kept if none could be collected this time
+func setNodeDiagnostics(ks *kataTypes.KataConfigStatus, nodeName string, diagnostics *kataTypes.KataNodeDiagnostics) {
+	if diagnostics == nil {
+		return
+	}
+	setNodeStatus(ks, nodeName, func(ns *kataTypes.KataNodeStatus) {
+		ns.Diagnostics = diagnostics
+	})
 }
 
 func getFailedNode(err error) (fn kataTypes.FailedNodeStatus, retErr error) {
@@ -69,3 +145,8 @@ func getHostName() (string, error) {
 func getNodeName() (string, error) {
 	return getHostName()
 }
+
+// NodeName returns the name of the node the daemon runs on
+func NodeName() (string, error) {
+	return getNodeName()
+}
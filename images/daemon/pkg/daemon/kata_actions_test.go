@@ -0,0 +1,82 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	kataTypes "github.com/openshift/sandboxed-containers-operator/api/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// unreachableClient fails the first gets as if the API server was down
+type unreachableClient struct {
+	client.Client
+	failures int
+	gets     int
+}
+
+func (c *unreachableClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	c.gets++
+	if c.gets <= c.failures {
+		return errors.New("connection refused")
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func withShortBackoff() func() {
+	saved := apiServerBackoff
+	apiServerBackoff = wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 5}
+	return func() { apiServerBackoff = saved }
+}
+
+func TestUpdateKataConfigStatusRetries(t *testing.T) {
+	defer withShortBackoff()()
+	host := newFakeHost(t)
+	defer host.cleanup()
+	k := newTestKataOpenShift(t, host)
+	c := &unreachableClient{Client: k.KataClient, failures: 2}
+
+	err := updateKataConfigStatus(c, testKataConfigName, func(ks *kataTypes.KataConfigStatus) {
+		ks.RuntimeClass = "kata"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.gets != 3 {
+		t.Errorf("got %d gets, want 3", c.gets)
+	}
+	if status := testKataConfigStatus(t, k); status.RuntimeClass != "kata" {
+		t.Errorf("status not updated: %+v", status)
+	}
+
+	c = &unreachableClient{Client: k.KataClient, failures: 10}
+	if err := updateKataConfigStatus(c, testKataConfigName, func(*kataTypes.KataConfigStatus) {}); err == nil {
+		t.Error("no error once the retries are used up")
+	}
+	if c.gets != apiServerBackoff.Steps {
+		t.Errorf("got %d gets, want one per backoff step", c.gets)
+	}
+}
+
+func TestUpdateKataConfigStatusNotFound(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	k := newTestKataOpenShift(t, host)
+	c := &unreachableClient{Client: k.KataClient}
+
+	start := time.Now()
+	err := updateKataConfigStatus(c, "missing", func(*kataTypes.KataConfigStatus) {
+		t.Error("status update applied to a missing KataConfig")
+	})
+	if !apierrors.IsNotFound(err) || c.gets != 1 {
+		t.Errorf("got %v after %d gets, want NotFound right away", err, c.gets)
+	}
+	if elapsed := time.Since(start); elapsed >= apiServerBackoff.Duration {
+		t.Errorf("NotFound took %v to surface", elapsed)
+	}
+}
@@ -3,22 +3,19 @@ package daemon
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
-	"os/exec"
 	"strings"
-	"syscall"
 
 	"github.com/containers/image/v5/copy"
 	"github.com/containers/image/v5/signature"
 	"github.com/containers/image/v5/transports/alltransports"
 	"github.com/containers/image/v5/types"
 	"github.com/coreos/go-semver/semver"
+	"github.com/go-logr/logr"
 	"github.com/opencontainers/image-tools/image"
-	confv1client "github.com/openshift/client-go/config/clientset/versioned/typed/config/v1"
-	kataTypes "github.com/openshift/kata-operator/api/v1"
-	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/tools/clientcmd"
+	configv1 "github.com/openshift/api/config/v1"
+	kataTypes "github.com/openshift/sandboxed-containers-operator/api/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -28,22 +25,33 @@ type KataExistance func() (bool, bool, error)
 // KataBinaryOperation installs the kata binaries on the node
 type KataBinaryOperation func(k *KataOpenShift) error
 
-//KataOpenShift is used for KataActions on OpenShift cluster nodes
+// ClusterVersionGetter returns the version of the cluster the kata payload is picked for
+type ClusterVersionGetter func(k *KataOpenShift) (string, error)
+
+// KataOpenShift is used for KataActions on OpenShift cluster nodes
 type KataOpenShift struct {
-	KataClient            client.Client
-	KataInstallChecker    KataExistance
-	KataUninstallChecker  KataExistance
-	KataBinaryInstaller   KataBinaryOperation
-	KataBinaryUnInstaller KataBinaryOperation
-	KataConfigPoolLabels  map[string]string
-	CRIODropinPath        string
-	PayloadTag            string
+	KataClient               client.Client
+	Log                      logr.Logger
+	KataInstallChecker       KataExistance
+	KataUninstallChecker     KataExistance
+	KataBinaryInstaller      KataBinaryOperation
+	KataBinaryUnInstaller    KataBinaryOperation
+	KataPreflightChecker     PreflightChecker
+	KataRollbacker           KataBinaryOperation
+	KataPayloadDownloader    KataBinaryOperation
+	KataDiagnosticsCollector DiagnosticsCollector
+	ClusterVersionGetter     ClusterVersionGetter
+	Host                     HostExecutor
+	KataConfigPoolLabels     map[string]string
+	CRIODropinPath           string
+	PayloadTag               string
 }
 
 var _ KataActions = (*KataOpenShift)(nil)
 
 // Install the kata binaries on Openshift
 func (k *KataOpenShift) Install(kataConfigResourceName string) error {
+	k.setDefaults()
 
 	if k.KataInstallChecker == nil {
 		k.KataInstallChecker = func() (bool, bool, error) {
@@ -54,9 +62,9 @@ func (k *KataOpenShift) Install(kataConfigResourceName string) error {
 				kataConfig            kataTypes.KataConfig
 			)
 
-			err = k.KataClient.Get(context.Background(), client.ObjectKey{
-				Name: kataConfigResourceName,
-			}, &kataConfig)
+			// Right after the reboot into the kata configuration the API server may
+			// still be down if it runs on this node
+			err = getKataConfig(k.KataClient, kataConfigResourceName, &kataConfig)
 			if err != nil {
 				return isKataInstalled, isCrioDropInInstalled, err
 			}
@@ -93,29 +101,62 @@ func (k *KataOpenShift) Install(kataConfigResourceName string) error {
 		return nil
 	}
 
-	k.PayloadTag, err = getClusterVersion()
+	nodeName, err := getNodeName()
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
-	log.Println("Kata operator payload tag: " + k.PayloadTag)
 
-	if k.KataBinaryInstaller == nil {
-		k.KataBinaryInstaller = installRPMs
+	// Don't retry a failed installation, roll the node back if requested
+	failed, err := k.handleFailedInstallation(kataConfigResourceName, nodeName)
+	if err != nil {
+		return err
+	}
+	if failed {
+		return nil
 	}
 
-	nodeName, err := getNodeName()
+	k.PayloadTag, err = k.ClusterVersionGetter(k)
 	if err != nil {
-		return err
+		return fmt.Errorf("unable to get the cluster version: %v", err)
+	}
+	k.Log.Info("Kata operator payload tag", "payloadTag", k.PayloadTag)
+
+	if k.KataBinaryInstaller == nil {
+		k.KataBinaryInstaller = installRPMs
 	}
 
 	if isKataInstalled {
 		// kata exist - mark completion if crio drop in file exists
 		if k.CRIODropinPath == "" {
-			k.CRIODropinPath = "/host/etc/crio/crio.conf.d/50-kata.conf"
+			k.CRIODropinPath = k.Host.Path("/etc/crio/crio.conf.d/50-kata.conf")
 		}
 		if _, err := os.Stat(k.CRIODropinPath); err == nil {
+			// The operator sizes the pod overhead of the runtime class from the sandbox VM settings
+			sandbox, sandboxErr := readSandboxConfig(k.Host, nodeName)
+			if sandboxErr != nil {
+				k.Log.Error(sandboxErr, "Unable to read the sandbox VM settings")
+			}
+			// The operator labels the node with the kata version
+			kataVersion, versionErr := installedKataVersion(k.Host)
+			if versionErr != nil {
+				k.Log.Error(versionErr, "Unable to get the installed kata version")
+			}
+
 			err = updateKataConfigStatus(k.KataClient, kataConfigResourceName, func(ks *kataTypes.KataConfigStatus) {
+				if sandboxErr == nil {
+					nodes := ks.OverheadStatus.NodesList
+					for i, n := range nodes {
+						if n.Name == nodeName {
+							nodes = append(nodes[:i], nodes[i+1:]...)
+							break
+						}
+					}
+					ks.OverheadStatus.NodesList = append(nodes, sandbox)
+				}
+				completeNodeOperation(ks, nodeName, kataTypes.NodePhaseInstalled, "")
+				setNodeStatus(ks, nodeName, func(ns *kataTypes.KataNodeStatus) {
+					ns.KataVersion = kataVersion
+				})
 				ks.InstallationStatus.Completed.CompletedNodesList = append(ks.InstallationStatus.Completed.CompletedNodesList, nodeName)
 				ks.InstallationStatus.Completed.CompletedNodesCount = len(ks.InstallationStatus.Completed.CompletedNodesList)
 				if ks.InstallationStatus.InProgress.InProgressNodesCount > 0 {
@@ -142,9 +183,20 @@ func (k *KataOpenShift) Install(kataConfigResourceName string) error {
 		}
 
 	} else {
+		// make sure the node is able to run kata before touching it
+		passed, err := k.runPreflightChecks(kataConfigResourceName, nodeName)
+		if err != nil {
+			return err
+		}
+		if !passed {
+			k.Log.Info("Node failed the preflight checks, skipping kata installation")
+			return nil
+		}
+
 		// kata doesn't exist, install it.
 		err = updateKataConfigStatus(k.KataClient, kataConfigResourceName, func(ks *kataTypes.KataConfigStatus) {
 			ks.InstallationStatus.InProgress.InProgressNodesCount++
+			startNodeOperation(ks, nodeName, kataTypes.NodePhaseInstalling, k.PayloadTag)
 		})
 
 		if err != nil {
@@ -155,6 +207,7 @@ func (k *KataOpenShift) Install(kataConfigResourceName string) error {
 
 		if err != nil {
 			// kata installation failed. report it.
+			diagnostics := k.collectDiagnostics(kataConfigResourceName, nodeName, err)
 			err = updateKataConfigStatus(k.KataClient, kataConfigResourceName, func(ks *kataTypes.KataConfigStatus) {
 				ks.InstallationStatus.InProgress.InProgressNodesCount--
 
@@ -165,16 +218,26 @@ func (k *KataOpenShift) Install(kataConfigResourceName string) error {
 
 				ks.InstallationStatus.Failed.FailedNodesList = append(ks.InstallationStatus.Failed.FailedNodesList, fn)
 				ks.InstallationStatus.Failed.FailedNodesCount = len(ks.InstallationStatus.Failed.FailedNodesList)
+				completeNodeOperation(ks, nodeName, kataTypes.NodePhaseFailed, fn.Error)
+				setNodeDiagnostics(ks, nodeName, diagnostics)
 			})
 
 			if err != nil {
 				return fmt.Errorf("kata installation failed, error updating kataconfig status %+v", err)
 			}
 
+			_, err = k.handleFailedInstallation(kataConfigResourceName, nodeName)
+			if err != nil {
+				return err
+			}
+
 		} else {
 			// mark binaries installed
 			err = updateKataConfigStatus(k.KataClient, kataConfigResourceName, func(ks *kataTypes.KataConfigStatus) {
 				ks.InstallationStatus.InProgress.BinariesInstalledNodesList = append(ks.InstallationStatus.InProgress.BinariesInstalledNodesList, nodeName)
+				setNodeStatus(ks, nodeName, func(ns *kataTypes.KataNodeStatus) {
+					ns.Phase = kataTypes.NodePhaseBinariesInstalled
+				})
 			})
 
 			if err != nil {
@@ -193,6 +256,7 @@ func (k *KataOpenShift) Upgrade() error {
 
 // Uninstall the kata binaries and configure the runtime on Openshift
 func (k *KataOpenShift) Uninstall(kataConfigResourceName string) error {
+	k.setDefaults()
 	if k.KataUninstallChecker == nil {
 		k.KataUninstallChecker = func() (bool, bool, error) {
 
@@ -256,6 +320,7 @@ func (k *KataOpenShift) Uninstall(kataConfigResourceName string) error {
 		// Kata binaries need to be uninstalled
 		err = updateKataConfigStatus(k.KataClient, kataConfigResourceName, func(ks *kataTypes.KataConfigStatus) {
 			ks.UnInstallationStatus.InProgress.InProgressNodesCount++
+			startNodeOperation(ks, nodeName, kataTypes.NodePhaseUninstalling, "")
 		})
 
 		if err != nil {
@@ -270,6 +335,7 @@ func (k *KataOpenShift) Uninstall(kataConfigResourceName string) error {
 
 		if err != nil {
 			// kata uninstallation failed. report it.
+			diagnostics := k.collectDiagnostics(kataConfigResourceName, nodeName, err)
 			err = updateKataConfigStatus(k.KataClient, kataConfigResourceName, func(ks *kataTypes.KataConfigStatus) {
 				ks.UnInstallationStatus.InProgress.InProgressNodesCount--
 
@@ -280,6 +346,8 @@ func (k *KataOpenShift) Uninstall(kataConfigResourceName string) error {
 
 				ks.UnInstallationStatus.Failed.FailedNodesList = append(ks.UnInstallationStatus.Failed.FailedNodesList, fn)
 				ks.UnInstallationStatus.Failed.FailedNodesCount = len(ks.UnInstallationStatus.Failed.FailedNodesList)
+				completeNodeOperation(ks, nodeName, kataTypes.NodePhaseFailed, fn.Error)
+				setNodeDiagnostics(ks, nodeName, diagnostics)
 			})
 
 			if err != nil {
@@ -290,6 +358,12 @@ func (k *KataOpenShift) Uninstall(kataConfigResourceName string) error {
 		// mark binaries uninstalled
 		err = updateKataConfigStatus(k.KataClient, kataConfigResourceName, func(ks *kataTypes.KataConfigStatus) {
 			ks.UnInstallationStatus.InProgress.BinariesUnInstalledNodesList = append(ks.UnInstallationStatus.InProgress.BinariesUnInstalledNodesList, nodeName)
+			for _, fn := range ks.UnInstallationStatus.Failed.FailedNodesList {
+				if fn.Name == nodeName {
+					return
+				}
+			}
+			completeNodeOperation(ks, nodeName, kataTypes.NodePhaseBinariesUninstalled, "")
 		})
 
 		if err != nil {
@@ -300,28 +374,102 @@ func (k *KataOpenShift) Uninstall(kataConfigResourceName string) error {
 	return nil
 }
 
-func doCmd(cmd *exec.Cmd) error {
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
-	fmt.Println(cmd.String())
+// setDefaults fills in the logger, the access to the node and the operations not set by the caller
+func (k *KataOpenShift) setDefaults() {
+	if k.Log == nil {
+		k.Log = ctrl.Log.WithName("daemon")
+	}
+	if k.Host == nil {
+		k.Host = &nodeExecutor{root: hostRoot}
+	}
+	if k.ClusterVersionGetter == nil {
+		k.ClusterVersionGetter = getClusterVersion
+	}
+	if k.KataPayloadDownloader == nil {
+		k.KataPayloadDownloader = downloadPayload
+	}
+}
+
+// ReportFailure records an error the operation returned in the status of the node, so that the
+// failure shows up in the KataConfig even if it happened before the daemon reported any progress.
+// The node is added to the failed nodes of the operation, which makes it eligible for a retry. An
+// error the operation recorded itself is kept, it tells more than the one returned after it.
+func (k *KataOpenShift) ReportFailure(kataConfigResourceName string, operation string, opErr error) {
+	k.setDefaults()
+
+	nodeName, err := getNodeName()
+	if err != nil {
+		k.Log.Error(err, "Unable to report the failure, unable to get the node name")
+		return
+	}
+
+	errMsg := fmt.Sprintf("%+v", opErr)
+	err = updateKataConfigStatus(k.KataClient, kataConfigResourceName, func(ks *kataTypes.KataConfigStatus) {
+		failed := &ks.InstallationStatus.Failed
+		if operation == "uninstall" {
+			failed = &ks.UnInstallationStatus.Failed
+		}
+		if !failedNodesContain(failed, nodeName) {
+			failed.FailedNodesList = append(failed.FailedNodesList, kataTypes.FailedNodeStatus{
+				Name:  nodeName,
+				Error: errMsg,
+			})
+			failed.FailedNodesCount = len(failed.FailedNodesList)
+		}
+
+		for _, ns := range ks.NodeStatus {
+			if ns.Name == nodeName && ns.Phase == kataTypes.NodePhaseFailed && ns.Error != "" {
+				return
+			}
+		}
+		completeNodeOperation(ks, nodeName, kataTypes.NodePhaseFailed, errMsg)
+	})
+	if err != nil {
+		k.Log.Error(err, "Unable to report the failure in the kataconfig status")
+	}
+}
+
+// failedNodesContain returns true if the node is in the failed nodes
+func failedNodesContain(failed *kataTypes.KataFailedNodeStatus, nodeName string) bool {
+	for _, fn := range failed.FailedNodesList {
+		if fn.Name == nodeName {
+			return true
+		}
+	}
+	return false
+}
+
+// runOnHost runs the command on the node and logs its output, the output is logged at
+// the debug level unless the command fails
+func (k *KataOpenShift) runOnHost(name string, arg ...string) error {
+	command := strings.Join(append([]string{name}, arg...), " ")
+	k.Log.Info("Running command", "command", command)
+	out, err := k.Host.Run(name, arg...)
 	if err != nil {
-		log.Println(err)
+		k.Log.Error(err, "Command failed", "command", command, "output", string(out))
 		return err
 	}
+	k.Log.V(1).Info("Command succeeded", "command", command, "output", string(out))
 
 	return nil
 }
 
-func cleanupHost() error {
-	cmd := exec.Command("/usr/bin/rm", "-rf", "/opt/kata-install")
-	err := doCmd(cmd)
+// installedKataVersion returns the version of the kata-containers package installed on the node
+func installedKataVersion(host HostExecutor) (string, error) {
+	out, err := host.Run("/usr/bin/rpm", "-q", "--queryformat", "%{VERSION}", "kata-containers")
+	if err != nil {
+		return "", fmt.Errorf("unable to query the kata-containers package: %v: %s", err, out)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (k *KataOpenShift) cleanupHost() error {
+	err := removeAll(k.Host.Path("/opt/kata-install"))
 	if err != nil {
 		return err
 	}
 
-	cmd = exec.Command("/usr/bin/rm", "-rf", "/usr/local/kata")
-	err = doCmd(cmd)
+	err = removeAll(k.Host.Path("/usr/local/kata"))
 	if err != nil {
 		return err
 	}
@@ -330,72 +478,98 @@ func cleanupHost() error {
 }
 
 func uninstallRPMs(k *KataOpenShift) error {
-	log.SetOutput(os.Stdout)
+	err := k.cleanupHost()
+	if err != nil {
+		k.Log.Error(err, "cleanupHost failed")
+	}
 
-	if err := syscall.Chroot("/host"); err != nil {
-		log.Fatalf("Unable to chroot to %s: %s", "/host", err)
+	err = k.runOnHost("rpm-ostree", "uninstall", "--idempotent", "--all") //FIXME not -a but kata-runtime, kata-osbuilder,...
+	if err != nil {
+		return err
 	}
 
-	if err := syscall.Chdir("/"); err != nil {
-		log.Fatalf("Unable to chdir to %s: %s", "/", err)
+	return nil
+}
+
+func installRPMs(k *KataOpenShift) error {
+	err := k.KataPayloadDownloader(k)
+	if err != nil {
+		return err
 	}
 
-	err := cleanupHost()
+	err = mkdirAll(k.Host.Path("/etc/yum.repos.d"), 0755)
 	if err != nil {
-		log.Println("cleanupHost failed")
+		return err
+	}
+
+	if err := installFile(k.Host.Path("/usr/local/kata/latest/packages.repo"),
+		k.Host.Path("/etc/yum.repos.d/packages.repo")); err != nil {
+		return err
+	}
+
+	// A copy interrupted by a restart of the daemon is resumed
+	if err = copyTree(k.Host.Path("/usr/local/kata/latest/packages"),
+		k.Host.Path("/opt/kata-install/packages")); err != nil {
+		return err
 	}
 
-	cmd := exec.Command("rpm-ostree", "uninstall", "--idempotent", "--all") //FIXME not -a but kata-runtime, kata-osbuilder,...
-	err = doCmd(cmd)
+	err = k.runOnHost("/usr/bin/rpm-ostree", "install", "--idempotent", "kata-containers")
 	if err != nil {
 		return err
 	}
 
+	err = k.cleanupHost()
+	if err != nil {
+		k.Log.Error(err, "cleanupHost failed")
+	}
+
 	return nil
+
 }
 
-func installRPMs(k *KataOpenShift) error {
-	fmt.Fprintf(os.Stderr, "%s\n", os.Getenv("PATH"))
-	log.SetOutput(os.Stdout)
+// downloadPayload pulls the kata payload image and unpacks it to /usr/local/kata/latest on the node
+func downloadPayload(k *KataOpenShift) error {
+	k.Log.V(1).Info("Downloading the kata payload", "PATH", os.Getenv("PATH"))
 
-	cmd := exec.Command("mkdir", "-p", "/host/opt/kata-install")
-	err := doCmd(cmd)
+	err := mkdirAll(k.Host.Path("/opt/kata-install"), 0755)
 	if err != nil {
 		return err
 	}
 
-	if err := syscall.Chroot("/host"); err != nil {
-		log.Fatalf("Unable to chroot to %s: %s", "/host", err)
-	}
-
-	if err := syscall.Chdir("/"); err != nil {
-		log.Fatalf("Unable to chdir to %s: %s", "/", err)
+	// The signature policy, the registries configuration and the certificates of the node apply, the
+	// blobs are downloaded to the disk of the node rather than the container
+	hostCtx := types.SystemContext{
+		RootForImplicitAbsolutePaths: k.Host.Path("/"),
+		BigFilesTemporaryDir:         k.Host.Path("/var/tmp"),
 	}
 
-	policy, err := signature.DefaultPolicy(nil)
+	policy, err := signature.DefaultPolicy(&hostCtx)
 	if err != nil {
-		fmt.Println(err)
+		k.Log.Error(err, "Unable to read the default signature policy")
 	}
 	policyContext, err := signature.NewPolicyContext(policy)
 	if err != nil {
-		fmt.Println(err)
+		k.Log.Error(err, "Unable to create the signature policy context")
+	}
+
+	authFile, cleanupAuth, err := k.payloadAuthFile()
+	if err != nil {
+		return err
+	}
+	defer cleanupAuth()
+	sourceCtx := hostCtx
+	sourceCtx.AuthFilePath = authFile
+	// The credentials of the legacy secret take precedence over the cluster pull secret, as they did before
+	if legacyAuth := legacyPayloadCredentials(); legacyAuth != nil {
+		k.Log.Info("WARNING: the payload image is pulled with the credentials of the deprecated secret payload-secret, " +
+			"create a pull secret and reference it in spec.config.pullSecret of the KataConfig instead")
+		sourceCtx.DockerAuthConfig = legacyAuth
 	}
 
 	payloadImage := os.Getenv("KATA_PAYLOAD_IMAGE")
-	sourceCtx := &types.SystemContext{}
 	if payloadImage != "" {
-		username := strings.Replace(os.Getenv("PAYLOAD_REGISTRY_USERNAME"), "\n", "", -1)
-		password := strings.Replace(os.Getenv("PAYLOAD_REGISTRY_PASSWORD"), "\n", "", -1)
-		if username != "" && password != "" {
-			sourceCtx = &types.SystemContext{
-				DockerAuthConfig: &types.DockerAuthConfig{
-					Username: username,
-					Password: password,
-				},
-			}
-		}
-		log.Println("WARNING: private payload image in use")
-		log.Println("Using env variable KATA_PAYLOAD_IMAGE " + payloadImage)
+		k.Log.Info("WARNING: private payload image in use, set by the env variable KATA_PAYLOAD_IMAGE",
+			"payloadImage", payloadImage)
 		payloadImage = "docker://" + payloadImage
 	} else {
 		payloadImage = "docker://quay.io/isolatedcontainers/kata-operator-payload:" + k.PayloadTag
@@ -403,76 +577,41 @@ func installRPMs(k *KataOpenShift) error {
 
 	srcRef, err := alltransports.ParseImageName(payloadImage)
 	if err != nil {
-		fmt.Println("Invalid source name of payload container image: " + payloadImage)
+		k.Log.Error(err, "Invalid source name of payload container image", "payloadImage", payloadImage)
 		return err
 	}
-	destRef, err := alltransports.ParseImageName("oci:/opt/kata-install/kata-image:latest")
+	destRef, err := alltransports.ParseImageName("oci:" + k.Host.Path("/opt/kata-install/kata-image") + ":latest")
 	if err != nil {
-		fmt.Println("Invalid destination name")
+		k.Log.Error(err, "Invalid destination name")
 		return err
 	}
 
 	_, err = copy.Image(context.Background(), policyContext, destRef, srcRef,
-		&copy.Options{SourceCtx: sourceCtx})
+		&copy.Options{SourceCtx: &sourceCtx, DestinationCtx: &hostCtx})
 
 	if err != nil {
-		fmt.Println("Error occured when downloading payload image:")
-		fmt.Println(err)
-		if os.Getenv("PAYLOAD_REGISTRY_USERNAME") != "" {
-			fmt.Println("payload secret env vars are set and used. Please check the credentials used?")
+		k.Log.Error(err, "Error occured when downloading payload image", "payloadImage", payloadImage)
+		if sourceCtx.DockerAuthConfig != nil {
+			k.Log.Info("The payload image was pulled with the credentials of payload-secret, check them")
+		} else if authFile != "" {
+			k.Log.Info("The payload image was pulled with a pull secret, check the credentials it holds for the registry")
 		}
 		return err
 	}
 
-	err = image.CreateRuntimeBundleLayout("/opt/kata-install/kata-image/",
-		"/usr/local/kata", "latest", "linux", []string{"name=latest"})
+	err = image.CreateRuntimeBundleLayout(k.Host.Path("/opt/kata-install/kata-image/"),
+		k.Host.Path("/usr/local/kata"), "latest", "linux", []string{"name=latest"})
 	if err != nil {
-		fmt.Println("error creating Runtime bundle layout in /usr/local/kata")
+		k.Log.Error(err, "error creating Runtime bundle layout in /usr/local/kata")
 		return err
 	}
 
-	cmd = exec.Command("mkdir", "-p", "/etc/yum.repos.d/")
-	err = doCmd(cmd)
-	if err != nil {
-		return err
-	}
-
-	cmd = exec.Command("/usr/bin/cp", "-f", "/usr/local/kata/latest/packages.repo",
-		"/etc/yum.repos.d/")
-	if err := doCmd(cmd); err != nil {
-		return err
-	}
-
-	cmd = exec.Command("/usr/bin/cp", "-a",
-		"/usr/local/kata/latest/packages", "/opt/kata-install/packages")
-	if err = doCmd(cmd); err != nil {
-		return err
-	}
-
-	cmd = exec.Command("/bin/bash", "-c", "/usr/bin/rpm-ostree install --idempotent kata-containers")
-	err = doCmd(cmd)
-	if err != nil {
-		return err
-	}
-
-	err = cleanupHost()
-	if err != nil {
-		log.Println("cleanupHost failed")
-	}
-
 	return nil
-
 }
 
-func getClusterVersion() (string, error) {
-	myconfig, err := clientcmd.BuildConfigFromFlags("", "")
-	if err != nil {
-		return "", err
-	}
-	myconfclient, err := confv1client.NewForConfig(myconfig)
-
-	myversion := "version"
-	clusterversion, err := myconfclient.ClusterVersions().Get(context.Background(), myversion, metaV1.GetOptions{})
+func getClusterVersion(k *KataOpenShift) (string, error) {
+	clusterversion := &configv1.ClusterVersion{}
+	err := k.KataClient.Get(context.Background(), client.ObjectKey{Name: "version"}, clusterversion)
 	if err != nil {
 		return "", err
 	}
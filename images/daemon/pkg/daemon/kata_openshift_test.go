@@ -0,0 +1,399 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	kataTypes "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+const testKataConfigName = "example-kataconfig"
+
+// newTestKataOpenShift returns a daemon working on the fake host with a fake client holding the KataConfig.
+// The payload download, the preflight checks and the diagnostics collection are stubbed.
+func newTestKataOpenShift(t *testing.T, host *fakeHost) *KataOpenShift {
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{clientgoscheme.AddToScheme, kataTypes.AddToScheme, configv1.Install} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	kataConfig := &kataTypes.KataConfig{ObjectMeta: metav1.ObjectMeta{Name: testKataConfigName}}
+	clusterVersion := &configv1.ClusterVersion{
+		ObjectMeta: metav1.ObjectMeta{Name: "version"},
+		Status: configv1.ClusterVersionStatus{
+			Desired: configv1.Release{Version: "4.8.2"},
+		},
+	}
+
+	return &KataOpenShift{
+		KataClient: fake.NewFakeClientWithScheme(scheme, kataConfig, clusterVersion),
+		Log:        zap.LoggerTo(ioutil.Discard, true),
+		Host:       host,
+		KataPreflightChecker: func(k *KataOpenShift) []kataTypes.PreflightCheckResult {
+			return []kataTypes.PreflightCheckResult{{Name: "kvm", Passed: true}}
+		},
+		KataPayloadDownloader: func(k *KataOpenShift) error {
+			host.writeFile(t, "/usr/local/kata/latest/packages.repo", "[packages]\nbaseurl=file:///opt/kata-install/packages\n")
+			host.writeFile(t, "/usr/local/kata/latest/packages/kata-containers.rpm", "rpm")
+			return os.MkdirAll(host.Path("/opt/kata-install"), 0755)
+		},
+		KataDiagnosticsCollector: func(k *KataOpenShift, opErr error) ([]byte, error) {
+			return []byte(opErr.Error()), nil
+		},
+	}
+}
+
+func testKataConfigStatus(t *testing.T, k *KataOpenShift) kataTypes.KataConfigStatus {
+	var kataConfig kataTypes.KataConfig
+	if err := k.KataClient.Get(context.Background(), client.ObjectKey{Name: testKataConfigName}, &kataConfig); err != nil {
+		t.Fatal(err)
+	}
+	return kataConfig.Status
+}
+
+func testNodeStatus(t *testing.T, status kataTypes.KataConfigStatus, nodeName string) kataTypes.KataNodeStatus {
+	for _, ns := range status.NodeStatus {
+		if ns.Name == nodeName {
+			return ns
+		}
+	}
+	t.Fatalf("no status for node %s", nodeName)
+	return kataTypes.KataNodeStatus{}
+}
+
+func testNodeName(t *testing.T) string {
+	nodeName, err := getNodeName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nodeName
+}
+
+func TestInstall(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	k := newTestKataOpenShift(t, host)
+	nodeName := testNodeName(t)
+
+	if err := k.Install(testKataConfigName); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	expected := []string{"/usr/bin/rpm-ostree install --idempotent kata-containers"}
+	if !reflect.DeepEqual(host.commands, expected) {
+		t.Errorf("unexpected commands\n got: %q\nwant: %q", host.commands, expected)
+	}
+	if !host.exists("/etc/yum.repos.d/packages.repo") {
+		t.Error("the package repository is not installed on the node")
+	}
+	if host.exists("/usr/local/kata") || host.exists("/opt/kata-install") {
+		t.Error("the payload is left behind on the node")
+	}
+
+	status := testKataConfigStatus(t, k)
+	if !reflect.DeepEqual(status.PreflightStatus.PassedNodesList, []string{nodeName}) {
+		t.Errorf("unexpected preflight status %+v", status.PreflightStatus)
+	}
+	if !reflect.DeepEqual(status.InstallationStatus.InProgress.BinariesInstalledNodesList, []string{nodeName}) {
+		t.Errorf("unexpected installation status %+v", status.InstallationStatus)
+	}
+	ns := testNodeStatus(t, status, nodeName)
+	if ns.Phase != kataTypes.NodePhaseBinariesInstalled || ns.PayloadVersion != "4.8.2" || ns.StartTime == nil {
+		t.Errorf("unexpected node status %+v", ns)
+	}
+}
+
+func TestInstallRerun(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	k := newTestKataOpenShift(t, host)
+	nodeName := testNodeName(t)
+
+	if err := k.Install(testKataConfigName); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	// The binaries are installed, the daemon waits for the CRI-O drop-in of the MachineConfig
+	host.commands = nil
+	if err := k.Install(testKataConfigName); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if len(host.commands) > 0 {
+		t.Errorf("commands run on a node waiting for the CRI-O drop-in: %q", host.commands)
+	}
+	if completed := testKataConfigStatus(t, k).InstallationStatus.Completed.CompletedNodesList; len(completed) > 0 {
+		t.Errorf("node completed before the CRI-O drop-in exists: %v", completed)
+	}
+
+	host.writeFile(t, "/etc/crio/crio.conf.d/50-kata.conf", "[crio.runtime.runtimes.kata]\n")
+	host.writeFile(t, "/etc/kata-containers/configuration.toml", "[hypervisor.qemu]\ndefault_memory = 4096\ndefault_vcpus = 1\n")
+	host.outputs["/usr/bin/rpm -q"] = "2.1.0\n"
+	for i := 0; i < 2; i++ {
+		if err := k.Install(testKataConfigName); err != nil {
+			t.Fatalf("Install failed: %v", err)
+		}
+	}
+	// Only the kata version is queried once the node completed
+	if expected := []string{"/usr/bin/rpm -q --queryformat %{VERSION} kata-containers"}; !reflect.DeepEqual(host.commands, expected) {
+		t.Errorf("unexpected commands on an installed node\n got: %q\nwant: %q", host.commands, expected)
+	}
+
+	status := testKataConfigStatus(t, k)
+	if !reflect.DeepEqual(status.InstallationStatus.Completed.CompletedNodesList, []string{nodeName}) ||
+		len(status.InstallationStatus.InProgress.BinariesInstalledNodesList) > 0 ||
+		status.InstallationStatus.InProgress.InProgressNodesCount != 0 {
+		t.Errorf("unexpected installation status %+v", status.InstallationStatus)
+	}
+	expectedSandbox := []kataTypes.NodeSandboxConfig{{
+		Name:             nodeName,
+		Hypervisor:       "qemu",
+		DefaultMemoryMiB: 4096,
+		DefaultVCPUs:     1,
+	}}
+	if !reflect.DeepEqual(status.OverheadStatus.NodesList, expectedSandbox) {
+		t.Errorf("unexpected sandbox settings %+v", status.OverheadStatus.NodesList)
+	}
+	ns := testNodeStatus(t, status, nodeName)
+	if ns.Phase != kataTypes.NodePhaseInstalled || ns.CompletionTime == nil || ns.KataVersion != "2.1.0" {
+		t.Errorf("unexpected node status %+v", ns)
+	}
+}
+
+func TestInstallFailure(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	k := newTestKataOpenShift(t, host)
+	nodeName := testNodeName(t)
+	host.failures["/usr/bin/rpm-ostree install"] = errors.New("exit status 1")
+
+	os.Setenv("POD_NAMESPACE", "sandboxed-containers-operator-system")
+	defer os.Unsetenv("POD_NAMESPACE")
+
+	if err := k.Install(testKataConfigName); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	// The failed node is rolled back right away with the default rollback policy
+	for _, c := range []string{"rpm-ostree cancel", "rpm-ostree cleanup --pending"} {
+		if !host.ran(c) {
+			t.Errorf("%q not run during the rollback, commands: %q", c, host.commands)
+		}
+	}
+	if host.exists("/etc/yum.repos.d/packages.repo") {
+		t.Error("the package repository is left behind on the node")
+	}
+
+	status := testKataConfigStatus(t, k)
+	failed := status.InstallationStatus.Failed.FailedNodesList
+	if len(failed) != 1 || failed[0].Name != nodeName || failed[0].Error != "exit status 1" {
+		t.Errorf("unexpected failed nodes %+v", failed)
+	}
+	if !reflect.DeepEqual(status.RollbackStatus.CompletedNodesList, []string{nodeName}) {
+		t.Errorf("unexpected rollback status %+v", status.RollbackStatus)
+	}
+
+	ns := testNodeStatus(t, status, nodeName)
+	if ns.Phase != kataTypes.NodePhaseFailed || ns.Error != "exit status 1" || ns.Diagnostics == nil {
+		t.Fatalf("unexpected node status %+v", ns)
+	}
+	if !host.exists(ns.Diagnostics.HostPath) {
+		t.Errorf("diagnostics %s not saved on the node", ns.Diagnostics.HostPath)
+	}
+	cm := &corev1.ConfigMap{}
+	if err := k.KataClient.Get(context.Background(), client.ObjectKey{
+		Namespace: "sandboxed-containers-operator-system",
+		Name:      ns.Diagnostics.ConfigMap,
+	}, cm); err != nil {
+		t.Fatalf("diagnostics not stored in a ConfigMap: %v", err)
+	}
+	if string(cm.BinaryData[diagnosticsConfigMapKey]) != "exit status 1" ||
+		cm.Labels[kataTypes.OwnerLabel] != testKataConfigName {
+		t.Errorf("unexpected diagnostics ConfigMap %+v", cm)
+	}
+
+	// A failed node is not installed again until it is retried
+	host.commands = nil
+	if err := k.Install(testKataConfigName); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if len(host.commands) > 0 {
+		t.Errorf("commands run on a failed node: %q", host.commands)
+	}
+}
+
+func TestInstallPreflightFailure(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	k := newTestKataOpenShift(t, host)
+	nodeName := testNodeName(t)
+
+	// The node has neither /dev/kvm nor the files of /proc the checks read
+	k.KataPreflightChecker = hostPreflightChecks
+
+	if err := k.Install(testKataConfigName); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if len(host.commands) > 0 {
+		t.Errorf("commands run on a node failing the preflight checks: %q", host.commands)
+	}
+
+	status := testKataConfigStatus(t, k)
+	failed := status.PreflightStatus.Failed.FailedNodesList
+	if len(failed) != 1 || failed[0].Name != nodeName {
+		t.Errorf("unexpected preflight status %+v", status.PreflightStatus)
+	}
+	if len(status.InstallationStatus.InProgress.BinariesInstalledNodesList) > 0 {
+		t.Errorf("unexpected installation status %+v", status.InstallationStatus)
+	}
+}
+
+func TestInstallNestedVirtualization(t *testing.T) {
+	for _, test := range []struct {
+		flags  string
+		nested bool
+	}{
+		{"fpu vme vmx hypervisor", true},
+		{"fpu vme svm", false},
+		{"fpu vme hypervisor", false},
+	} {
+		host := newFakeHost(t)
+		k := newTestKataOpenShift(t, host)
+		host.writeFile(t, "/proc/cpuinfo", "processor\t: 0\nflags\t\t: "+test.flags+"\n")
+
+		if err := k.Install(testKataConfigName); err != nil {
+			t.Fatalf("Install failed: %v", err)
+		}
+
+		results := testKataConfigStatus(t, k).PreflightStatus.NodeResults
+		if len(results) != 1 || results[0].NestedVirtualization == nil || *results[0].NestedVirtualization != test.nested {
+			t.Errorf("cpu flags %q: unexpected preflight results %+v, want nested virtualization %v",
+				test.flags, results, test.nested)
+		}
+		host.cleanup()
+	}
+}
+
+func TestUninstall(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	k := newTestKataOpenShift(t, host)
+	nodeName := testNodeName(t)
+	host.writeFile(t, "/usr/local/kata/latest/packages.repo", "")
+
+	if err := k.Uninstall(testKataConfigName); err != nil {
+		t.Fatalf("Uninstall failed: %v", err)
+	}
+
+	expected := []string{"rpm-ostree uninstall --idempotent --all"}
+	if !reflect.DeepEqual(host.commands, expected) {
+		t.Errorf("unexpected commands\n got: %q\nwant: %q", host.commands, expected)
+	}
+	if host.exists("/usr/local/kata") {
+		t.Error("the payload is left behind on the node")
+	}
+
+	status := testKataConfigStatus(t, k)
+	if !reflect.DeepEqual(status.UnInstallationStatus.InProgress.BinariesUnInstalledNodesList, []string{nodeName}) {
+		t.Errorf("unexpected uninstallation status %+v", status.UnInstallationStatus)
+	}
+	if ns := testNodeStatus(t, status, nodeName); ns.Phase != kataTypes.NodePhaseBinariesUninstalled {
+		t.Errorf("unexpected node status %+v", ns)
+	}
+
+	host.commands = nil
+	if err := k.Uninstall(testKataConfigName); err != nil {
+		t.Fatalf("Uninstall failed: %v", err)
+	}
+	if len(host.commands) > 0 {
+		t.Errorf("commands run on an uninstalled node: %q", host.commands)
+	}
+}
+
+func TestUninstallFailure(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	k := newTestKataOpenShift(t, host)
+	nodeName := testNodeName(t)
+	host.failures["rpm-ostree uninstall"] = errors.New("exit status 1")
+
+	if err := k.Uninstall(testKataConfigName); err != nil {
+		t.Fatalf("Uninstall failed: %v", err)
+	}
+
+	status := testKataConfigStatus(t, k)
+	failed := status.UnInstallationStatus.Failed.FailedNodesList
+	if len(failed) != 1 || failed[0].Name != nodeName {
+		t.Errorf("unexpected failed nodes %+v", failed)
+	}
+	// Without the operator namespace the diagnostics are only kept on the node
+	ns := testNodeStatus(t, status, nodeName)
+	if ns.Phase != kataTypes.NodePhaseFailed || ns.Diagnostics == nil || ns.Diagnostics.ConfigMap != "" {
+		t.Fatalf("unexpected node status %+v", ns)
+	}
+	if !host.exists(ns.Diagnostics.HostPath) {
+		t.Errorf("diagnostics %s not saved on the node", ns.Diagnostics.HostPath)
+	}
+}
+
+func TestReportFailure(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	k := newTestKataOpenShift(t, host)
+	nodeName := testNodeName(t)
+
+	// The payload download failed before the installation got under way
+	k.ReportFailure(testKataConfigName, "install", errors.New("payload download failed"))
+
+	status := testKataConfigStatus(t, k)
+	failed := status.InstallationStatus.Failed
+	if failed.FailedNodesCount != 1 || len(failed.FailedNodesList) != 1 ||
+		failed.FailedNodesList[0].Name != nodeName || failed.FailedNodesList[0].Error != "payload download failed" {
+		t.Errorf("node not added to the failed nodes: %+v", failed)
+	}
+	if ns := testNodeStatus(t, status, nodeName); ns.Phase != kataTypes.NodePhaseFailed || ns.Error != "payload download failed" {
+		t.Errorf("unexpected node status %+v", ns)
+	}
+
+	k.ReportFailure(testKataConfigName, "uninstall", errors.New("uninstallation failed"))
+	status = testKataConfigStatus(t, k)
+	if status.InstallationStatus.Failed.FailedNodesCount != 1 || status.UnInstallationStatus.Failed.FailedNodesCount != 1 {
+		t.Errorf("failure not reported for the uninstallation only: %+v, %+v",
+			status.InstallationStatus.Failed, status.UnInstallationStatus.Failed)
+	}
+}
+
+func TestReportFailureKeepsRecordedError(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	k := newTestKataOpenShift(t, host)
+	nodeName := testNodeName(t)
+	host.failures["/usr/bin/rpm-ostree install"] = errors.New("exit status 1")
+	k.KataRollbacker = func(k *KataOpenShift) error { return nil }
+
+	if err := k.Install(testKataConfigName); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	k.ReportFailure(testKataConfigName, "install", errors.New("error updating kataconfig status"))
+
+	status := testKataConfigStatus(t, k)
+	if len(status.InstallationStatus.Failed.FailedNodesList) != 1 || status.InstallationStatus.Failed.FailedNodesList[0].Error != "exit status 1" {
+		t.Errorf("unexpected failed nodes %+v", status.InstallationStatus.Failed)
+	}
+	if ns := testNodeStatus(t, status, nodeName); ns.Error != "exit status 1" {
+		t.Errorf("error recorded by the installation overwritten: %+v", ns)
+	}
+}
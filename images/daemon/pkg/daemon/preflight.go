@@ -0,0 +1,340 @@
+package daemon
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	kataTypes "github.com/openshift/sandboxed-containers-operator/api/v1"
+)
+
+// PreflightChecker runs the host checks that decide if a node is able to run kata VMs
+type PreflightChecker func(k *KataOpenShift) []kataTypes.PreflightCheckResult
+
+const (
+	// hostRoot is where the root filesystem of the node is mounted in the daemon pod
+	hostRoot = "/host"
+
+	// minimum amount of available memory to start at least one kata VM
+	preflightMinMemoryBytes = 2 * 1024 * 1024 * 1024
+	// minimum amount of free disk space needed for the payload and the layered rpms
+	preflightMinDiskBytes = 1024 * 1024 * 1024
+
+	preflightMinKernelMajor = 4
+	preflightMinKernelMinor = 18
+)
+
+// runPreflightChecks runs the preflight checks on the node and reports the results in the KataConfig
+// status, replacing the ones of an earlier run. The checks run every time the daemon is about to install
+// kata, so a node that got fixed passes once the daemon is restarted. It returns false if the node failed the checks.
+func (k *KataOpenShift) runPreflightChecks(kataConfigResourceName string, nodeName string) (bool, error) {
+	if k.KataPreflightChecker == nil {
+		k.KataPreflightChecker = hostPreflightChecks
+	}
+
+	results := k.KataPreflightChecker(k)
+
+	var failedChecks []string
+	for _, r := range results {
+		k.Log.Info("Preflight check", "check", r.Name, "passed", r.Passed, "message", r.Message)
+		if !r.Passed {
+			failedChecks = append(failedChecks, r.Name+": "+r.Message)
+		}
+	}
+	passed := len(failedChecks) == 0
+
+	nodeResult := kataTypes.NodePreflightResult{
+		Name:   nodeName,
+		Checks: results,
+	}
+	// The operator labels the node with it, it stays unknown if the cpu flags can't be read
+	if flags, err := cpuFlags(k.Host.Path("/proc/cpuinfo")); err == nil {
+		nested := nestedVirtualization(flags)
+		nodeResult.NestedVirtualization = &nested
+	}
+
+	err := updateKataConfigStatus(k.KataClient, kataConfigResourceName, func(ks *kataTypes.KataConfigStatus) {
+		removePreflightResults(&ks.PreflightStatus, nodeName)
+		ks.PreflightStatus.NodeResults = append(ks.PreflightStatus.NodeResults, nodeResult)
+		if passed {
+			ks.PreflightStatus.PassedNodesList = append(ks.PreflightStatus.PassedNodesList, nodeName)
+		} else {
+			ks.PreflightStatus.Failed.FailedNodesList = append(ks.PreflightStatus.Failed.FailedNodesList, kataTypes.FailedNodeStatus{
+				Name:  nodeName,
+				Error: "preflight checks failed: " + strings.Join(failedChecks, "; "),
+			})
+			ks.PreflightStatus.Failed.FailedNodesCount = len(ks.PreflightStatus.Failed.FailedNodesList)
+		}
+	})
+	if err != nil {
+		return false, fmt.Errorf("preflight checks done, error updating kataconfig status %+v", err)
+	}
+
+	return passed, nil
+}
+
+// removePreflightResults removes the results of an earlier run on the node
+func removePreflightResults(ps *kataTypes.KataPreflightStatus, nodeName string) {
+	var nodeResults []kataTypes.NodePreflightResult
+	for _, r := range ps.NodeResults {
+		if r.Name != nodeName {
+			nodeResults = append(nodeResults, r)
+		}
+	}
+	ps.NodeResults = nodeResults
+
+	var passed []string
+	for _, n := range ps.PassedNodesList {
+		if n != nodeName {
+			passed = append(passed, n)
+		}
+	}
+	ps.PassedNodesList = passed
+
+	var failed []kataTypes.FailedNodeStatus
+	for _, fn := range ps.Failed.FailedNodesList {
+		if fn.Name != nodeName {
+			failed = append(failed, fn)
+		}
+	}
+	ps.Failed.FailedNodesList = failed
+	ps.Failed.FailedNodesCount = len(failed)
+}
+
+func hostPreflightChecks(k *KataOpenShift) []kataTypes.PreflightCheckResult {
+	return []kataTypes.PreflightCheckResult{
+		checkKVMDevice(k.Host),
+		checkCPUVirtualization(k.Host),
+		checkAvailableMemory(k.Host),
+		checkDiskSpace(k.Host, "/opt"),
+		checkDiskSpace(k.Host, "/usr/local"),
+		checkSELinux(k.Host),
+		checkKernelVersion(k.Host),
+	}
+}
+
+func checkKVMDevice(host HostExecutor) kataTypes.PreflightCheckResult {
+	result := kataTypes.PreflightCheckResult{Name: "kvm"}
+
+	fi, err := os.Stat(host.Path("/dev/kvm"))
+	if err != nil {
+		result.Message = fmt.Sprintf("/dev/kvm not usable: %v", err)
+		return result
+	}
+
+	mode := fi.Mode()
+	if mode&os.ModeCharDevice == 0 {
+		result.Message = "/dev/kvm is not a character device"
+		return result
+	}
+	if mode.Perm()&0600 != 0600 {
+		result.Message = fmt.Sprintf("/dev/kvm has insufficient permissions %v", mode.Perm())
+		return result
+	}
+
+	result.Passed = true
+	result.Message = fmt.Sprintf("/dev/kvm present with permissions %v", mode.Perm())
+	return result
+}
+
+func checkCPUVirtualization(host HostExecutor) kataTypes.PreflightCheckResult {
+	result := kataTypes.PreflightCheckResult{Name: "cpu-virtualization"}
+
+	flags, err := cpuFlags(host.Path("/proc/cpuinfo"))
+	if err != nil {
+		result.Message = fmt.Sprintf("unable to read cpu flags: %v", err)
+		return result
+	}
+
+	var virtFlag string
+	for _, f := range []string{"vmx", "svm"} {
+		if flags[f] {
+			virtFlag = f
+			break
+		}
+	}
+	if virtFlag == "" {
+		result.Message = "cpu does not expose vmx or svm, hardware or nested virtualization is required"
+		return result
+	}
+
+	result.Passed = true
+	if nestedVirtualization(flags) {
+		result.Message = virtFlag + " available through nested virtualization"
+	} else {
+		result.Message = virtFlag + " available"
+	}
+	return result
+}
+
+// nestedVirtualization returns true if the node is a VM itself which exposes the virtualization
+// extensions to its guests
+func nestedVirtualization(flags map[string]bool) bool {
+	// we are a guest ourselves, so vmx or svm can only be there because of nested virtualization
+	return flags["hypervisor"] && (flags["vmx"] || flags["svm"])
+}
+
+func cpuFlags(cpuinfoPath string) (map[string]bool, error) {
+	f, err := os.Open(cpuinfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	flags := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "flags") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		for _, flag := range strings.Fields(parts[1]) {
+			flags[flag] = true
+		}
+		// all cpus report the same flags
+		break
+	}
+
+	return flags, scanner.Err()
+}
+
+func checkAvailableMemory(host HostExecutor) kataTypes.PreflightCheckResult {
+	result := kataTypes.PreflightCheckResult{Name: "memory"}
+
+	available, err := memAvailable(host.Path("/proc/meminfo"))
+	if err != nil {
+		result.Message = fmt.Sprintf("unable to read available memory: %v", err)
+		return result
+	}
+
+	result.Message = fmt.Sprintf("%d MiB available", available/(1024*1024))
+	result.Passed = available >= preflightMinMemoryBytes
+	if !result.Passed {
+		result.Message += fmt.Sprintf(", at least %d MiB required", preflightMinMemoryBytes/(1024*1024))
+	}
+	return result
+}
+
+func memAvailable(meminfoPath string) (uint64, error) {
+	f, err := os.Open(meminfoPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemAvailable:" {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return kb * 1024, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	return 0, fmt.Errorf("MemAvailable not found in %s", meminfoPath)
+}
+
+func checkDiskSpace(host HostExecutor, path string) kataTypes.PreflightCheckResult {
+	result := kataTypes.PreflightCheckResult{Name: "disk-space-" + strings.Trim(strings.Replace(path, "/", "-", -1), "-")}
+
+	// the directory is created during installation, check the filesystem it is going to live on
+	statPath := host.Path(path)
+	for statPath != host.Path("/") {
+		if _, err := os.Stat(statPath); !os.IsNotExist(err) {
+			break
+		}
+		statPath = filepath.Dir(statPath)
+	}
+
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(statPath, &st); err != nil {
+		result.Message = fmt.Sprintf("unable to stat %s: %v", path, err)
+		return result
+	}
+
+	available := st.Bavail * uint64(st.Bsize)
+	result.Message = fmt.Sprintf("%d MiB available under %s", available/(1024*1024), path)
+	result.Passed = available >= preflightMinDiskBytes
+	if !result.Passed {
+		result.Message += fmt.Sprintf(", at least %d MiB required", preflightMinDiskBytes/(1024*1024))
+	}
+	return result
+}
+
+// checkSELinux reports the SELinux mode of the node, kata runs in every mode so the check always passes
+func checkSELinux(host HostExecutor) kataTypes.PreflightCheckResult {
+	result := kataTypes.PreflightCheckResult{Name: "selinux", Passed: true}
+
+	enforce, err := ioutil.ReadFile(host.Path("/sys/fs/selinux/enforce"))
+	if os.IsNotExist(err) {
+		result.Message = "disabled"
+		return result
+	} else if err != nil {
+		result.Message = fmt.Sprintf("unable to read selinux mode: %v", err)
+		return result
+	}
+
+	if strings.TrimSpace(string(enforce)) == "1" {
+		result.Message = "enforcing"
+	} else {
+		result.Message = "permissive"
+	}
+	return result
+}
+
+func checkKernelVersion(host HostExecutor) kataTypes.PreflightCheckResult {
+	result := kataTypes.PreflightCheckResult{Name: "kernel-version"}
+
+	osrelease, err := ioutil.ReadFile(host.Path("/proc/sys/kernel/osrelease"))
+	if err != nil {
+		result.Message = fmt.Sprintf("unable to get kernel version: %v", err)
+		return result
+	}
+	release := strings.TrimSpace(string(osrelease))
+
+	major, minor, err := parseKernelVersion(release)
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+
+	result.Message = release
+	result.Passed = major > preflightMinKernelMajor ||
+		(major == preflightMinKernelMajor && minor >= preflightMinKernelMinor)
+	if !result.Passed {
+		result.Message += fmt.Sprintf(", at least %d.%d required", preflightMinKernelMajor, preflightMinKernelMinor)
+	}
+	return result
+}
+
+func parseKernelVersion(release string) (int, int, error) {
+	parts := strings.SplitN(release, ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("unexpected kernel version %q", release)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("unexpected kernel version %q", release)
+	}
+	minor, err := strconv.Atoi(strings.SplitN(parts[1], "-", 2)[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("unexpected kernel version %q", release)
+	}
+	return major, minor, nil
+}
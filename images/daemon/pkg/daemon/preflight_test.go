@@ -0,0 +1,112 @@
+package daemon
+
+import (
+	"reflect"
+	"testing"
+
+	kataTypes "github.com/openshift/sandboxed-containers-operator/api/v1"
+)
+
+func TestParseKernelVersion(t *testing.T) {
+	for _, test := range []struct {
+		release      string
+		major, minor int
+		valid        bool
+	}{
+		{"4.18.0-305.el8.x86_64", 4, 18, true},
+		{"5.10", 5, 10, true},
+		{"5.14-rc1", 5, 14, true},
+		{"5", 0, 0, false},
+		{"x.18.0", 0, 0, false},
+		{"4.y.0", 0, 0, false},
+		{"", 0, 0, false},
+	} {
+		major, minor, err := parseKernelVersion(test.release)
+		if (err == nil) != test.valid || major != test.major || minor != test.minor {
+			t.Errorf("parseKernelVersion(%q) = %d, %d, %v", test.release, major, minor, err)
+		}
+	}
+}
+
+func TestCPUFlags(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	host.writeFile(t, "/proc/cpuinfo", "processor\t: 0\nvendor_id\t: GenuineIntel\nflags\t\t: fpu vmx hypervisor\n"+
+		"processor\t: 1\nflags\t\t: fpu other\n")
+
+	flags, err := cpuFlags(host.Path("/proc/cpuinfo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]bool{"fpu": true, "vmx": true, "hypervisor": true}
+	if !reflect.DeepEqual(flags, expected) {
+		t.Errorf("got flags %v, want the ones of the first cpu %v", flags, expected)
+	}
+
+	if _, err := cpuFlags(host.Path("/proc/missing")); err == nil {
+		t.Error("no error for a missing cpuinfo")
+	}
+}
+
+func TestMemAvailable(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	host.writeFile(t, "/proc/meminfo", "MemTotal:       16303856 kB\nMemFree:         1093544 kB\nMemAvailable:    8151928 kB\n")
+	host.writeFile(t, "/proc/nomeminfo", "MemTotal:       16303856 kB\n")
+
+	available, err := memAvailable(host.Path("/proc/meminfo"))
+	if err != nil || available != 8151928*1024 {
+		t.Errorf("got %d, %v, want %d bytes", available, err, 8151928*1024)
+	}
+	if _, err := memAvailable(host.Path("/proc/nomeminfo")); err == nil {
+		t.Error("no error if MemAvailable is missing")
+	}
+}
+
+func TestCheckSELinux(t *testing.T) {
+	for _, test := range []struct {
+		enforce string
+		message string
+	}{
+		{"1", "enforcing"},
+		{"0", "permissive"},
+		{"", "disabled"},
+	} {
+		host := newFakeHost(t)
+		if test.enforce != "" {
+			host.writeFile(t, "/sys/fs/selinux/enforce", test.enforce+"\n")
+		}
+
+		result := checkSELinux(host)
+		if !result.Passed || result.Message != test.message {
+			t.Errorf("unexpected result %+v, want a passed check reporting %s", result, test.message)
+		}
+		host.cleanup()
+	}
+}
+
+func TestPreflightChecksRerun(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	k := newTestKataOpenShift(t, host)
+	nodeName := testNodeName(t)
+
+	passed := false
+	k.KataPreflightChecker = func(k *KataOpenShift) []kataTypes.PreflightCheckResult {
+		return []kataTypes.PreflightCheckResult{{Name: "kvm", Passed: passed}}
+	}
+
+	// The node failed first and got fixed before the daemon restarted
+	for _, passed = range []bool{false, true} {
+		ok, err := k.runPreflightChecks(testKataConfigName, nodeName)
+		if err != nil || ok != passed {
+			t.Fatalf("got %v, %v, want %v", ok, err, passed)
+		}
+	}
+
+	status := testKataConfigStatus(t, k).PreflightStatus
+	if !reflect.DeepEqual(status.PassedNodesList, []string{nodeName}) || status.Failed.FailedNodesCount != 0 ||
+		len(status.Failed.FailedNodesList) != 0 || len(status.NodeResults) != 1 || !status.NodeResults[0].Checks[0].Passed {
+		t.Errorf("unexpected preflight status %+v", status)
+	}
+}
@@ -0,0 +1,161 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	kataTypes "github.com/openshift/sandboxed-containers-operator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// rollbackRequested returns true if a failed node is to be rolled back, either because
+// of the automatic rollback policy or because the node is listed in the rollback nodes
+func rollbackRequested(kataConfig *kataTypes.KataConfig, nodeName string) bool {
+	if kataConfig.Spec.RollbackPolicy != kataTypes.RollbackPolicyManual {
+		return true
+	}
+
+	for _, n := range kataConfig.Spec.RollbackNodes {
+		if n == nodeName {
+			return true
+		}
+	}
+	return false
+}
+
+// handleFailedInstallation rolls back the node if the kata installation failed on it and a rollback is
+// requested. It returns true if the installation failed on the node, whether it got rolled back or not.
+func (k *KataOpenShift) handleFailedInstallation(kataConfigResourceName string, nodeName string) (bool, error) {
+	var kataConfig kataTypes.KataConfig
+	err := k.KataClient.Get(context.Background(), client.ObjectKey{
+		Name: kataConfigResourceName,
+	}, &kataConfig)
+	if err != nil {
+		return false, err
+	}
+
+	failed := false
+	for _, fn := range kataConfig.Status.InstallationStatus.Failed.FailedNodesList {
+		if fn.Name == nodeName {
+			failed = true
+			break
+		}
+	}
+	if !failed {
+		return false, nil
+	}
+
+	for _, n := range kataConfig.Status.RollbackStatus.CompletedNodesList {
+		if n == nodeName {
+			return true, nil
+		}
+	}
+	for _, fn := range kataConfig.Status.RollbackStatus.Failed.FailedNodesList {
+		if fn.Name == nodeName {
+			return true, nil
+		}
+	}
+
+	if !rollbackRequested(&kataConfig, nodeName) {
+		k.Log.Info("Kata installation failed on the node, waiting for a rollback request")
+		return true, nil
+	}
+
+	if k.KataRollbacker == nil {
+		k.KataRollbacker = rollbackRPMs
+	}
+
+	k.Log.Info("Rolling back the failed kata installation")
+	rollbackErr := k.KataRollbacker(k)
+	var diagnostics *kataTypes.KataNodeDiagnostics
+	if rollbackErr != nil {
+		diagnostics = k.collectDiagnostics(kataConfigResourceName, nodeName, rollbackErr)
+	}
+
+	err = updateKataConfigStatus(k.KataClient, kataConfigResourceName, func(ks *kataTypes.KataConfigStatus) {
+		if rollbackErr == nil {
+			ks.RollbackStatus.CompletedNodesList = append(ks.RollbackStatus.CompletedNodesList, nodeName)
+			return
+		}
+
+		ks.RollbackStatus.Failed.FailedNodesList = append(ks.RollbackStatus.Failed.FailedNodesList, kataTypes.FailedNodeStatus{
+			Name:  nodeName,
+			Error: fmt.Sprintf("%+v", rollbackErr),
+		})
+		ks.RollbackStatus.Failed.FailedNodesCount = len(ks.RollbackStatus.Failed.FailedNodesList)
+		setNodeDiagnostics(ks, nodeName, diagnostics)
+	})
+	if err != nil {
+		return true, fmt.Errorf("kata rollback done, error updating kataconfig status %+v", err)
+	}
+
+	return true, nil
+}
+
+// rollbackRPMs restores the rpm-ostree deployment the node booted before the kata
+// installation and removes whatever the installation left behind on the node
+func rollbackRPMs(k *KataOpenShift) error {
+	// A failed transaction might still be around, the pending deployment
+	// holding the layered kata packages is dropped after that
+	if err := k.runOnHost("rpm-ostree", "cancel"); err != nil {
+		k.Log.Error(err, "rpm-ostree cancel failed")
+	}
+
+	out, err := k.Host.Run("rpm-ostree", "status", "--json")
+	if err != nil {
+		return fmt.Errorf("rpm-ostree status failed: %v: %s", err, out)
+	}
+	booted, err := kataDeploymentBooted(out)
+	if err != nil {
+		return err
+	}
+
+	if booted {
+		// The node runs the kata deployment already, the previous one is booted on the next reboot
+		if err := k.runOnHost("rpm-ostree", "rollback"); err != nil {
+			return err
+		}
+	} else if err := k.runOnHost("rpm-ostree", "cleanup", "--pending"); err != nil {
+		return err
+	}
+
+	if err := k.cleanupHost(); err != nil {
+		return err
+	}
+
+	if err := removeAll(k.Host.Path("/etc/yum.repos.d/packages.repo")); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// rpmOstreeStatus is the part of the rpm-ostree status the rollback looks at
+type rpmOstreeStatus struct {
+	Deployments []struct {
+		Booted            bool     `json:"booted"`
+		RequestedPackages []string `json:"requested-packages"`
+	} `json:"deployments"`
+}
+
+// kataDeploymentBooted returns true if the node booted a deployment with the kata packages layered,
+// out is the output of rpm-ostree status --json
+func kataDeploymentBooted(out []byte) (bool, error) {
+	var status rpmOstreeStatus
+	if err := json.Unmarshal(out, &status); err != nil {
+		return false, fmt.Errorf("unable to parse rpm-ostree status: %v", err)
+	}
+
+	for _, d := range status.Deployments {
+		if !d.Booted {
+			continue
+		}
+		for _, p := range d.RequestedPackages {
+			if p == "kata-containers" {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKataDeploymentBooted(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		status string
+		booted bool
+		valid  bool
+	}{
+		{"pending kata deployment", `{"deployments": [{"requested-packages": ["kata-containers"]}, {"booted": true}]}`, false, true},
+		{"booted kata deployment", `{"deployments": [{"booted": true, "requested-packages": ["kata-containers"]}, {}]}`, true, true},
+		{"other packages", `{"deployments": [{"booted": true, "requested-packages": ["vim"]}]}`, false, true},
+		{"invalid", `rpm-ostree: not found`, false, false},
+	} {
+		booted, err := kataDeploymentBooted([]byte(test.status))
+		if booted != test.booted || (err == nil) != test.valid {
+			t.Errorf("%s: got %v, %v", test.name, booted, err)
+		}
+	}
+}
+
+func TestRollbackBootedKataDeployment(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	k := newTestKataOpenShift(t, host)
+	host.failures["/usr/bin/rpm-ostree install"] = errors.New("exit status 1")
+	host.outputs["rpm-ostree status --json"] = `{"deployments": [{"booted": true, "requested-packages": ["kata-containers"]}]}`
+
+	if err := k.Install(testKataConfigName); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if !host.ran("rpm-ostree rollback") || host.ran("rpm-ostree cleanup --pending") {
+		t.Errorf("the booted kata deployment is not rolled back, commands: %q", host.commands)
+	}
+}
+
+func TestRollbackFailure(t *testing.T) {
+	host := newFakeHost(t)
+	defer host.cleanup()
+	k := newTestKataOpenShift(t, host)
+	nodeName := testNodeName(t)
+	host.failures["/usr/bin/rpm-ostree install"] = errors.New("exit status 1")
+	host.failures["rpm-ostree cleanup"] = errors.New("exit status 2")
+
+	if err := k.Install(testKataConfigName); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	status := testKataConfigStatus(t, k)
+	failed := status.RollbackStatus.Failed.FailedNodesList
+	if len(failed) != 1 || failed[0].Name != nodeName || failed[0].Error != "exit status 2" ||
+		status.RollbackStatus.Failed.FailedNodesCount != 1 {
+		t.Errorf("unexpected rollback status %+v", status.RollbackStatus)
+	}
+}
@@ -0,0 +1,66 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+	kataTypes "github.com/openshift/sandboxed-containers-operator/api/v1"
+)
+
+// kataConfigPaths are relative to the host root, the first one found is the configuration kata uses
+var kataConfigPaths = []string{
+	"etc/kata-containers/configuration.toml",
+	"usr/share/kata-containers/defaults/configuration.toml",
+}
+
+type hypervisorConfig struct {
+	DefaultMemory int32 `toml:"default_memory"`
+	DefaultVCPUs  int32 `toml:"default_vcpus"`
+}
+
+// readSandboxConfig reads the VM settings of the kata sandboxes from the kata configuration of the node
+func readSandboxConfig(host HostExecutor, nodeName string) (kataTypes.NodeSandboxConfig, error) {
+	for _, path := range kataConfigPaths {
+		var config struct {
+			Hypervisor map[string]hypervisorConfig `toml:"hypervisor"`
+		}
+		if _, err := toml.DecodeFile(host.Path(path), &config); os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return kataTypes.NodeSandboxConfig{}, fmt.Errorf("unable to read the kata configuration %s: %v", path, err)
+		}
+
+		// The configuration has a single hypervisor section, take the first one should there be more
+		var hypervisors []string
+		for name := range config.Hypervisor {
+			hypervisors = append(hypervisors, name)
+		}
+		if len(hypervisors) == 0 {
+			return kataTypes.NodeSandboxConfig{}, fmt.Errorf("no hypervisor configured in the kata configuration %s", path)
+		}
+		sort.Strings(hypervisors)
+		h := config.Hypervisor[hypervisors[0]]
+
+		sandbox := kataTypes.NodeSandboxConfig{
+			Name:             nodeName,
+			Hypervisor:       hypervisors[0],
+			DefaultMemoryMiB: h.DefaultMemory,
+			DefaultVCPUs:     h.DefaultVCPUs,
+		}
+		if sandbox.DefaultMemoryMiB <= 0 {
+			sandbox.DefaultMemoryMiB = kataTypes.KataDefaultMemoryMiB
+		}
+		// kata gives the VM all CPUs of the node for a negative or too large value
+		if sandbox.DefaultVCPUs < 0 || int(sandbox.DefaultVCPUs) > runtime.NumCPU() {
+			sandbox.DefaultVCPUs = int32(runtime.NumCPU())
+		} else if sandbox.DefaultVCPUs == 0 {
+			sandbox.DefaultVCPUs = kataTypes.KataDefaultVCPUs
+		}
+		return sandbox, nil
+	}
+
+	return kataTypes.NodeSandboxConfig{}, fmt.Errorf("no kata configuration found")
+}
@@ -19,8 +19,10 @@ package main
 import (
 	"flag"
 	"os"
+	"time"
 
 	mcfgapi "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -28,9 +30,11 @@ import (
 	nodeapi "k8s.io/kubernetes/pkg/apis/node/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
 	"github.com/openshift/sandboxed-containers-operator/controllers"
+	"github.com/openshift/sandboxed-containers-operator/webhooks"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -53,14 +57,71 @@ func init() {
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var orphanSweepInterval time.Duration
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&orphanSweepInterval, "orphan-sweep-interval", 10*time.Minute,
+		"How often objects left behind by deleted KataConfigs are looked for and removed.")
+
+	var daemon controllers.DaemonConfig
+	var daemonPullPolicy, daemonPullSecrets, daemonTolerations, daemonRequests, daemonLimits string
+	flag.StringVar(&daemon.Image, "daemon-image", envOrDefault("RELATED_IMAGE_KATA_DAEMON", controllers.DefaultDaemonImage),
+		"The image of the daemon installing kata on the nodes. Defaults to $RELATED_IMAGE_KATA_DAEMON.")
+	flag.StringVar(&daemonPullPolicy, "daemon-image-pull-policy", string(corev1.PullIfNotPresent),
+		"The pull policy of the daemon image.")
+	flag.StringVar(&daemon.Namespace, "daemon-namespace", envOrDefault("OPERATOR_NAMESPACE", controllers.DefaultOperatorNamespace),
+		"The namespace the daemons run in. Defaults to $OPERATOR_NAMESPACE, the namespace of the operator.")
+	flag.StringVar(&daemon.ServiceAccount, "daemon-service-account", controllers.DefaultDaemonServiceAccount,
+		"The service account the daemons run as. It is created by the operator along with the roles of the daemon.")
+	flag.StringVar(&daemonPullSecrets, "daemon-image-pull-secrets", "",
+		"Comma separated list of the secrets in the daemon namespace used to pull the daemon image.")
+	flag.StringVar(&daemonTolerations, "daemon-tolerations", "",
+		"The tolerations of the daemon pods as a JSON list.")
+	flag.StringVar(&daemon.PriorityClassName, "daemon-priority-class", "",
+		"The priority class of the daemon pods.")
+	flag.StringVar(&daemonRequests, "daemon-requests", "",
+		"The resources requested by the daemon, e.g. cpu=10m,memory=50Mi.")
+	flag.StringVar(&daemonLimits, "daemon-limits", "",
+		"The resource limits of the daemon, e.g. memory=500Mi.")
+	flag.StringVar(&daemon.LogLevel, "daemon-log-level", envOrDefault("DAEMON_LOG_LEVEL", controllers.DefaultDaemonLogLevel),
+		"The log level of the daemon: debug, info or error. Defaults to $DAEMON_LOG_LEVEL.")
+	flag.StringVar(&daemon.LogFormat, "daemon-log-format", envOrDefault("DAEMON_LOG_FORMAT", controllers.DefaultDaemonLogFormat),
+		"The log format of the daemon: console or json. Defaults to $DAEMON_LOG_FORMAT.")
+	var sandboxingPolicyMode string
+	flag.StringVar(&sandboxingPolicyMode, "sandboxing-policy-mode", string(webhooks.PolicyEnforce),
+		"What happens to pods without a kata runtime class in namespaces labelled sandboxing=required: "+
+			"enforce denies them, audit admits them and records an event.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
 
+	daemon.ImagePullPolicy = corev1.PullPolicy(daemonPullPolicy)
+	daemon.ImagePullSecrets = controllers.ParseList(daemonPullSecrets)
+	var err error
+	if daemon.Tolerations, err = controllers.ParseTolerations(daemonTolerations); err != nil {
+		setupLog.Error(err, "invalid --daemon-tolerations")
+		os.Exit(1)
+	}
+	if daemon.Resources.Requests, err = controllers.ParseResourceList(daemonRequests); err != nil {
+		setupLog.Error(err, "invalid --daemon-requests")
+		os.Exit(1)
+	}
+	if daemon.Resources.Limits, err = controllers.ParseResourceList(daemonLimits); err != nil {
+		setupLog.Error(err, "invalid --daemon-limits")
+		os.Exit(1)
+	}
+	if err := daemon.ValidateLogging(); err != nil {
+		setupLog.Error(err, "invalid daemon logging")
+		os.Exit(1)
+	}
+	policyMode, err := webhooks.ParsePolicyMode(sandboxingPolicyMode)
+	if err != nil {
+		setupLog.Error(err, "invalid --sandboxing-policy-mode")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
@@ -84,6 +145,7 @@ func main() {
 			Client: mgr.GetClient(),
 			Log:    ctrl.Log.WithName("controllers").WithName("KataConfig"),
 			Scheme: mgr.GetScheme(),
+			Daemon: daemon,
 		}).SetupWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create KataConfig controller for OpenShift cluster", "controller", "KataConfig")
 			os.Exit(1)
@@ -93,6 +155,7 @@ func main() {
 			Client: mgr.GetClient(),
 			Log:    ctrl.Log.WithName("controllers").WithName("KataConfig"),
 			Scheme: mgr.GetScheme(),
+			Daemon: daemon,
 		}).SetupWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create KataConfig controller for Kubernetes cluster", "controller", "KataConfig")
 			os.Exit(1)
@@ -100,9 +163,43 @@ func main() {
 	}
 	// +kubebuilder:scaffold:builder
 
+	// The webhooks need the serving certificate the webhook patch of config/default mounts
+	if os.Getenv("ENABLE_WEBHOOKS") == "true" {
+		mgr.GetWebhookServer().Register(webhooks.PodMutatorPath, &webhook.Admission{Handler: &webhooks.PodMutator{
+			Client:            mgr.GetClient(),
+			Log:               ctrl.Log.WithName("webhooks").WithName("PodMutator"),
+			OperatorNamespace: daemon.Namespace,
+		}})
+		mgr.GetWebhookServer().Register(webhooks.PodValidatorPath, &webhook.Admission{Handler: &webhooks.PodValidator{
+			Client:            mgr.GetClient(),
+			Log:               ctrl.Log.WithName("webhooks").WithName("PodValidator"),
+			Recorder:          mgr.GetEventRecorderFor("sandboxing-policy"),
+			Mode:              policyMode,
+			OperatorNamespace: daemon.Namespace,
+		}})
+	}
+
+	if err := mgr.Add(&controllers.OrphanSweeper{
+		Client:    mgr.GetClient(),
+		Log:       ctrl.Log.WithName("sweeper"),
+		Interval:  orphanSweepInterval,
+		OpenShift: isOpenshift,
+	}); err != nil {
+		setupLog.Error(err, "unable to add the orphan sweeper")
+		os.Exit(1)
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
+
+// envOrDefault returns the value of the environment variable, or the default if it isn't set
+func envOrDefault(key string, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return defaultValue
+}
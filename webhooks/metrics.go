@@ -0,0 +1,36 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// deniedPods counts the pods violating the sandboxing policy. In audit mode the pods are
+	// counted but admitted.
+	deniedPods = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sandboxed_containers_policy_denied_pods_total",
+		Help: "Number of pods violating the sandboxing policy of their namespace, by namespace and policy mode",
+	}, []string{"namespace", "mode"})
+)
+
+func init() {
+	// Served on the metrics endpoint of the manager
+	metrics.Registry.MustRegister(deniedPods)
+}
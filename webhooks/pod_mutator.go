@@ -0,0 +1,211 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// PodMutatorPath is the path the pod mutating webhook is served at
+const PodMutatorPath = "/mutate-v1-pod"
+
+// +kubebuilder:webhook:path=/mutate-v1-pod,mutating=true,failurePolicy=ignore,groups="",resources=pods,verbs=create,versions=v1,name=mpod.kataconfiguration.openshift.io
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// PodMutator sets the kata runtime class on the pods selected by the runtime class injection
+// policy of a KataConfig
+type PodMutator struct {
+	Client client.Client
+	Log    logr.Logger
+	// OperatorNamespace runs the daemons installing kata, its pods never get the runtime class
+	OperatorNamespace string
+
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &PodMutator{}
+
+// InjectDecoder is called by the webhook server
+func (m *PodMutator) InjectDecoder(d *admission.Decoder) error {
+	m.decoder = d
+	return nil
+}
+
+// Handle sets the runtime class of the pod if a KataConfig selects it. The pod is let through
+// unchanged if anything goes wrong, the webhook never keeps pods from being created.
+func (m *PodMutator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pod := &corev1.Pod{}
+	if err := m.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if pod.Spec.RuntimeClassName != nil {
+		return admission.Allowed("the pod sets its runtime class")
+	}
+	if systemNamespace(req.Namespace, m.OperatorNamespace) {
+		return admission.Allowed("no runtime class is injected into the pods of system namespaces")
+	}
+	if optedOut(pod.Annotations) {
+		return admission.Allowed("the pod opted out of the runtime class injection")
+	}
+
+	namespace := &corev1.Namespace{}
+	if err := m.Client.Get(ctx, types.NamespacedName{Name: req.Namespace}, namespace); err != nil {
+		return m.allowOnError(req, err)
+	}
+	if optedOut(namespace.Annotations) {
+		return admission.Allowed("the namespace opted out of the runtime class injection")
+	}
+
+	kataConfig, err := m.selectingKataConfig(ctx, namespace, pod)
+	if err != nil {
+		return m.allowOnError(req, err)
+	}
+	if kataConfig == nil {
+		return admission.Allowed("no KataConfig selects the pod")
+	}
+
+	// Kata pods would not start before the installation is done, nor once the uninstallation began
+	runtimeClasses := kataConfig.Status.RuntimeClassNames()
+	if len(runtimeClasses) == 0 || kataConfig.GetDeletionTimestamp() != nil {
+		reason := fmt.Sprintf("kata is not installed by KataConfig %s, the pod runs without the kata runtime class", kataConfig.Name)
+		m.Log.Info("Not injecting the runtime class", "namespace", req.Namespace, "pod", podName(req, pod),
+			"kataconfig", kataConfig.Name, "reason", reason)
+		resp := admission.Allowed(reason)
+		resp.Warnings = []string{reason}
+		return resp
+	}
+	if pod.Spec.HostNetwork || pod.Spec.HostPID || pod.Spec.HostIPC {
+		return admission.Allowed("pods using the namespaces of the node can't run in a kata VM")
+	}
+
+	runtimeClassName := injectedRuntimeClass(runtimeClasses)
+	pod.Spec.RuntimeClassName = &runtimeClassName
+	marshaled, err := json.Marshal(pod)
+	if err != nil {
+		return m.allowOnError(req, err)
+	}
+
+	m.Log.Info("Injecting the runtime class", "namespace", req.Namespace, "pod", podName(req, pod),
+		"kataconfig", kataConfig.Name, "runtimeClass", runtimeClassName)
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// allowOnError lets the pod through unchanged. An error response would deny the pod, the failure
+// policy of the webhook only applies if the webhook can't be called.
+func (m *PodMutator) allowOnError(req admission.Request, err error) admission.Response {
+	m.Log.Error(err, "Unable to decide on the runtime class injection", "namespace", req.Namespace, "pod", req.Name)
+	return admission.Allowed(fmt.Sprintf("runtime class not injected: %v", err))
+}
+
+// selectingKataConfig returns the KataConfig whose injection policy selects the pod. If several
+// do, the first one by name wins.
+func (m *PodMutator) selectingKataConfig(ctx context.Context, namespace *corev1.Namespace,
+	pod *corev1.Pod) (*kataconfigurationv1.KataConfig, error) {
+	kataConfigList := &kataconfigurationv1.KataConfigList{}
+	if err := m.Client.List(ctx, kataConfigList); err != nil {
+		return nil, err
+	}
+	sort.Slice(kataConfigList.Items, func(i, j int) bool {
+		return kataConfigList.Items[i].Name < kataConfigList.Items[j].Name
+	})
+
+	for i := range kataConfigList.Items {
+		kataConfig := &kataConfigList.Items[i]
+		policy := kataConfig.Spec.RuntimeClassInjection
+		if policy == nil || (policy.NamespaceSelector == nil && policy.PodSelector == nil) {
+			continue
+		}
+
+		selected, err := policySelects(policy, namespace.Labels, pod.Labels)
+		if err != nil {
+			m.Log.Error(err, "Invalid runtime class injection policy", "kataconfig", kataConfig.Name)
+			continue
+		}
+		if selected {
+			return kataConfig, nil
+		}
+	}
+	return nil, nil
+}
+
+// policySelects returns true if the pod matches every selector the policy has
+func policySelects(policy *kataconfigurationv1.KataRuntimeClassInjection, namespaceLabels map[string]string,
+	podLabels map[string]string) (bool, error) {
+	for _, s := range []struct {
+		selector *metav1.LabelSelector
+		labels   map[string]string
+	}{
+		{policy.NamespaceSelector, namespaceLabels},
+		{policy.PodSelector, podLabels},
+	} {
+		if s.selector == nil {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(s.selector)
+		if err != nil {
+			return false, err
+		}
+		if !selector.Matches(labels.Set(s.labels)) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// injectedRuntimeClass returns the runtime class set on the pods: the default kata runtime class if
+// the KataConfig has several, as kata-deploy does on Kubernetes, or its only one
+func injectedRuntimeClass(runtimeClasses []string) string {
+	for _, name := range runtimeClasses {
+		if name == kataconfigurationv1.DefaultRuntimeClassName {
+			return name
+		}
+	}
+	return runtimeClasses[0]
+}
+
+// systemNamespace returns true for the namespaces of the cluster components and of the operator
+func systemNamespace(namespace string, operatorNamespace string) bool {
+	return namespace == operatorNamespace || namespace == "openshift" ||
+		strings.HasPrefix(namespace, "openshift-") || strings.HasPrefix(namespace, "kube-")
+}
+
+func optedOut(annotations map[string]string) bool {
+	return annotations[kataconfigurationv1.InjectRuntimeClassAnnotation] == "false"
+}
+
+// podName returns the name of the pod, the pods of a controller only have a generated name yet
+func podName(req admission.Request, pod *corev1.Pod) string {
+	if req.Name != "" {
+		return req.Name
+	}
+	return pod.GenerateName
+}
@@ -0,0 +1,183 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := kataconfigurationv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+func newKataConfig(name string, runtimeClass string, policy *kataconfigurationv1.KataRuntimeClassInjection) *kataconfigurationv1.KataConfig {
+	return &kataconfigurationv1.KataConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       kataconfigurationv1.KataConfigSpec{RuntimeClassInjection: policy},
+		Status:     kataconfigurationv1.KataConfigStatus{RuntimeClass: runtimeClass},
+	}
+}
+
+func newNamespace(name string, labels map[string]string, annotations map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels, Annotations: annotations}}
+}
+
+func newPod(namespace string, labels map[string]string, annotations map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: namespace, Labels: labels, Annotations: annotations},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "app"}}},
+	}
+}
+
+func newRequest(t *testing.T, pod *corev1.Pod) admission.Request {
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+		Name:      pod.Name,
+		Namespace: pod.Namespace,
+		Operation: admissionv1beta1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}}
+}
+
+func TestPodMutator(t *testing.T) {
+	sandboxed := map[string]string{"sandboxing": "kata"}
+	byNamespace := &kataconfigurationv1.KataRuntimeClassInjection{
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: sandboxed},
+	}
+	byPod := &kataconfigurationv1.KataRuntimeClassInjection{
+		PodSelector: &metav1.LabelSelector{MatchLabels: sandboxed},
+	}
+	optOut := map[string]string{kataconfigurationv1.InjectRuntimeClassAnnotation: "false"}
+
+	tests := []struct {
+		name         string
+		kataConfig   *kataconfigurationv1.KataConfig
+		namespace    *corev1.Namespace
+		pod          *corev1.Pod
+		runtimeClass string
+		warning      bool
+	}{
+		{
+			name:         "namespace selected",
+			kataConfig:   newKataConfig("example", "kata", byNamespace),
+			namespace:    newNamespace("apps", sandboxed, nil),
+			pod:          newPod("apps", nil, nil),
+			runtimeClass: "kata",
+		},
+		{
+			name:       "namespace not selected",
+			kataConfig: newKataConfig("example", "kata", byNamespace),
+			namespace:  newNamespace("apps", nil, nil),
+			pod:        newPod("apps", nil, nil),
+		},
+		{
+			name:         "pod selected",
+			kataConfig:   newKataConfig("example", "kata-example", byPod),
+			namespace:    newNamespace("apps", nil, nil),
+			pod:          newPod("apps", sandboxed, nil),
+			runtimeClass: "kata-example",
+		},
+		{
+			name:         "kata-deploy runtime classes",
+			kataConfig:   newKataConfig("example", "kata-qemu-virtiofs,kata-qemu,kata-clh,kata-fc,kata", byNamespace),
+			namespace:    newNamespace("apps", sandboxed, nil),
+			pod:          newPod("apps", nil, nil),
+			runtimeClass: "kata",
+		},
+		{
+			name:         "several runtime classes without the default one",
+			kataConfig:   newKataConfig("example", "kata-qemu, kata-clh", byNamespace),
+			namespace:    newNamespace("apps", sandboxed, nil),
+			pod:          newPod("apps", nil, nil),
+			runtimeClass: "kata-qemu",
+		},
+		{
+			name:       "pod opted out",
+			kataConfig: newKataConfig("example", "kata", byNamespace),
+			namespace:  newNamespace("apps", sandboxed, nil),
+			pod:        newPod("apps", nil, optOut),
+		},
+		{
+			name:       "namespace opted out",
+			kataConfig: newKataConfig("example", "kata", byPod),
+			namespace:  newNamespace("apps", nil, optOut),
+			pod:        newPod("apps", sandboxed, nil),
+		},
+		{
+			name:       "kata not installed",
+			kataConfig: newKataConfig("example", "", byNamespace),
+			namespace:  newNamespace("apps", sandboxed, nil),
+			pod:        newPod("apps", nil, nil),
+			warning:    true,
+		},
+		{
+			name:       "operator namespace",
+			kataConfig: newKataConfig("example", "kata", byNamespace),
+			namespace:  newNamespace("sandboxed-containers-operator-system", sandboxed, nil),
+			pod:        newPod("sandboxed-containers-operator-system", nil, nil),
+		},
+		{
+			name:       "no policy",
+			kataConfig: newKataConfig("example", "kata", nil),
+			namespace:  newNamespace("apps", sandboxed, nil),
+			pod:        newPod("apps", sandboxed, nil),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scheme := newScheme(t)
+			decoder, err := admission.NewDecoder(scheme)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mutator := &PodMutator{
+				Client:            fake.NewFakeClientWithScheme(scheme, test.kataConfig, test.namespace),
+				Log:               log.Log,
+				OperatorNamespace: "sandboxed-containers-operator-system",
+			}
+			if err := mutator.InjectDecoder(decoder); err != nil {
+				t.Fatal(err)
+			}
+
+			resp := mutator.Handle(context.Background(), newRequest(t, test.pod))
+			if !resp.Allowed {
+				t.Fatalf("pod denied: %v", resp.Result)
+			}
+			if test.warning != (len(resp.Warnings) > 0) {
+				t.Errorf("unexpected warnings %v", resp.Warnings)
+			}
+
+			if test.runtimeClass == "" {
+				if len(resp.Patches) > 0 {
+					t.Errorf("unexpected patches %v", resp.Patches)
+				}
+				return
+			}
+			if len(resp.Patches) != 1 || resp.Patches[0].Path != "/spec/runtimeClassName" ||
+				resp.Patches[0].Value != test.runtimeClass {
+				t.Errorf("unexpected patches %v, want the runtime class %s", resp.Patches, test.runtimeClass)
+			}
+		})
+	}
+}
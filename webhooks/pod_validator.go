@@ -0,0 +1,177 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	kataconfigurationv1 "github.com/openshift/sandboxed-containers-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// PodValidatorPath is the path the pod validating webhook is served at
+const PodValidatorPath = "/validate-v1-pod"
+
+// PolicyMode is what happens to the pods violating the sandboxing policy
+type PolicyMode string
+
+const (
+	// PolicyEnforce denies the pods violating the sandboxing policy
+	PolicyEnforce PolicyMode = "enforce"
+	// PolicyAudit admits the pods violating the sandboxing policy and records an event
+	PolicyAudit PolicyMode = "audit"
+)
+
+// ParsePolicyMode parses the mode of the sandboxing policy
+func ParsePolicyMode(value string) (PolicyMode, error) {
+	switch mode := PolicyMode(value); mode {
+	case PolicyEnforce, PolicyAudit:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown sandboxing policy mode %q, expected %s or %s", value, PolicyEnforce, PolicyAudit)
+	}
+}
+
+// +kubebuilder:webhook:path=/validate-v1-pod,mutating=false,failurePolicy=fail,groups="",resources=pods,verbs=create,versions=v1,name=vpod.kataconfiguration.openshift.io
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// PodValidator enforces the sandboxing policy: the pods of the namespaces labelled
+// sandboxing=required have to use the runtime class of a KataConfig
+type PodValidator struct {
+	Client   client.Client
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	Mode     PolicyMode
+	// OperatorNamespace runs the daemons installing kata, the policy never applies to it
+	OperatorNamespace string
+
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &PodValidator{}
+
+// InjectDecoder is called by the webhook server
+func (v *PodValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle admits the pod if its namespace doesn't require sandboxing or if the pod uses a kata
+// runtime class. In enforce mode the pod is denied if the policy can't be checked.
+func (v *PodValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pod := &corev1.Pod{}
+	if err := v.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if systemNamespace(req.Namespace, v.OperatorNamespace) {
+		return admission.Allowed("the sandboxing policy doesn't apply to system namespaces")
+	}
+
+	namespace := &corev1.Namespace{}
+	if err := v.Client.Get(ctx, types.NamespacedName{Name: req.Namespace}, namespace); err != nil {
+		return v.onError(req, err)
+	}
+	if namespace.Labels[kataconfigurationv1.SandboxingLabel] != kataconfigurationv1.SandboxingRequired {
+		return admission.Allowed("the namespace doesn't require sandboxing")
+	}
+
+	runtimeClasses, err := v.kataRuntimeClasses(ctx)
+	if err != nil {
+		return v.onError(req, err)
+	}
+	if pod.Spec.RuntimeClassName != nil {
+		for _, runtimeClass := range runtimeClasses {
+			if *pod.Spec.RuntimeClassName == runtimeClass {
+				return admission.Allowed("the pod uses a kata runtime class")
+			}
+		}
+	}
+
+	reason := violation(req.Namespace, pod, runtimeClasses)
+	deniedPods.WithLabelValues(req.Namespace, string(v.Mode)).Inc()
+	// The pod doesn't exist yet, the event goes to the namespace
+	if v.Mode == PolicyAudit {
+		v.Recorder.Event(namespace, corev1.EventTypeWarning, "SandboxingPolicyViolated",
+			fmt.Sprintf("pod %s admitted in audit mode: %s", podName(req, pod), reason))
+		v.Log.Info("Admitting pod violating the sandboxing policy", "namespace", req.Namespace,
+			"pod", podName(req, pod), "reason", reason)
+		resp := admission.Allowed(reason)
+		resp.Warnings = []string{reason}
+		return resp
+	}
+
+	v.Recorder.Event(namespace, corev1.EventTypeWarning, "SandboxingPolicyDenied",
+		fmt.Sprintf("pod %s denied: %s", podName(req, pod), reason))
+	v.Log.Info("Denying pod violating the sandboxing policy", "namespace", req.Namespace,
+		"pod", podName(req, pod), "reason", reason)
+	return admission.Denied(reason)
+}
+
+// onError denies the pod in enforce mode, a pod must not get around the policy because the
+// policy couldn't be checked. In audit mode the pod is admitted.
+func (v *PodValidator) onError(req admission.Request, err error) admission.Response {
+	v.Log.Error(err, "Unable to check the sandboxing policy", "namespace", req.Namespace, "pod", req.Name)
+	if v.Mode == PolicyAudit {
+		return admission.Allowed(fmt.Sprintf("sandboxing policy not checked: %v", err))
+	}
+	return admission.Errored(http.StatusInternalServerError, err)
+}
+
+// kataRuntimeClasses returns the runtime classes of the KataConfigs which have kata installed
+func (v *PodValidator) kataRuntimeClasses(ctx context.Context) ([]string, error) {
+	kataConfigList := &kataconfigurationv1.KataConfigList{}
+	if err := v.Client.List(ctx, kataConfigList); err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, kataConfig := range kataConfigList.Items {
+		for _, name := range kataConfig.Status.RuntimeClassNames() {
+			names[name] = true
+		}
+	}
+	var runtimeClasses []string
+	for name := range names {
+		runtimeClasses = append(runtimeClasses, name)
+	}
+	sort.Strings(runtimeClasses)
+	return runtimeClasses, nil
+}
+
+// violation tells why the pod violates the sandboxing policy of the namespace
+func violation(namespace string, pod *corev1.Pod, runtimeClasses []string) string {
+	reason := fmt.Sprintf("namespace %s requires sandboxing, ", namespace)
+	switch {
+	case len(runtimeClasses) == 0:
+		return reason + "but kata is not installed on the cluster"
+	case pod.Spec.RuntimeClassName == nil:
+		reason += "the pod has no runtime class"
+	default:
+		reason += fmt.Sprintf("the runtime class %s of the pod is not a kata runtime class", *pod.Spec.RuntimeClassName)
+	}
+	return reason + fmt.Sprintf(", set runtimeClassName to one of %s", strings.Join(runtimeClasses, ", "))
+}
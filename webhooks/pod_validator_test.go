@@ -0,0 +1,155 @@
+package webhooks
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestPodValidator(t *testing.T) {
+	required := map[string]string{"sandboxing": "required"}
+	withRuntimeClass := func(pod *corev1.Pod, runtimeClass string) *corev1.Pod {
+		pod.Spec.RuntimeClassName = &runtimeClass
+		return pod
+	}
+
+	tests := []struct {
+		name      string
+		mode      PolicyMode
+		namespace *corev1.Namespace
+		pod       *corev1.Pod
+		allowed   bool
+		event     bool
+	}{
+		{
+			name:      "namespace not requiring sandboxing",
+			mode:      PolicyEnforce,
+			namespace: newNamespace("apps", nil, nil),
+			pod:       newPod("apps", nil, nil),
+			allowed:   true,
+		},
+		{
+			name:      "kata runtime class",
+			mode:      PolicyEnforce,
+			namespace: newNamespace("tenant", required, nil),
+			pod:       withRuntimeClass(newPod("tenant", nil, nil), "kata-example"),
+			allowed:   true,
+		},
+		{
+			name:      "kata-deploy runtime class",
+			mode:      PolicyEnforce,
+			namespace: newNamespace("tenant", required, nil),
+			pod:       withRuntimeClass(newPod("tenant", nil, nil), "kata-clh"),
+			allowed:   true,
+		},
+		{
+			name:      "no runtime class",
+			mode:      PolicyEnforce,
+			namespace: newNamespace("tenant", required, nil),
+			pod:       newPod("tenant", nil, nil),
+			event:     true,
+		},
+		{
+			name:      "other runtime class",
+			mode:      PolicyEnforce,
+			namespace: newNamespace("tenant", required, nil),
+			pod:       withRuntimeClass(newPod("tenant", nil, nil), "runc"),
+			event:     true,
+		},
+		{
+			name:      "audit",
+			mode:      PolicyAudit,
+			namespace: newNamespace("tenant", required, nil),
+			pod:       newPod("tenant", nil, nil),
+			allowed:   true,
+			event:     true,
+		},
+		{
+			name:      "operator namespace",
+			mode:      PolicyEnforce,
+			namespace: newNamespace("sandboxed-containers-operator-system", required, nil),
+			pod:       newPod("sandboxed-containers-operator-system", nil, nil),
+			allowed:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scheme := newScheme(t)
+			decoder, err := admission.NewDecoder(scheme)
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorder := record.NewFakeRecorder(10)
+			validator := &PodValidator{
+				Client: fake.NewFakeClientWithScheme(scheme, test.namespace,
+					newKataConfig("example", "kata-example", nil), newKataConfig("pending", "", nil),
+					newKataConfig("kubernetes", "kata-qemu,kata-clh,kata", nil)),
+				Log:               log.Log,
+				Recorder:          recorder,
+				Mode:              test.mode,
+				OperatorNamespace: "sandboxed-containers-operator-system",
+			}
+			if err := validator.InjectDecoder(decoder); err != nil {
+				t.Fatal(err)
+			}
+
+			denied := deniedPods.WithLabelValues(test.pod.Namespace, string(test.mode))
+			before := testutil.ToFloat64(denied)
+
+			resp := validator.Handle(context.Background(), newRequest(t, test.pod))
+			if resp.Allowed != test.allowed {
+				t.Errorf("allowed is %v, want %v: %v", resp.Allowed, test.allowed, resp.Result)
+			}
+			if len(recorder.Events) > 0 != test.event {
+				t.Errorf("recorded %d events, want an event: %v", len(recorder.Events), test.event)
+			}
+			if counted := testutil.ToFloat64(denied) - before; counted != 0 != test.event {
+				t.Errorf("counted %v denied pods", counted)
+			}
+		})
+	}
+}
+
+func TestKataRuntimeClasses(t *testing.T) {
+	validator := &PodValidator{
+		Client: fake.NewFakeClientWithScheme(newScheme(t),
+			newKataConfig("example", "kata", nil), newKataConfig("pending", "", nil),
+			newKataConfig("kubernetes", "kata-qemu-virtiofs,kata-qemu,kata-clh,kata-fc,kata", nil)),
+	}
+
+	runtimeClasses, err := validator.kataRuntimeClasses(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"kata", "kata-clh", "kata-fc", "kata-qemu", "kata-qemu-virtiofs"}
+	if !reflect.DeepEqual(runtimeClasses, expected) {
+		t.Errorf("got runtime classes %v, want %v", runtimeClasses, expected)
+	}
+}
+
+func TestViolation(t *testing.T) {
+	runc := "runc"
+	pod := newPod("tenant", nil, nil)
+
+	if got, want := violation("tenant", pod, nil),
+		"namespace tenant requires sandboxing, but kata is not installed on the cluster"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := violation("tenant", pod, []string{"kata", "kata-example"}),
+		"namespace tenant requires sandboxing, the pod has no runtime class, set runtimeClassName to one of kata, kata-example"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	pod.Spec.RuntimeClassName = &runc
+	if got, want := violation("tenant", pod, []string{"kata"}),
+		"namespace tenant requires sandboxing, the runtime class runc of the pod is not a kata runtime class, set runtimeClassName to one of kata"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}